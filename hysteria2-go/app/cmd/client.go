@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"crypto/tls"
@@ -15,11 +14,13 @@ import (
 	"net/netip"
 	"os"
 	"os/signal"
+	"reflect"
 	"runtime"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -27,11 +28,13 @@ import (
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/apernet/hysteria/app/v2/internal/clientapi"
 	"github.com/apernet/hysteria/app/v2/internal/forwarding"
 	"github.com/apernet/hysteria/app/v2/internal/http"
 	"github.com/apernet/hysteria/app/v2/internal/mimic"
 	"github.com/apernet/hysteria/app/v2/internal/proxymux"
 	"github.com/apernet/hysteria/app/v2/internal/redirect"
+	"github.com/apernet/hysteria/app/v2/internal/routing"
 	"github.com/apernet/hysteria/app/v2/internal/sockopts"
 	"github.com/apernet/hysteria/app/v2/internal/socks5"
 	"github.com/apernet/hysteria/app/v2/internal/tproxy"
@@ -73,26 +76,103 @@ func initClientFlags() {
 }
 
 type clientConfig struct {
-	Server        string                 `mapstructure:"server"`
-	Auth          string                 `mapstructure:"auth"`
-	Realm         clientConfigRealm      `mapstructure:"realm"`
-	Transport     clientConfigTransport  `mapstructure:"transport"`
-	Obfs          clientConfigObfs       `mapstructure:"obfs"`
-	TLS           clientConfigTLS        `mapstructure:"tls"`
-	QUIC          clientConfigQUIC       `mapstructure:"quic"`
-	Mimic         mimicConfig            `mapstructure:"mimic"`
-	Congestion    clientConfigCongestion `mapstructure:"congestion"`
-	Bandwidth     clientConfigBandwidth  `mapstructure:"bandwidth"`
-	FastOpen      bool                   `mapstructure:"fastOpen"`
-	Lazy          bool                   `mapstructure:"lazy"`
-	SOCKS5        *socks5Config          `mapstructure:"socks5"`
-	HTTP          *httpConfig            `mapstructure:"http"`
-	TCPForwarding []tcpForwardingEntry   `mapstructure:"tcpForwarding"`
-	UDPForwarding []udpForwardingEntry   `mapstructure:"udpForwarding"`
-	TCPTProxy     *tcpTProxyConfig       `mapstructure:"tcpTProxy"`
-	UDPTProxy     *udpTProxyConfig       `mapstructure:"udpTProxy"`
-	TCPRedirect   *tcpRedirectConfig     `mapstructure:"tcpRedirect"`
-	TUN           *tunConfig             `mapstructure:"tun"`
+	Server          string                      `mapstructure:"server"`
+	Servers         []clientConfigServer        `mapstructure:"servers"`
+	ServerSelection clientConfigServerSelection `mapstructure:"serverSelection"`
+	Auth            string                      `mapstructure:"auth"`
+	Realm           clientConfigRealm           `mapstructure:"realm"`
+	Transport       clientConfigTransport       `mapstructure:"transport"`
+	Obfs            clientConfigObfs            `mapstructure:"obfs"`
+	TLS             clientConfigTLS             `mapstructure:"tls"`
+	QUIC            clientConfigQUIC            `mapstructure:"quic"`
+	Mimic           mimicConfig                 `mapstructure:"mimic"`
+	Congestion      clientConfigCongestion      `mapstructure:"congestion"`
+	Bandwidth       clientConfigBandwidth       `mapstructure:"bandwidth"`
+	FastOpen        bool                        `mapstructure:"fastOpen"`
+	Lazy            bool                        `mapstructure:"lazy"`
+	Reconnect       clientConfigReconnect       `mapstructure:"reconnect"`
+	ACL             clientConfigACL             `mapstructure:"acl"`
+	SOCKS5          *socks5Config               `mapstructure:"socks5"`
+	HTTP            *httpConfig                 `mapstructure:"http"`
+	TCPForwarding   []tcpForwardingEntry        `mapstructure:"tcpForwarding"`
+	UDPForwarding   []udpForwardingEntry        `mapstructure:"udpForwarding"`
+	TCPTProxy       *tcpTProxyConfig            `mapstructure:"tcpTProxy"`
+	UDPTProxy       *udpTProxyConfig            `mapstructure:"udpTProxy"`
+	TCPRedirect     *tcpRedirectConfig          `mapstructure:"tcpRedirect"`
+	TUN             *tunConfig                  `mapstructure:"tun"`
+	API             clientConfigAPI             `mapstructure:"api"`
+	Log             clientConfigLog             `mapstructure:"log"`
+}
+
+// clientConfigServer is an entry of the servers list. Sections that are
+// left empty are taken from the top level of the config.
+type clientConfigServer struct {
+	Server    string                `mapstructure:"server"`
+	Auth      string                `mapstructure:"auth"`
+	Transport clientConfigTransport `mapstructure:"transport"`
+	Obfs      clientConfigObfs      `mapstructure:"obfs"`
+	TLS       clientConfigTLS       `mapstructure:"tls"`
+}
+
+type clientConfigServerSelection struct {
+	Policy              string        `mapstructure:"policy"`
+	HealthCheckInterval time.Duration `mapstructure:"healthCheckInterval"`
+}
+
+type clientConfigReconnect struct {
+	InitialDelay time.Duration `mapstructure:"initialDelay"`
+	MaxDelay     time.Duration `mapstructure:"maxDelay"`
+	Multiplier   float64       `mapstructure:"multiplier"`
+	Jitter       *float64      `mapstructure:"jitter"` // nil for the default, 0 to disable
+}
+
+func (c clientConfigReconnect) validate() error {
+	if c.InitialDelay < 0 {
+		return configError{Field: "reconnect.initialDelay", Err: errors.New("must not be negative")}
+	}
+	if c.MaxDelay != 0 && c.MaxDelay < c.InitialDelay {
+		return configError{Field: "reconnect.maxDelay", Err: errors.New("must not be less than initialDelay")}
+	}
+	if c.Multiplier != 0 && c.Multiplier < 1 {
+		return configError{Field: "reconnect.multiplier", Err: errors.New("must be at least 1")}
+	}
+	if c.Jitter != nil && (*c.Jitter < 0 || *c.Jitter > 1) {
+		return configError{Field: "reconnect.jitter", Err: errors.New("must be between 0 and 1")}
+	}
+	return nil
+}
+
+type clientConfigAPI struct {
+	Listen string `mapstructure:"listen"`
+	Secret string `mapstructure:"secret"`
+}
+
+// validate requires a secret unless the API only listens on loopback,
+// as anyone who can reach it can close and reconnect the client.
+func (c clientConfigAPI) validate() error {
+	if c.Listen == "" || c.Secret != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return configError{Field: "api.listen", Err: err}
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.IsLoopback() {
+		return nil
+	}
+	return configError{Field: "api.secret", Err: errors.New("required unless api.listen is a loopback address")}
+}
+
+type clientConfigACL struct {
+	File              string        `mapstructure:"file"`
+	Inline            []string      `mapstructure:"inline"`
+	GeoIP             string        `mapstructure:"geoip"`
+	GeoSite           string        `mapstructure:"geosite"`
+	GeoUpdateInterval time.Duration `mapstructure:"geoUpdateInterval"`
+	Resolve           bool          `mapstructure:"resolve"`
 }
 
 type mimicConfig struct {
@@ -171,9 +251,19 @@ type clientConfigQUIC struct {
 	KeepAlivePeriod             time.Duration            `mapstructure:"keepAlivePeriod"`
 	DisablePathMTUDiscovery     bool                     `mapstructure:"disablePathMTUDiscovery"`
 	DisableChromeParrot         bool                     `mapstructure:"disableChromeParrot"`
+	Disable0RTT                 bool                     `mapstructure:"disable0RTT"`
+	EnableMigration             bool                     `mapstructure:"enableMigration"`
+	PoolSize                    int                      `mapstructure:"poolSize"`
+	Health                      clientConfigQUICHealth   `mapstructure:"health"`
 	Sockopts                    clientConfigQUICSockopts `mapstructure:"sockopts"`
 }
 
+type clientConfigQUICHealth struct {
+	Enable   bool          `mapstructure:"enable"`
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
 type clientConfigQUICSockopts struct {
 	BindInterface       *string `mapstructure:"bindInterface"`
 	FirewallMark        *uint32 `mapstructure:"fwmark"`
@@ -196,37 +286,44 @@ type socks5Config struct {
 	Username   string `mapstructure:"username"`
 	Password   string `mapstructure:"password"`
 	DisableUDP bool   `mapstructure:"disableUDP"`
+	DisableACL bool   `mapstructure:"disableACL"`
 }
 
 type httpConfig struct {
-	Listen   string `mapstructure:"listen"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	Realm    string `mapstructure:"realm"`
+	Listen     string `mapstructure:"listen"`
+	Username   string `mapstructure:"username"`
+	Password   string `mapstructure:"password"`
+	Realm      string `mapstructure:"realm"`
+	DisableACL bool   `mapstructure:"disableACL"`
 }
 
 type tcpForwardingEntry struct {
-	Listen string `mapstructure:"listen"`
-	Remote string `mapstructure:"remote"`
+	Listen     string `mapstructure:"listen"`
+	Remote     string `mapstructure:"remote"`
+	DisableACL bool   `mapstructure:"disableACL"`
 }
 
 type udpForwardingEntry struct {
-	Listen  string        `mapstructure:"listen"`
-	Remote  string        `mapstructure:"remote"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	Listen     string        `mapstructure:"listen"`
+	Remote     string        `mapstructure:"remote"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	DisableACL bool          `mapstructure:"disableACL"`
 }
 
 type tcpTProxyConfig struct {
-	Listen string `mapstructure:"listen"`
+	Listen     string `mapstructure:"listen"`
+	DisableACL bool   `mapstructure:"disableACL"`
 }
 
 type udpTProxyConfig struct {
-	Listen  string        `mapstructure:"listen"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	Listen     string        `mapstructure:"listen"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	DisableACL bool          `mapstructure:"disableACL"`
 }
 
 type tcpRedirectConfig struct {
-	Listen string `mapstructure:"listen"`
+	Listen     string `mapstructure:"listen"`
+	DisableACL bool   `mapstructure:"disableACL"`
 }
 
 type tunConfig struct {
@@ -244,6 +341,9 @@ type tunConfig struct {
 		IPv4Exclude []string `mapstructure:"ipv4Exclude"`
 		IPv6Exclude []string `mapstructure:"ipv6Exclude"`
 	} `mapstructure:"route"`
+	// ACL is opt-in for TUN: direct connections use ordinary sockets, which
+	// auto route sends straight back into the interface unless excluded.
+	ACL bool `mapstructure:"acl"`
 }
 
 func (c *clientConfig) fillServerAddr(hyConfig *client.Config) error {
@@ -456,9 +556,91 @@ func (c *clientConfig) validateMimic() error {
 	if isPortHoppingPort(port) {
 		return configError{Field: "mimic", Err: errors.New("cannot be used with port hopping")}
 	}
+	// Mimic is attached to one interface, there is nowhere to migrate to
+	if c.QUIC.EnableMigration {
+		return configError{Field: "quic.enableMigration", Err: errors.New("cannot be used with mimic")}
+	}
 	return nil
 }
 
+func (c clientConfigACL) enabled() bool {
+	return c.File != "" || len(c.Inline) > 0
+}
+
+// validate checks the rules without compiling them,
+// which is done on first use to avoid loading the GeoIP/GeoSite databases early.
+func (c clientConfigACL) validate() error {
+	if c.File != "" && len(c.Inline) > 0 {
+		return configError{Field: "acl", Err: errors.New("cannot set both acl.file and acl.inline")}
+	}
+	if c.File != "" {
+		bs, err := os.ReadFile(c.File)
+		if err != nil {
+			return configError{Field: "acl.file", Err: err}
+		}
+		if err := routing.CheckRules(string(bs)); err != nil {
+			return configError{Field: "acl.file", Err: err}
+		}
+	} else if len(c.Inline) > 0 {
+		if err := routing.CheckRules(strings.Join(c.Inline, "\n")); err != nil {
+			return configError{Field: "acl.inline", Err: err}
+		}
+	}
+	return nil
+}
+
+func (c clientConfigACL) ruleSet() (routing.RuleSet, error) {
+	gLoader := &utils.GeoLoader{
+		GeoIPFilename:   c.GeoIP,
+		GeoSiteFilename: c.GeoSite,
+		UpdateInterval:  c.GeoUpdateInterval,
+		DownloadFunc:    geoDownloadFunc,
+		DownloadErrFunc: geoDownloadErrFunc,
+	}
+	if c.File != "" {
+		rs, err := routing.NewRuleSetFromFile(c.File, gLoader)
+		if err != nil {
+			return nil, configError{Field: "acl.file", Err: err}
+		}
+		return rs, nil
+	}
+	rs, err := routing.NewRuleSetFromString(strings.Join(c.Inline, "\n"), gLoader)
+	if err != nil {
+		return nil, configError{Field: "acl.inline", Err: err}
+	}
+	return rs, nil
+}
+
+// clientRouter compiles the acl section on first use,
+// so that all the modes routing through it share a single rule set.
+type clientRouter struct {
+	Config clientConfigACL
+
+	once    sync.Once
+	ruleSet routing.RuleSet
+	err     error
+}
+
+// Client returns hyClient wrapped in a router that applies the rules,
+// or hyClient itself if there are none or the mode has disableACL set.
+func (r *clientRouter) Client(hyClient client.Client, disableACL bool) (client.Client, error) {
+	if disableACL || !r.Config.enabled() {
+		return hyClient, nil
+	}
+	r.once.Do(func() {
+		r.ruleSet, r.err = r.Config.ruleSet()
+	})
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &routing.Router{
+		HyClient:    hyClient,
+		RuleSet:     r.ruleSet,
+		Resolve:     r.Config.Resolve,
+		EventLogger: &routingLogger{},
+	}, nil
+}
+
 func (c *clientConfig) fillQUICConfig(hyConfig *client.Config) error {
 	hyConfig.QUICConfig = client.QUICConfig{
 		InitialStreamReceiveWindow:     c.QUIC.InitStreamReceiveWindow,
@@ -469,10 +651,29 @@ func (c *clientConfig) fillQUICConfig(hyConfig *client.Config) error {
 		KeepAlivePeriod:                c.QUIC.KeepAlivePeriod,
 		DisablePathMTUDiscovery:        c.QUIC.DisablePathMTUDiscovery,
 		DisableChromeParrot:            c.QUIC.DisableChromeParrot,
+		Disable0RTT:                    c.QUIC.Disable0RTT,
+		EnableMigration:                c.QUIC.EnableMigration,
 		// Mimic rewrites packets after they leave the socket, which corrupts
 		// every segment but the first of a GSO batch.
 		DisableGSO: c.Mimic.Enabled,
 	}
+	if c.QUIC.PoolSize < 0 {
+		return configError{Field: "quic.poolSize", Err: errors.New("must not be negative")}
+	}
+	hyConfig.PoolSize = c.QUIC.PoolSize
+	if c.QUIC.Health.Enable {
+		if c.QUIC.Health.Interval != 0 && c.QUIC.Health.Interval < time.Second {
+			return configError{Field: "quic.health.interval", Err: errors.New("must be at least 1s")}
+		}
+		if c.QUIC.Health.Timeout != 0 && c.QUIC.Health.Timeout <= c.QUIC.Health.Interval {
+			return configError{Field: "quic.health.timeout", Err: errors.New("must be greater than interval")}
+		}
+	}
+	hyConfig.HealthConfig = client.HealthConfig{
+		Enable:   c.QUIC.Health.Enable,
+		Interval: c.QUIC.Health.Interval,
+		Timeout:  c.QUIC.Health.Timeout,
+	}
 	return nil
 }
 
@@ -527,6 +728,9 @@ func (c *clientConfig) fillFastOpen(hyConfig *client.Config) error {
 // - TLS insecure
 // - TLS pinned SHA256 hash (normalized)
 func (c *clientConfig) URI() string {
+	if len(c.Servers) > 0 {
+		return c.serverConfigs()[0].URI()
+	}
 	q := url.Values{}
 	switch strings.ToLower(c.Obfs.Type) {
 	case "salamander":
@@ -620,8 +824,12 @@ func (c *clientConfig) parseURI() bool {
 	return true
 }
 
-// Config validates the fields and returns a ready-to-use Hysteria client config
+// Config validates the fields and returns a ready-to-use Hysteria client config.
+// With a servers list, that is the config of the first server.
 func (c *clientConfig) Config() (*client.Config, error) {
+	if len(c.Servers) > 0 {
+		return c.serverConfigs()[0].Config()
+	}
 	if realmAddr, ok, err := c.parseRealmAddr(); ok || err != nil {
 		if err != nil {
 			return nil, configError{Field: "server", Err: err}
@@ -648,6 +856,182 @@ func (c *clientConfig) Config() (*client.Config, error) {
 	return hyConfig, nil
 }
 
+// validate checks the config the same way Config does, except that for realm
+// addresses it does not open sockets or contact the rendezvous server.
+func (c *clientConfig) validate() error {
+	if err := c.Log.validate(); err != nil {
+		return err
+	}
+	if err := c.validateMimic(); err != nil {
+		return err
+	}
+	if err := c.ACL.validate(); err != nil {
+		return err
+	}
+	if err := c.Reconnect.validate(); err != nil {
+		return err
+	}
+	if err := c.API.validate(); err != nil {
+		return err
+	}
+	if err := c.validateServers(); err != nil {
+		return err
+	}
+	for i, sc := range c.serverConfigs() {
+		if err := sc.validateServer(); err != nil {
+			if ce, ok := err.(configError); ok && len(c.Servers) > 0 {
+				ce.Field = fmt.Sprintf("servers[%d].%s", i, ce.Field)
+				err = ce
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// validateServer does the server part of validate for a single server.
+func (c *clientConfig) validateServer() error {
+	if _, ok, err := c.parseRealmAddr(); ok || err != nil {
+		if err != nil {
+			return configError{Field: "server", Err: err}
+		}
+		hyConfig := &client.Config{}
+		for _, f := range c.realmFillers() {
+			if err := f(hyConfig); err != nil {
+				return err
+			}
+		}
+		if _, _, err := realmIPMode(c.Realm.IPMode); err != nil {
+			return configError{Field: "realm.ipMode", Err: err}
+		}
+		return nil
+	}
+	_, err := c.Config()
+	return err
+}
+
+// realmFillers are the fillers that apply in realm mode, where the server
+// address and connection factory come from the rendezvous instead.
+func (c *clientConfig) realmFillers() []func(*client.Config) error {
+	return []func(*client.Config) error{
+		c.fillAuth,
+		c.fillTLSConfig,
+		c.fillQUICConfig,
+		c.fillCongestionConfig,
+		c.fillBandwidthConfig,
+		c.fillFastOpen,
+	}
+}
+
+const defaultHealthCheckInterval = 1 * time.Minute
+
+// serverConfigs returns a config for each server to connect to: c itself
+// without a servers list, or otherwise a copy of c per entry, with the
+// sections set in the entry replacing those at the top level.
+func (c *clientConfig) serverConfigs() []*clientConfig {
+	if len(c.Servers) == 0 {
+		return []*clientConfig{c}
+	}
+	configs := make([]*clientConfig, len(c.Servers))
+	for i, s := range c.Servers {
+		sc := *c
+		sc.Servers = nil
+		sc.Server = s.Server
+		if s.Auth != "" {
+			sc.Auth = s.Auth
+		}
+		if !reflect.ValueOf(s.Transport).IsZero() {
+			sc.Transport = s.Transport
+		}
+		if !reflect.ValueOf(s.Obfs).IsZero() {
+			sc.Obfs = s.Obfs
+		}
+		if !reflect.ValueOf(s.TLS).IsZero() {
+			sc.TLS = s.TLS
+		}
+		configs[i] = &sc
+	}
+	return configs
+}
+
+func (c *clientConfig) validateServers() error {
+	if len(c.Servers) == 0 {
+		return nil
+	}
+	if c.Server != "" {
+		return configError{Field: "servers", Err: errors.New("cannot set both server and servers")}
+	}
+	for i, s := range c.Servers {
+		if s.Server == "" {
+			return configError{Field: fmt.Sprintf("servers[%d].server", i), Err: errors.New("empty server address")}
+		}
+	}
+	if _, err := c.serverPolicy(); err != nil {
+		return err
+	}
+	if c.ServerSelection.HealthCheckInterval < 0 {
+		return configError{Field: "serverSelection.healthCheckInterval", Err: errors.New("must not be negative")}
+	}
+	return nil
+}
+
+func (c *clientConfig) serverPolicy() (client.ServerPolicy, error) {
+	switch strings.ToLower(c.ServerSelection.Policy) {
+	case "", "failover":
+		return client.ServerPolicyFailover, nil
+	case "roundrobin", "round-robin":
+		return client.ServerPolicyRoundRobin, nil
+	case "lowestlatency", "lowest-latency", "latency":
+		return client.ServerPolicyLowestLatency, nil
+	default:
+		return 0, configError{Field: "serverSelection.policy", Err: errors.New("unsupported policy")}
+	}
+}
+
+// serverGroup returns the group of servers in the servers list,
+// which starts health checking them in the background.
+func (c *clientConfig) serverGroup() (*client.ServerGroup, error) {
+	policy, err := c.serverPolicy()
+	if err != nil {
+		return nil, err
+	}
+	interval := c.ServerSelection.HealthCheckInterval
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	configs := c.serverConfigs()
+	configFuncs := make([]func() (*client.Config, error), len(configs))
+	for i, sc := range configs {
+		configFuncs[i] = sc.Config
+	}
+	return client.NewServerGroup(configFuncs, policy, interval), nil
+}
+
+// newReconnectableClient creates the client for this config,
+// with failover between servers if a servers list is configured.
+func (c *clientConfig) newReconnectableClient(
+	connectedFunc func(client.Client, *client.HandshakeInfo, int),
+	disconnectedFunc func(client.Client, error),
+) (client.ReconnectableClient, error) {
+	var group *client.ServerGroup
+	if len(c.Servers) == 0 {
+		group = client.NewServerGroup([]func() (*client.Config, error){c.Config}, client.ServerPolicyFailover, 0)
+	} else {
+		var err error
+		group, err = c.serverGroup()
+		if err != nil {
+			return nil, err
+		}
+	}
+	reconnectConfig := client.ReconnectConfig{
+		InitialDelay: c.Reconnect.InitialDelay,
+		MaxDelay:     c.Reconnect.MaxDelay,
+		Multiplier:   c.Reconnect.Multiplier,
+		Jitter:       c.Reconnect.Jitter,
+	}
+	return client.NewReconnectableClientGroup(group, reconnectConfig, connectedFunc, disconnectedFunc, c.Lazy)
+}
+
 func (c *clientConfig) parseRealmAddr() (*realm.Addr, bool, error) {
 	addr, err := realm.ParseAddr(c.Server)
 	if err == nil {
@@ -665,15 +1049,7 @@ func (c *clientConfig) realmConfig(addr *realm.Addr) (*client.Config, error) {
 		zap.String("realmServer", addr.HostPort),
 		zap.String("scheme", addr.RendezvousScheme))
 	hyConfig := &client.Config{}
-	fillers := []func(*client.Config) error{
-		c.fillAuth,
-		c.fillTLSConfig,
-		c.fillQUICConfig,
-		c.fillCongestionConfig,
-		c.fillBandwidthConfig,
-		c.fillFastOpen,
-	}
-	for _, f := range fillers {
+	for _, f := range c.realmFillers() {
 		if err := f(hyConfig); err != nil {
 			return nil, err
 		}
@@ -832,28 +1208,28 @@ func (c *clientConfig) realmHTTPClient() *stdHTTP.Client {
 	return &stdHTTP.Client{Transport: tr}
 }
 
-func StartFromJSON(json string) {
+// StartFromJSON runs the client until it receives SIGINT/SIGTERM or one of its modes fails.
+// Failures are returned as *ClientError instead of exiting the process.
+// sink receives the client's events, it can be nil.
+func StartFromJSON(json string, sink EventSink) error {
 	// client mode
 	InitLogger()
 
 	logger.Info("client mode")
 
-	if err := viper.ReadConfigJSON(bytes.NewReader([]byte(json))); err != nil {
-		logger.Error("failed to read client config", zap.Error(err))
-		// Xray-core: Configuration error
-		os.Exit(23)
+	config, err := parseClientJSON(json)
+	if err != nil {
+		return err
 	}
-	var config clientConfig
-	if err := viper.Unmarshal(&config); err != nil {
-		logger.Error("failed to parse client config", zap.Error(err))
-		// Xray-core: Configuration error
-		os.Exit(23)
+	if err := startClientLog(config.Log); err != nil {
+		return newClientError(ErrorCategoryConfig, err)
 	}
+	defer stopClientLog()
 
-	c, err := client.NewReconnectableClient(
-		config.Config,
+	c, err := config.newReconnectableClient(
 		func(c client.Client, info *client.HandshakeInfo, count int) {
 			connectLog(info, count)
+			emitEvent(sink, connectedEvent(info, count))
 			// On the client side, we start checking for updates after we successfully connect
 			// to the server, which, depending on whether lazy mode is enabled, may or may not
 			// be immediately after the client starts. We don't want the update check request
@@ -861,12 +1237,20 @@ func StartFromJSON(json string) {
 			//if count == 1 && !disableUpdateCheck {
 			//	go runCheckUpdateClient(c)
 			//}
-		}, config.Lazy,
+		},
+		func(c client.Client, err error) {
+			emitEvent(sink, disconnectedEvent(err))
+		},
 	)
 	if err != nil {
-		logger.Fatal("failed to initialize client", zap.Error(err))
+		logger.Error("failed to initialize client", zap.Error(err))
+		return newClientError(ErrorCategoryConnect, err)
 	}
 	defer c.Close()
+	c.SetMessageFunc(func(msg client.ControlMessage) {
+		messageLog(msg)
+		emitEvent(sink, messageEvent(msg))
+	})
 
 	uri := config.URI()
 	if showQR {
@@ -877,47 +1261,9 @@ func StartFromJSON(json string) {
 	}
 
 	// Register modes
-	var runner clientModeRunner
-	if config.SOCKS5 != nil {
-		runner.Add("SOCKS5 server", func() error {
-			return clientSOCKS5(*config.SOCKS5, c)
-		})
-	}
-	if config.HTTP != nil {
-		runner.Add("HTTP proxy server", func() error {
-			return clientHTTP(*config.HTTP, c)
-		})
-	}
-	if len(config.TCPForwarding) > 0 {
-		runner.Add("TCP forwarding", func() error {
-			return clientTCPForwarding(config.TCPForwarding, c)
-		})
-	}
-	if len(config.UDPForwarding) > 0 {
-		runner.Add("UDP forwarding", func() error {
-			return clientUDPForwarding(config.UDPForwarding, c)
-		})
-	}
-	if config.TCPTProxy != nil {
-		runner.Add("TCP transparent proxy", func() error {
-			return clientTCPTProxy(*config.TCPTProxy, c)
-		})
-	}
-	if config.UDPTProxy != nil {
-		runner.Add("UDP transparent proxy", func() error {
-			return clientUDPTProxy(*config.UDPTProxy, c)
-		})
-	}
-	if config.TCPRedirect != nil {
-		runner.Add("TCP redirect", func() error {
-			return clientTCPRedirect(*config.TCPRedirect, c)
-		})
-	}
-	if config.TUN != nil {
-		runner.Add("TUN", func() error {
-			return clientTUN(*config.TUN, c)
-		})
-	}
+	runner := clientModeRunner{Events: sink}
+	config.addModes(&runner, c)
+	defer runner.Close()
 
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
@@ -935,14 +1281,11 @@ func StartFromJSON(json string) {
 		if r.OK {
 			logger.Info(r.Msg)
 		} else {
-			_ = c.Close() // Close the client here as Fatal will exit the program without running defer
-			if r.Err != nil {
-				logger.Fatal(r.Msg, zap.Error(r.Err))
-			} else {
-				logger.Fatal(r.Msg)
-			}
+			logger.Error(r.Msg, zap.Error(r.Err))
+			return r.clientError()
 		}
 	}
+	return nil
 }
 
 func runClientCmd(cmd *cobra.Command, args []string) {
@@ -951,13 +1294,12 @@ func runClientCmd(cmd *cobra.Command, args []string) {
 }
 
 func runClient(v *viper.Viper) {
-	if err := v.ReadInConfig(); err != nil {
+	cfg, err := readClientConfig(v)
+	if err != nil {
 		logger.Fatal("failed to read client config", zap.Error(err))
 	}
-	var config clientConfig
-	if err := v.Unmarshal(&config); err != nil {
-		logger.Fatal("failed to parse client config", zap.Error(err))
-	}
+	config := *cfg
+	running := config // as parsed, for diffing on reload
 
 	if err := config.validateMimic(); err != nil {
 		logger.Fatal("failed to load client config", zap.Error(err))
@@ -965,8 +1307,7 @@ func runClient(v *viper.Viper) {
 	mimicInst := config.startMimic()
 	defer mimicInst.Close()
 
-	c, err := client.NewReconnectableClient(
-		config.Config,
+	c, err := config.newReconnectableClient(
 		func(c client.Client, info *client.HandshakeInfo, count int) {
 			connectLog(info, count)
 			// On the client side, we start checking for updates after we successfully connect
@@ -976,12 +1317,13 @@ func runClient(v *viper.Viper) {
 			if count == 1 && !disableUpdateCheck {
 				go runCheckUpdateClient(c)
 			}
-		}, config.Lazy,
+		}, nil,
 	)
 	if err != nil {
 		logger.Fatal("failed to initialize client", zap.Error(err))
 	}
 	defer c.Close()
+	c.SetMessageFunc(messageLog)
 
 	uri := config.URI()
 	if showQR {
@@ -993,75 +1335,192 @@ func runClient(v *viper.Viper) {
 
 	// Register modes
 	var runner clientModeRunner
-	if config.SOCKS5 != nil {
-		runner.Add("SOCKS5 server", func() error {
-			return clientSOCKS5(*config.SOCKS5, c)
+	config.addModes(&runner, c)
+	defer runner.Close()
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
+
+	runnerChan := make(chan clientModeRunnerResult, 1)
+	go func() {
+		runnerChan <- runner.Run()
+	}()
+
+	for {
+		select {
+		case <-reloadChan:
+			logger.Info("received SIGHUP, reloading config")
+			next, err := readClientConfig(v)
+			if err == nil {
+				err = reloadClient(running, *next, c, &runner)
+			}
+			if err != nil {
+				logger.Error("failed to reload client config", zap.Error(err))
+				continue
+			}
+			running = *next
+			logger.Info("client config reloaded")
+		case <-signalChan:
+			logger.Info("received signal, shutting down gracefully")
+			return
+		case r := <-runnerChan:
+			if r.OK {
+				logger.Info(r.Msg)
+			} else {
+				_ = c.Close() // Close the client here as Fatal will exit the program without running defer
+				if r.Err != nil {
+					logger.Fatal(r.Msg, zap.Error(r.Err))
+				} else {
+					logger.Fatal(r.Msg)
+				}
+			}
+			return
+		}
+	}
+}
+
+// addModes registers every inbound mode enabled in the config with the runner.
+func (c *clientConfig) addModes(runner *clientModeRunner, hyClient client.ReconnectableClient) {
+	for _, m := range c.modes(hyClient, runner.Events) {
+		runner.Add(m)
+	}
+}
+
+// modes returns every inbound mode enabled in the config, keyed by its config section.
+func (c *clientConfig) modes(hyClient client.ReconnectableClient, sink EventSink) []*clientMode {
+	var modes []*clientMode
+	router := &clientRouter{Config: c.ACL}
+	clients := func(disableACL bool) (client.Client, error) {
+		return router.Client(hyClient, disableACL)
+	}
+	add := func(key, name string, config any, useACL bool, run func(closers *utils.CloseGroup) error) {
+		m := &clientMode{Key: key, Name: name, Config: config, Run: run}
+		if useACL {
+			m.ACL = c.ACL
+		}
+		modes = append(modes, m)
+	}
+	if c.SOCKS5 != nil {
+		config := *c.SOCKS5
+		add("socks5", "SOCKS5 server", config, !config.DisableACL, func(closers *utils.CloseGroup) error {
+			hc, err := clients(config.DisableACL)
+			if err != nil {
+				return err
+			}
+			return clientSOCKS5(config, hc, closers, sink)
 		})
 	}
-	if config.HTTP != nil {
-		runner.Add("HTTP proxy server", func() error {
-			return clientHTTP(*config.HTTP, c)
+	if c.HTTP != nil {
+		config := *c.HTTP
+		add("http", "HTTP proxy server", config, !config.DisableACL, func(closers *utils.CloseGroup) error {
+			hc, err := clients(config.DisableACL)
+			if err != nil {
+				return err
+			}
+			return clientHTTP(config, hc, closers, sink)
 		})
 	}
-	if len(config.TCPForwarding) > 0 {
-		runner.Add("TCP forwarding", func() error {
-			return clientTCPForwarding(config.TCPForwarding, c)
+	if len(c.TCPForwarding) > 0 {
+		entries := c.TCPForwarding
+		useACL := slices.ContainsFunc(entries, func(e tcpForwardingEntry) bool { return !e.DisableACL })
+		add("tcpForwarding", "TCP forwarding", entries, useACL, func(closers *utils.CloseGroup) error {
+			return clientTCPForwarding(entries, clients, closers, sink)
 		})
 	}
-	if len(config.UDPForwarding) > 0 {
-		runner.Add("UDP forwarding", func() error {
-			return clientUDPForwarding(config.UDPForwarding, c)
+	if len(c.UDPForwarding) > 0 {
+		entries := c.UDPForwarding
+		useACL := slices.ContainsFunc(entries, func(e udpForwardingEntry) bool { return !e.DisableACL })
+		add("udpForwarding", "UDP forwarding", entries, useACL, func(closers *utils.CloseGroup) error {
+			return clientUDPForwarding(entries, clients, closers, sink)
 		})
 	}
-	if config.TCPTProxy != nil {
-		runner.Add("TCP transparent proxy", func() error {
-			return clientTCPTProxy(*config.TCPTProxy, c)
+	if c.TCPTProxy != nil {
+		config := *c.TCPTProxy
+		add("tcpTProxy", "TCP transparent proxy", config, !config.DisableACL, func(closers *utils.CloseGroup) error {
+			hc, err := clients(config.DisableACL)
+			if err != nil {
+				return err
+			}
+			return clientTCPTProxy(config, hc, closers, sink)
 		})
 	}
-	if config.UDPTProxy != nil {
-		runner.Add("UDP transparent proxy", func() error {
-			return clientUDPTProxy(*config.UDPTProxy, c)
+	if c.UDPTProxy != nil {
+		config := *c.UDPTProxy
+		add("udpTProxy", "UDP transparent proxy", config, !config.DisableACL, func(closers *utils.CloseGroup) error {
+			hc, err := clients(config.DisableACL)
+			if err != nil {
+				return err
+			}
+			return clientUDPTProxy(config, hc, closers, sink)
 		})
 	}
-	if config.TCPRedirect != nil {
-		runner.Add("TCP redirect", func() error {
-			return clientTCPRedirect(*config.TCPRedirect, c)
+	if c.TCPRedirect != nil {
+		config := *c.TCPRedirect
+		add("tcpRedirect", "TCP redirect", config, !config.DisableACL, func(closers *utils.CloseGroup) error {
+			hc, err := clients(config.DisableACL)
+			if err != nil {
+				return err
+			}
+			return clientTCPRedirect(config, hc, closers, sink)
 		})
 	}
-	if config.TUN != nil {
-		runner.Add("TUN", func() error {
-			return clientTUN(*config.TUN, c)
+	if c.TUN != nil {
+		config := *c.TUN
+		add("tun", "TUN", config, config.ACL, func(closers *utils.CloseGroup) error {
+			hc, err := clients(!config.ACL)
+			if err != nil {
+				return err
+			}
+			return clientTUN(config, hc, closers, sink)
 		})
 	}
+	if c.API.Listen != "" {
+		config := c.API
+		add("api", "client API server", config, false, func(closers *utils.CloseGroup) error {
+			return clientAPI(config, hyClient, closers, sink)
+		})
+	}
+	return modes
+}
 
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
-	defer signal.Stop(signalChan)
+// clientMode is a single inbound mode, run and stopped independently of the others.
+type clientMode struct {
+	Key    string // config key, also reported as Event.Mode
+	Name   string
+	Config any             // the mode's config section, compared on reload
+	ACL    clientConfigACL // the acl section if the mode routes through it, also compared
+	Run    func(closers *utils.CloseGroup) error
 
-	runnerChan := make(chan clientModeRunnerResult, 1)
-	go func() {
-		runnerChan <- runner.Run()
-	}()
-
-	select {
-	case <-signalChan:
-		logger.Info("received signal, shutting down gracefully")
-	case r := <-runnerChan:
-		if r.OK {
-			logger.Info(r.Msg)
-		} else {
-			_ = c.Close() // Close the client here as Fatal will exit the program without running defer
-			if r.Err != nil {
-				logger.Fatal(r.Msg, zap.Error(r.Err))
-			} else {
-				logger.Fatal(r.Msg)
-			}
-		}
-	}
+	// closers holds the listeners opened by Run,
+	// closing it makes Run return.
+	closers utils.CloseGroup
+	stopped atomic.Bool // closed on purpose, Run returning is not a failure
+	done    chan struct{}
+	err     error // returned by Run, set before done is closed
 }
 
+// clientModeStartTimeout is how long Sync waits for a mode it has started to fail.
+const clientModeStartTimeout = 500 * time.Millisecond
+
 type clientModeRunner struct {
-	ModeMap map[string]func() error
+	// Events receives listener and per-request events from the modes, can be nil.
+	Events EventSink
+
+	mutex   sync.Mutex
+	modes   map[string]*clientMode
+	started bool
+	closed  bool
+	results chan clientModeResult
+	quit    chan struct{}
+}
+
+type clientModeResult struct {
+	Mode *clientMode
+	Err  error
 }
 
 type clientModeRunnerResult struct {
@@ -1070,43 +1529,236 @@ type clientModeRunnerResult struct {
 	Err error
 }
 
-func (r *clientModeRunner) Add(name string, f func() error) {
-	if r.ModeMap == nil {
-		r.ModeMap = make(map[string]func() error)
+// clientError converts a failed result into the error reported to embedders.
+func (r clientModeRunnerResult) clientError() *ClientError {
+	if r.OK {
+		return nil
+	}
+	if r.Err == nil {
+		return &ClientError{Category: ErrorCategoryRuntime, Message: r.Msg}
+	}
+	e := newClientError(ErrorCategoryRuntime, r.Err)
+	e.Message = r.Msg + ": " + e.Message
+	return e
+}
+
+// init must be called with r.mutex held.
+func (r *clientModeRunner) init() {
+	if r.modes == nil {
+		r.modes = make(map[string]*clientMode)
+		r.results = make(chan clientModeResult)
+		r.quit = make(chan struct{})
 	}
-	r.ModeMap[name] = f
 }
 
+// Add registers a mode, replacing any mode with the same key.
+// If the runner is already running, the mode is started right away.
+func (r *clientModeRunner) Add(m *clientMode) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.init()
+	if old := r.modes[m.Key]; old != nil {
+		r.stop(old)
+	}
+	r.modes[m.Key] = m
+	if r.started && !r.closed {
+		r.start(m)
+	}
+}
+
+// Len returns the number of registered modes.
+func (r *clientModeRunner) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.modes)
+}
+
+// start runs m in a new goroutine. Must be called with r.mutex held.
+func (r *clientModeRunner) start(m *clientMode) {
+	m.done = make(chan struct{})
+	go func() {
+		err := m.Run(&m.closers)
+		m.err = err
+		close(m.done)
+		// Sync holds the mutex while it waits for modes to start,
+		// and stops them again if it rolls them back
+		r.mutex.Lock()
+		stopped := m.stopped.Load()
+		r.mutex.Unlock()
+		if stopped {
+			// The matching listenerUp is emitted by the mode itself once it's listening
+			emitEvent(r.Events, Event{Type: EventListenerDown, Mode: m.Key})
+			return
+		}
+		emitEvent(r.Events, Event{Type: EventListenerDown, Mode: m.Key, Error: errorString(err)})
+		select {
+		case r.results <- clientModeResult{m, err}:
+		case <-r.quit:
+		}
+	}()
+}
+
+// stop closes m and waits for it to return. Must be called with r.mutex held.
+func (r *clientModeRunner) stop(m *clientMode) {
+	m.stopped.Store(true)
+	_ = m.closers.Close()
+	if m.done != nil {
+		<-m.done
+	}
+}
+
+// Run starts all registered modes and blocks until one of them fails,
+// all of them have returned on their own, or the runner is closed.
 func (r *clientModeRunner) Run() clientModeRunnerResult {
-	if len(r.ModeMap) == 0 {
+	r.mutex.Lock()
+	r.init()
+	if len(r.modes) == 0 {
+		r.mutex.Unlock()
 		return clientModeRunnerResult{OK: false, Msg: "no mode specified"}
 	}
+	r.started = true
+	if !r.closed {
+		for _, m := range r.modes {
+			r.start(m)
+		}
+	}
+	r.mutex.Unlock()
 
-	type modeError struct {
-		Name string
-		Err  error
+	for {
+		select {
+		case res := <-r.results:
+			// Fatal if any one of the modes fails
+			if res.Err != nil {
+				return clientModeRunnerResult{OK: false, Msg: "failed to run " + res.Mode.Name, Err: res.Err}
+			}
+			r.mutex.Lock()
+			if r.modes[res.Mode.Key] == res.Mode {
+				delete(r.modes, res.Mode.Key)
+			}
+			empty := len(r.modes) == 0
+			r.mutex.Unlock()
+			if empty {
+				// We don't really have any such cases, as currently none of our modes would stop on themselves without error.
+				// But we leave the possibility here for future expansion.
+				return clientModeRunnerResult{OK: true, Msg: "finished without error"}
+			}
+		case <-r.quit:
+			return clientModeRunnerResult{OK: true, Msg: "stopped"}
+		}
+	}
+}
+
+// Sync makes the registered modes match the given ones: modes that are gone
+// or whose config has changed are stopped, new and changed ones are started.
+// Modes whose config is unchanged keep running untouched.
+//
+// If any of the started modes fails within clientModeStartTimeout, for example
+// because its address is in use, all of them are stopped again, the previous
+// modes are restored, and the error is returned.
+func (r *clientModeRunner) Sync(modes []*clientMode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.init()
+	next := make(map[string]*clientMode, len(modes))
+	for _, m := range modes {
+		next[m.Key] = m
+	}
+	var prev []*clientMode
+	for key, m := range r.modes {
+		if n, ok := next[key]; !ok || !reflect.DeepEqual(m.Config, n.Config) || !reflect.DeepEqual(m.ACL, n.ACL) {
+			logger.Info("stopping "+m.Name, zap.String("mode", key))
+			r.stop(m)
+			delete(r.modes, key)
+			prev = append(prev, m)
+		}
+	}
+	running := r.started && !r.closed
+	var started []*clientMode
+	for key, m := range next {
+		if _, ok := r.modes[key]; ok {
+			continue
+		}
+		r.modes[key] = m
+		if running {
+			logger.Info("starting "+m.Name, zap.String("mode", key))
+			r.start(m)
+			started = append(started, m)
+		}
 	}
-	errChan := make(chan modeError, len(r.ModeMap))
-	for name, f := range r.ModeMap {
-		go func(name string, f func() error) {
-			err := f()
-			errChan <- modeError{name, err}
-		}(name, f)
+	if len(started) == 0 {
+		return nil
 	}
-	// Fatal if any one of the modes fails
-	for i := 0; i < len(r.ModeMap); i++ {
-		e := <-errChan
-		if e.Err != nil {
-			return clientModeRunnerResult{OK: false, Msg: "failed to run " + e.Name, Err: e.Err}
+
+	// Binding fails right away, so a mode that is still running by then is taken to have started
+	ctx, cancel := context.WithTimeout(context.Background(), clientModeStartTimeout)
+	defer cancel()
+	var failed *clientMode
+	for _, m := range started {
+		select {
+		case <-m.done:
+			failed = m
+		case <-ctx.Done():
+		}
+		if failed != nil {
+			break
 		}
 	}
+	if failed == nil {
+		return nil
+	}
+	logger.Warn("failed to start "+failed.Name+", restoring the previous modes", zap.String("mode", failed.Key), zap.Error(failed.err))
+	for _, m := range started {
+		r.stop(m)
+		delete(r.modes, m.Key)
+	}
+	for _, m := range prev {
+		// The old ones can't be run again, their closers are closed
+		m = &clientMode{Key: m.Key, Name: m.Name, Config: m.Config, ACL: m.ACL, Run: m.Run}
+		r.modes[m.Key] = m
+		r.start(m)
+	}
+	return fmt.Errorf("failed to run %s: %w", failed.Name, failed.err)
+}
+
+// Close stops all running modes by closing their listeners.
+func (r *clientModeRunner) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.init()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.quit)
+	var errs []error
+	for _, m := range r.modes {
+		m.stopped.Store(true)
+		errs = append(errs, m.closers.Close())
+	}
+	return errors.Join(errs...)
+}
 
-	// We don't really have any such cases, as currently none of our modes would stop on themselves without error.
-	// But we leave the possibility here for future expansion.
-	return clientModeRunnerResult{OK: true, Msg: "finished without error"}
+func clientAPI(config clientConfigAPI, c client.ReconnectableClient, closers *utils.CloseGroup, sink EventSink) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	l, err := correctnet.Listen("tcp", config.Listen)
+	if err != nil {
+		return configError{Field: "api.listen", Err: err}
+	}
+	if err := closers.Add(l); err != nil {
+		return err
+	}
+	s := &clientapi.Server{
+		HyClient: c,
+		Secret:   config.Secret,
+	}
+	logger.Info("client API server listening", zap.String("addr", config.Listen))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "api", Addr: config.Listen})
+	return s.Serve(l)
 }
 
-func clientSOCKS5(config socks5Config, c client.Client) error {
+func clientSOCKS5(config socks5Config, c client.Client, closers *utils.CloseGroup, sink EventSink) error {
 	if config.Listen == "" {
 		return configError{Field: "listen", Err: errors.New("listen address is empty")}
 	}
@@ -1114,6 +1766,9 @@ func clientSOCKS5(config socks5Config, c client.Client) error {
 	if err != nil {
 		return configError{Field: "listen", Err: err}
 	}
+	if err := closers.Add(l); err != nil {
+		return err
+	}
 	var authFunc func(username, password string) bool
 	username, password := config.Username, config.Password
 	if username != "" && password != "" {
@@ -1125,13 +1780,14 @@ func clientSOCKS5(config socks5Config, c client.Client) error {
 		HyClient:    c,
 		AuthFunc:    authFunc,
 		DisableUDP:  config.DisableUDP,
-		EventLogger: &socks5Logger{},
+		EventLogger: &socks5Logger{sink: sink},
 	}
 	logger.Info("SOCKS5 server listening", zap.String("addr", config.Listen))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "socks5", Addr: config.Listen})
 	return s.Serve(l)
 }
 
-func clientHTTP(config httpConfig, c client.Client) error {
+func clientHTTP(config httpConfig, c client.Client, closers *utils.CloseGroup, sink EventSink) error {
 	if config.Listen == "" {
 		return configError{Field: "listen", Err: errors.New("listen address is empty")}
 	}
@@ -1139,6 +1795,9 @@ func clientHTTP(config httpConfig, c client.Client) error {
 	if err != nil {
 		return configError{Field: "listen", Err: err}
 	}
+	if err := closers.Add(l); err != nil {
+		return err
+	}
 	var authFunc func(username, password string) bool
 	username, password := config.Username, config.Password
 	if username != "" && password != "" {
@@ -1153,13 +1812,14 @@ func clientHTTP(config httpConfig, c client.Client) error {
 		HyClient:    c,
 		AuthFunc:    authFunc,
 		AuthRealm:   config.Realm,
-		EventLogger: &httpLogger{},
+		EventLogger: &httpLogger{sink: sink},
 	}
 	logger.Info("HTTP proxy server listening", zap.String("addr", config.Listen))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "http", Addr: config.Listen})
 	return h.Serve(l)
 }
 
-func clientTCPForwarding(entries []tcpForwardingEntry, c client.Client) error {
+func clientTCPForwarding(entries []tcpForwardingEntry, clients func(disableACL bool) (client.Client, error), closers *utils.CloseGroup, sink EventSink) error {
 	errChan := make(chan error, len(entries))
 	for _, e := range entries {
 		if e.Listen == "" {
@@ -1168,11 +1828,19 @@ func clientTCPForwarding(entries []tcpForwardingEntry, c client.Client) error {
 		if e.Remote == "" {
 			return configError{Field: "remote", Err: errors.New("remote address is empty")}
 		}
+		c, err := clients(e.DisableACL)
+		if err != nil {
+			return err
+		}
 		l, err := correctnet.Listen("tcp", e.Listen)
 		if err != nil {
 			return configError{Field: "listen", Err: err}
 		}
+		if err := closers.Add(l); err != nil {
+			return err
+		}
 		logger.Info("TCP forwarding listening", zap.String("addr", e.Listen), zap.String("remote", e.Remote))
+		emitEvent(sink, Event{Type: EventListenerUp, Mode: "tcpForwarding", Addr: e.Listen})
 		go func(remote string) {
 			t := &forwarding.TCPTunnel{
 				HyClient:    c,
@@ -1186,7 +1854,7 @@ func clientTCPForwarding(entries []tcpForwardingEntry, c client.Client) error {
 	return <-errChan
 }
 
-func clientUDPForwarding(entries []udpForwardingEntry, c client.Client) error {
+func clientUDPForwarding(entries []udpForwardingEntry, clients func(disableACL bool) (client.Client, error), closers *utils.CloseGroup, sink EventSink) error {
 	errChan := make(chan error, len(entries))
 	for _, e := range entries {
 		if e.Listen == "" {
@@ -1195,11 +1863,19 @@ func clientUDPForwarding(entries []udpForwardingEntry, c client.Client) error {
 		if e.Remote == "" {
 			return configError{Field: "remote", Err: errors.New("remote address is empty")}
 		}
+		c, err := clients(e.DisableACL)
+		if err != nil {
+			return err
+		}
 		l, err := correctnet.ListenPacket("udp", e.Listen)
 		if err != nil {
 			return configError{Field: "listen", Err: err}
 		}
+		if err := closers.Add(l); err != nil {
+			return err
+		}
 		logger.Info("UDP forwarding listening", zap.String("addr", e.Listen), zap.String("remote", e.Remote))
+		emitEvent(sink, Event{Type: EventListenerUp, Mode: "udpForwarding", Addr: e.Listen})
 		go func(remote string, timeout time.Duration) {
 			u := &forwarding.UDPTunnel{
 				HyClient:    c,
@@ -1214,7 +1890,7 @@ func clientUDPForwarding(entries []udpForwardingEntry, c client.Client) error {
 	return <-errChan
 }
 
-func clientTCPTProxy(config tcpTProxyConfig, c client.Client) error {
+func clientTCPTProxy(config tcpTProxyConfig, c client.Client, closers *utils.CloseGroup, sink EventSink) error {
 	if config.Listen == "" {
 		return configError{Field: "listen", Err: errors.New("listen address is empty")}
 	}
@@ -1226,11 +1902,15 @@ func clientTCPTProxy(config tcpTProxyConfig, c client.Client) error {
 		HyClient:    c,
 		EventLogger: &tcpTProxyLogger{},
 	}
+	if err := closers.Add(p); err != nil {
+		return err
+	}
 	logger.Info("TCP transparent proxy listening", zap.String("addr", config.Listen))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "tcpTProxy", Addr: config.Listen})
 	return p.ListenAndServe(laddr)
 }
 
-func clientUDPTProxy(config udpTProxyConfig, c client.Client) error {
+func clientUDPTProxy(config udpTProxyConfig, c client.Client, closers *utils.CloseGroup, sink EventSink) error {
 	if config.Listen == "" {
 		return configError{Field: "listen", Err: errors.New("listen address is empty")}
 	}
@@ -1243,11 +1923,15 @@ func clientUDPTProxy(config udpTProxyConfig, c client.Client) error {
 		Timeout:     config.Timeout,
 		EventLogger: &udpTProxyLogger{},
 	}
+	if err := closers.Add(p); err != nil {
+		return err
+	}
 	logger.Info("UDP transparent proxy listening", zap.String("addr", config.Listen))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "udpTProxy", Addr: config.Listen})
 	return p.ListenAndServe(laddr)
 }
 
-func clientTCPRedirect(config tcpRedirectConfig, c client.Client) error {
+func clientTCPRedirect(config tcpRedirectConfig, c client.Client, closers *utils.CloseGroup, sink EventSink) error {
 	if config.Listen == "" {
 		return configError{Field: "listen", Err: errors.New("listen address is empty")}
 	}
@@ -1259,11 +1943,15 @@ func clientTCPRedirect(config tcpRedirectConfig, c client.Client) error {
 		HyClient:    c,
 		EventLogger: &tcpRedirectLogger{},
 	}
+	if err := closers.Add(p); err != nil {
+		return err
+	}
 	logger.Info("TCP redirect listening", zap.String("addr", config.Listen))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "tcpRedirect", Addr: config.Listen})
 	return p.ListenAndServe(laddr)
 }
 
-func clientTUN(config tunConfig, c client.Client) error {
+func clientTUN(config tunConfig, c client.Client, closers *utils.CloseGroup, sink EventSink) error {
 	supportedPlatforms := []string{"linux", "darwin", "windows", "android"}
 	if !slices.Contains(supportedPlatforms, runtime.GOOS) {
 		logger.Error("TUN is not supported on this platform", zap.String("platform", runtime.GOOS))
@@ -1294,7 +1982,7 @@ func clientTUN(config tunConfig, c client.Client) error {
 	}
 	server := &tun.Server{
 		HyClient:     c,
-		EventLogger:  &tunLogger{},
+		EventLogger:  &tunLogger{sink: sink},
 		Logger:       logger,
 		IfName:       config.Name,
 		MTU:          config.MTU,
@@ -1345,7 +2033,11 @@ func clientTUN(config tunConfig, c client.Client) error {
 			return err
 		}
 	}
+	if err := closers.Add(server); err != nil {
+		return err
+	}
 	logger.Info("TUN listening", zap.String("interface", config.Name))
+	emitEvent(sink, Event{Type: EventListenerUp, Mode: "tun", Addr: config.Name})
 	return server.Serve()
 }
 
@@ -1452,16 +2144,28 @@ func connectLog(info *client.HandshakeInfo, count int) {
 		zap.Bool("udpEnabled", info.UDPEnabled),
 		zap.Uint64("tx", info.Tx),
 		zap.Bool("ech", info.ECHAccepted),
+		zap.Bool("0rtt", info.Used0RTT),
 		zap.Int("count", count))
 }
 
-type socks5Logger struct{}
+func messageLog(msg client.ControlMessage) {
+	logger.Info("message from server",
+		zap.Stringer("type", msg.Type),
+		zap.Uint64("value", msg.Value),
+		zap.String("text", msg.Text))
+}
+
+type socks5Logger struct {
+	sink EventSink
+}
 
 func (l *socks5Logger) TCPRequest(addr net.Addr, reqAddr string) {
+	emitEvent(l.sink, Event{Type: EventSOCKS5TCPRequest, Addr: addr.String(), ReqAddr: reqAddr})
 	logger.Debug("SOCKS5 TCP request", zap.String("addr", addr.String()), zap.String("reqAddr", reqAddr))
 }
 
 func (l *socks5Logger) TCPError(addr net.Addr, reqAddr string, err error) {
+	emitEvent(l.sink, Event{Type: EventSOCKS5TCPError, Addr: addr.String(), ReqAddr: reqAddr, Error: errorString(err)})
 	if err == nil {
 		logger.Debug("SOCKS5 TCP closed", zap.String("addr", addr.String()), zap.String("reqAddr", reqAddr))
 	} else {
@@ -1470,10 +2174,12 @@ func (l *socks5Logger) TCPError(addr net.Addr, reqAddr string, err error) {
 }
 
 func (l *socks5Logger) UDPRequest(addr net.Addr) {
+	emitEvent(l.sink, Event{Type: EventSOCKS5UDPRequest, Addr: addr.String()})
 	logger.Debug("SOCKS5 UDP request", zap.String("addr", addr.String()))
 }
 
 func (l *socks5Logger) UDPError(addr net.Addr, err error) {
+	emitEvent(l.sink, Event{Type: EventSOCKS5UDPError, Addr: addr.String(), Error: errorString(err)})
 	if err == nil {
 		logger.Debug("SOCKS5 UDP closed", zap.String("addr", addr.String()))
 	} else {
@@ -1481,13 +2187,17 @@ func (l *socks5Logger) UDPError(addr net.Addr, err error) {
 	}
 }
 
-type httpLogger struct{}
+type httpLogger struct {
+	sink EventSink
+}
 
 func (l *httpLogger) ConnectRequest(addr net.Addr, reqAddr string) {
+	emitEvent(l.sink, Event{Type: EventHTTPConnectRequest, Addr: addr.String(), ReqAddr: reqAddr})
 	logger.Debug("HTTP CONNECT request", zap.String("addr", addr.String()), zap.String("reqAddr", reqAddr))
 }
 
 func (l *httpLogger) ConnectError(addr net.Addr, reqAddr string, err error) {
+	emitEvent(l.sink, Event{Type: EventHTTPConnectError, Addr: addr.String(), ReqAddr: reqAddr, Error: errorString(err)})
 	if err == nil {
 		logger.Debug("HTTP CONNECT closed", zap.String("addr", addr.String()), zap.String("reqAddr", reqAddr))
 	} else {
@@ -1496,10 +2206,12 @@ func (l *httpLogger) ConnectError(addr net.Addr, reqAddr string, err error) {
 }
 
 func (l *httpLogger) HTTPRequest(addr net.Addr, reqURL string) {
+	emitEvent(l.sink, Event{Type: EventHTTPRequest, Addr: addr.String(), ReqAddr: reqURL})
 	logger.Debug("HTTP request", zap.String("addr", addr.String()), zap.String("reqURL", reqURL))
 }
 
 func (l *httpLogger) HTTPError(addr net.Addr, reqURL string, err error) {
+	emitEvent(l.sink, Event{Type: EventHTTPError, Addr: addr.String(), ReqAddr: reqURL, Error: errorString(err)})
 	if err == nil {
 		logger.Debug("HTTP closed", zap.String("addr", addr.String()), zap.String("reqURL", reqURL))
 	} else {
@@ -1507,6 +2219,12 @@ func (l *httpLogger) HTTPError(addr net.Addr, reqURL string, err error) {
 	}
 }
 
+type routingLogger struct{}
+
+func (l *routingLogger) Match(network, reqAddr string, outbound routing.Outbound) {
+	logger.Debug("routing match", zap.String("network", network), zap.String("reqAddr", reqAddr), zap.Stringer("outbound", outbound))
+}
+
 type tcpLogger struct{}
 
 func (l *tcpLogger) Connect(addr net.Addr) {
@@ -1577,13 +2295,17 @@ func (l *tcpRedirectLogger) Error(addr, reqAddr net.Addr, err error) {
 	}
 }
 
-type tunLogger struct{}
+type tunLogger struct {
+	sink EventSink
+}
 
 func (l *tunLogger) TCPRequest(addr, reqAddr string) {
+	emitEvent(l.sink, Event{Type: EventTUNTCPRequest, Addr: addr, ReqAddr: reqAddr})
 	logger.Debug("TUN TCP request", zap.String("addr", addr), zap.String("reqAddr", reqAddr))
 }
 
 func (l *tunLogger) TCPError(addr, reqAddr string, err error) {
+	emitEvent(l.sink, Event{Type: EventTUNTCPError, Addr: addr, ReqAddr: reqAddr, Error: errorString(err)})
 	if err == nil {
 		logger.Debug("TUN TCP closed", zap.String("addr", addr), zap.String("reqAddr", reqAddr))
 	} else {
@@ -1592,10 +2314,12 @@ func (l *tunLogger) TCPError(addr, reqAddr string, err error) {
 }
 
 func (l *tunLogger) UDPRequest(addr string) {
+	emitEvent(l.sink, Event{Type: EventTUNUDPRequest, Addr: addr})
 	logger.Debug("TUN UDP request", zap.String("addr", addr))
 }
 
 func (l *tunLogger) UDPError(addr string, err error) {
+	emitEvent(l.sink, Event{Type: EventTUNUDPError, Addr: addr, Error: errorString(err)})
 	if err == nil {
 		logger.Debug("TUN UDP closed", zap.String("addr", addr))
 	} else {
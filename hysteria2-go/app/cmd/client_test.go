@@ -23,7 +23,48 @@ func TestClientConfig(t *testing.T) {
 	assertAllFieldsSet(t, config, "client")
 	assert.Equal(t, config, clientConfig{
 		Server: "example.com",
-		Auth:   "weak_ahh_password",
+		Servers: []clientConfigServer{
+			{
+				Server: "backup1.example.com:443",
+				Auth:   "backup_password",
+				Transport: clientConfigTransport{
+					Type: "udp",
+					UDP: clientConfigTransportUDP{
+						HopInterval:    20 * time.Second,
+						MinHopInterval: 5 * time.Second,
+						MaxHopInterval: 40 * time.Second,
+					},
+				},
+				Obfs: clientConfigObfs{
+					Type: "gecko",
+					Salamander: clientConfigObfsSalamander{
+						Password: "backup_r1ver",
+					},
+					Gecko: clientConfigObfsGecko{
+						Password:      "backup_g3ck0",
+						MinPacketSize: 200,
+						MaxPacketSize: 1000,
+					},
+				},
+				TLS: clientConfigTLS{
+					SNI:               "backup1.example.com",
+					Insecure:          true,
+					PinSHA256:         "DEADBEEF114514",
+					CA:                "backup_ca.crt",
+					ClientCertificate: "backup.crt",
+					ClientKey:         "backup.key",
+					ECH:               "AEv+DQBHAAAgACB3rc0R",
+				},
+			},
+			{
+				Server: "backup2.example.com:443",
+			},
+		},
+		ServerSelection: clientConfigServerSelection{
+			Policy:              "lowestLatency",
+			HealthCheckInterval: 30 * time.Second,
+		},
+		Auth: "weak_ahh_password",
 		Realm: clientConfigRealm{
 			STUNServers:  []string{"stun1.example.com:3478", "stun2.example.com:3478"},
 			STUNTimeout:  6 * time.Second,
@@ -73,6 +114,14 @@ func TestClientConfig(t *testing.T) {
 			KeepAlivePeriod:             4 * time.Second,
 			DisablePathMTUDiscovery:     true,
 			DisableChromeParrot:         true,
+			Disable0RTT:                 true,
+			EnableMigration:             true,
+			PoolSize:                    4,
+			Health: clientConfigQUICHealth{
+				Enable:   true,
+				Interval: 3 * time.Second,
+				Timeout:  12 * time.Second,
+			},
 			Sockopts: clientConfigQUICSockopts{
 				BindInterface:       stringRef("eth0"),
 				FirewallMark:        uint32Ref(1234),
@@ -97,40 +146,64 @@ func TestClientConfig(t *testing.T) {
 		},
 		FastOpen: true,
 		Lazy:     true,
+		Reconnect: clientConfigReconnect{
+			InitialDelay: 2 * time.Second,
+			MaxDelay:     5 * time.Minute,
+			Multiplier:   1.5,
+			Jitter:       float64Ref(0.1),
+		},
+		ACL: clientConfigACL{
+			File: "client_acl.txt",
+			Inline: []string{
+				"direct(geoip:private)",
+				"reject(geosite:category-ads-all)",
+			},
+			GeoIP:             "some.dat",
+			GeoSite:           "some_site.dat",
+			GeoUpdateInterval: 168 * time.Hour,
+			Resolve:           true,
+		},
 		SOCKS5: &socks5Config{
 			Listen:     "127.0.0.1:1080",
 			Username:   "anon",
 			Password:   "bro",
 			DisableUDP: true,
+			DisableACL: true,
 		},
 		HTTP: &httpConfig{
-			Listen:   "127.0.0.1:8080",
-			Username: "qqq",
-			Password: "bruh",
-			Realm:    "martian",
+			Listen:     "127.0.0.1:8080",
+			Username:   "qqq",
+			Password:   "bruh",
+			Realm:      "martian",
+			DisableACL: true,
 		},
 		TCPForwarding: []tcpForwardingEntry{
 			{
-				Listen: "127.0.0.1:8088",
-				Remote: "internal.example.com:80",
+				Listen:     "127.0.0.1:8088",
+				Remote:     "internal.example.com:80",
+				DisableACL: true,
 			},
 		},
 		UDPForwarding: []udpForwardingEntry{
 			{
-				Listen:  "127.0.0.1:5353",
-				Remote:  "internal.example.com:53",
-				Timeout: 50 * time.Second,
+				Listen:     "127.0.0.1:5353",
+				Remote:     "internal.example.com:53",
+				Timeout:    50 * time.Second,
+				DisableACL: true,
 			},
 		},
 		TCPTProxy: &tcpTProxyConfig{
-			Listen: "127.0.0.1:2500",
+			Listen:     "127.0.0.1:2500",
+			DisableACL: true,
 		},
 		UDPTProxy: &udpTProxyConfig{
-			Listen:  "127.0.0.1:2501",
-			Timeout: 20 * time.Second,
+			Listen:     "127.0.0.1:2501",
+			Timeout:    20 * time.Second,
+			DisableACL: true,
 		},
 		TCPRedirect: &tcpRedirectConfig{
-			Listen: "127.0.0.1:3500",
+			Listen:     "127.0.0.1:3500",
+			DisableACL: true,
 		},
 		TUN: &tunConfig{
 			Name:    "hytun",
@@ -153,6 +226,18 @@ func TestClientConfig(t *testing.T) {
 				IPv4Exclude: []string{"192.0.2.1/32"},
 				IPv6Exclude: []string{"2001:db8::1/128"},
 			},
+			ACL: true,
+		},
+		API: clientConfigAPI{
+			Listen: "127.0.0.1:9999",
+			Secret: "its_a_secret",
+		},
+		Log: clientConfigLog{
+			Level:      "debug",
+			Format:     "json",
+			File:       "/var/log/hysteria.log",
+			MaxSize:    10,
+			MaxBackups: 3,
 		},
 	})
 }
@@ -390,6 +475,38 @@ func stringRef(s string) *string {
 	return &s
 }
 
+func float64Ref(f float64) *float64 {
+	return &f
+}
+
 func uint32Ref(i uint32) *uint32 {
 	return &i
 }
+
+func TestClientServerConfigs(t *testing.T) {
+	config := &clientConfig{
+		Auth: "shared",
+		TLS:  clientConfigTLS{SNI: "shared.example.com"},
+		Servers: []clientConfigServer{
+			{Server: "a.example.com:443"},
+			{Server: "b.example.com:443", Auth: "b_password", TLS: clientConfigTLS{Insecure: true}},
+		},
+	}
+	assert.NoError(t, config.validateServers())
+	configs := config.serverConfigs()
+	if assert.Len(t, configs, 2) {
+		assert.Equal(t, "a.example.com:443", configs[0].Server)
+		assert.Equal(t, "shared", configs[0].Auth)
+		assert.Equal(t, clientConfigTLS{SNI: "shared.example.com"}, configs[0].TLS)
+		assert.Equal(t, "b.example.com:443", configs[1].Server)
+		assert.Equal(t, "b_password", configs[1].Auth)
+		assert.Equal(t, clientConfigTLS{Insecure: true}, configs[1].TLS)
+		assert.Empty(t, configs[1].Servers)
+	}
+
+	config.Server = "c.example.com:443"
+	assert.Error(t, config.validateServers())
+	config.Server = ""
+	config.ServerSelection.Policy = "random"
+	assert.Error(t, config.validateServers())
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/client"
+)
+
+// ClientInstance is a client started through the embedding API.
+// Unlike StartFromJSON, it does not block and does not react to signals;
+// the host application stops it explicitly with Stop.
+// Multiple instances can run in the same process at the same time.
+type ClientInstance struct {
+	client client.ReconnectableClient
+	runner *clientModeRunner
+
+	reloadMutex sync.Mutex
+	config      clientConfig // as parsed, for diffing on reload
+
+	stopOnce sync.Once
+	stopping chan struct{}
+	done     chan struct{}
+	err      *ClientError // set before done is closed
+}
+
+// parseClientJSON reads a JSON client config.
+// Each call uses its own viper so concurrent clients don't share config state.
+func parseClientJSON(json string) (*clientConfig, error) {
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(strings.NewReader(json)); err != nil {
+		logger.Error("failed to read client config", zap.Error(err))
+		return nil, newClientError(ErrorCategoryConfig, err)
+	}
+	var config clientConfig
+	if err := v.Unmarshal(&config); err != nil {
+		logger.Error("failed to parse client config", zap.Error(err))
+		return nil, newClientError(ErrorCategoryConfig, err)
+	}
+	return &config, nil
+}
+
+// ValidateClientJSON checks a JSON client config without connecting to the server.
+// It returns nil if the config is valid, or a *ClientError describing the first problem found.
+func ValidateClientJSON(json string) error {
+	InitLogger()
+
+	config, err := parseClientJSON(json)
+	if err != nil {
+		return err
+	}
+	if err := config.validate(); err != nil {
+		return newClientError(ErrorCategoryConfig, err)
+	}
+	if len(config.modes(nil, nil)) == 0 {
+		return &ClientError{Category: ErrorCategoryConfig, Message: "no mode specified"}
+	}
+	return nil
+}
+
+// StartClient parses the JSON client config, connects (unless lazy mode is enabled)
+// and starts all configured modes in the background.
+// Errors are returned as *ClientError. sink receives the client's events, it can be nil.
+func StartClient(json string, sink EventSink) (*ClientInstance, error) {
+	InitLogger()
+
+	config, err := parseClientJSON(json)
+	if err != nil {
+		return nil, err
+	}
+	if err := startClientLog(config.Log); err != nil {
+		return nil, newClientError(ErrorCategoryConfig, err)
+	}
+	parsed := *config
+
+	c, err := config.newReconnectableClient(
+		func(c client.Client, info *client.HandshakeInfo, count int) {
+			connectLog(info, count)
+			emitEvent(sink, connectedEvent(info, count))
+		},
+		func(c client.Client, err error) {
+			emitEvent(sink, disconnectedEvent(err))
+		},
+	)
+	if err != nil {
+		stopClientLog()
+		logger.Error("failed to initialize client", zap.Error(err))
+		return nil, newClientError(ErrorCategoryConnect, err)
+	}
+	c.SetMessageFunc(func(msg client.ControlMessage) {
+		messageLog(msg)
+		emitEvent(sink, messageEvent(msg))
+	})
+
+	runner := &clientModeRunner{Events: sink}
+	config.addModes(runner, c)
+	if runner.Len() == 0 {
+		_ = c.Close()
+		stopClientLog()
+		return nil, &ClientError{Category: ErrorCategoryConfig, Message: "no mode specified"}
+	}
+
+	inst := &ClientInstance{
+		client:   c,
+		runner:   runner,
+		config:   parsed,
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go inst.run()
+	return inst, nil
+}
+
+func (inst *ClientInstance) run() {
+	defer close(inst.done)
+	defer stopClientLog()
+	r := inst.runner.Run()
+	select {
+	case <-inst.stopping:
+		// Modes returning because of Stop is expected
+		return
+	default:
+	}
+	if r.OK {
+		logger.Info(r.Msg)
+	} else {
+		logger.Error(r.Msg, zap.Error(r.Err))
+		inst.err = r.clientError()
+	}
+	// One of the modes failed, tear down the rest
+	_ = inst.runner.Close()
+	_ = inst.client.Close()
+}
+
+// Stop closes all listeners and the connection to the server,
+// and waits for the running modes to return. It is safe to call Stop multiple times.
+func (inst *ClientInstance) Stop() error {
+	var err error
+	inst.stopOnce.Do(func() {
+		close(inst.stopping)
+		err = errors.Join(inst.runner.Close(), inst.client.Close())
+	})
+	<-inst.done
+	return err
+}
+
+// Reload applies a new JSON client config to the running instance.
+// If the server, auth, TLS, obfuscation or other connection settings changed,
+// the client connects with them in the background, closing the old connection once done.
+// Only the modes whose config sections changed are restarted.
+// Mimic, lazy and reconnect settings are not reloaded. Errors, including a restarted
+// mode failing to start, are returned as *ClientError, and leave the instance running
+// with its previous config.
+func (inst *ClientInstance) Reload(json string) error {
+	next, err := parseClientJSON(json)
+	if err != nil {
+		return err
+	}
+	inst.reloadMutex.Lock()
+	defer inst.reloadMutex.Unlock()
+	select {
+	case <-inst.done:
+		return &ClientError{Category: ErrorCategoryRuntime, Message: "client is stopped"}
+	default:
+	}
+	if err := reloadClientLog(inst.config.Log, next.Log, func() error {
+		return reloadClient(inst.config, *next, inst.client, inst.runner)
+	}); err != nil {
+		logger.Error("failed to reload client config", zap.Error(err))
+		return newClientError(ErrorCategoryConfig, err)
+	}
+	inst.config = *next
+	logger.Info("client config reloaded")
+	return nil
+}
+
+// SetBandwidth changes the bandwidth of the current connection without reconnecting,
+// for example to lower down when switching to a metered network. The values use
+// the same format as bandwidth.up and bandwidth.down in the config, empty means unknown.
+// The change only lasts until the config is reloaded. Errors are returned as *ClientError.
+func (inst *ClientInstance) SetBandwidth(up, down string) error {
+	c := clientConfig{Bandwidth: clientConfigBandwidth{Up: up, Down: down}}
+	var hyConfig client.Config
+	if err := c.fillBandwidthConfig(&hyConfig); err != nil {
+		return newClientError(ErrorCategoryConfig, err)
+	}
+	if err := inst.client.SetBandwidth(hyConfig.BandwidthConfig.MaxTx, hyConfig.BandwidthConfig.MaxRx); err != nil {
+		return newClientError(ErrorCategoryRuntime, err)
+	}
+	return nil
+}
+
+// Done returns a channel that is closed once the instance has stopped,
+// either because Stop was called or because one of its modes failed.
+func (inst *ClientInstance) Done() <-chan struct{} {
+	return inst.done
+}
+
+// Wait blocks until the instance has stopped and returns the runtime failure
+// that stopped it, or nil if it was stopped by Stop.
+func (inst *ClientInstance) Wait() error {
+	<-inst.done
+	if inst.err == nil {
+		return nil
+	}
+	return inst.err
+}
+
+// ClientStats is the snapshot of client.Stats reported to embedders.
+type ClientStats struct {
+	Tx                uint64            `json:"tx"`
+	Rx                uint64            `json:"rx"`
+	ActiveStreams     int               `json:"activeStreams"`
+	ActiveUDPSessions int               `json:"activeUDPSessions"`
+	ReconnectCount    int               `json:"reconnectCount"`
+	RTT               int64             `json:"rtt"` // milliseconds
+	Loss              float64           `json:"loss"`
+	Paths             []ClientPathStats `json:"paths"`
+	Conns             []ClientConnStats `json:"conns"`
+}
+
+// ClientPathStats is the snapshot of client.PathStats reported to embedders.
+type ClientPathStats struct {
+	SmoothedRTT      float64 `json:"smoothedRTT"` // milliseconds
+	MinRTT           float64 `json:"minRTT"`      // milliseconds
+	CongestionWindow uint64  `json:"cwnd"`
+	BytesInFlight    uint64  `json:"bytesInFlight"`
+	PacketsSent      uint64  `json:"packetsSent"`
+	PacketsLost      uint64  `json:"packetsLost"`
+	BytesLost        uint64  `json:"bytesLost"`
+	MTU              uint64  `json:"mtu"`
+	Congestion       string  `json:"congestion"`
+	BrutalAckRate    float64 `json:"brutalAckRate,omitempty"`
+	BrutalBandwidth  uint64  `json:"brutalBandwidth,omitempty"`
+	BBRMode          string  `json:"bbrMode,omitempty"`
+	BBRBandwidth     uint64  `json:"bbrBandwidth,omitempty"` // bytes per second
+}
+
+func newClientPathStats(p client.PathStats) ClientPathStats {
+	return ClientPathStats{
+		SmoothedRTT:      float64(p.SmoothedRTT) / float64(time.Millisecond),
+		MinRTT:           float64(p.MinRTT) / float64(time.Millisecond),
+		CongestionWindow: p.CongestionWindow,
+		BytesInFlight:    p.BytesInFlight,
+		PacketsSent:      p.PacketsSent,
+		PacketsLost:      p.PacketsLost,
+		BytesLost:        p.BytesLost,
+		MTU:              p.MTU,
+		Congestion:       p.Congestion,
+		BrutalAckRate:    p.BrutalAckRate,
+		BrutalBandwidth:  p.BrutalBandwidth,
+		BBRMode:          p.BBRMode,
+		BBRBandwidth:     p.BBRBandwidth,
+	}
+}
+
+// ClientConnStats is the snapshot of client.ConnStats reported to embedders.
+type ClientConnStats struct {
+	ID    uint64 `json:"id"`
+	Type  string `json:"type"`
+	Addr  string `json:"addr,omitempty"`
+	Tx    uint64 `json:"tx"`
+	Rx    uint64 `json:"rx"`
+	Since int64  `json:"since"` // Unix milliseconds
+}
+
+func newClientStats(s client.Stats) ClientStats {
+	cs := ClientStats{
+		Tx:                s.Tx,
+		Rx:                s.Rx,
+		ActiveStreams:     s.ActiveStreams,
+		ActiveUDPSessions: s.ActiveUDPSessions,
+		ReconnectCount:    s.ReconnectCount,
+		RTT:               s.RTT.Milliseconds(),
+		Loss:              s.Loss,
+		Paths:             make([]ClientPathStats, 0, len(s.Paths)),
+		Conns:             make([]ClientConnStats, 0, len(s.Conns)),
+	}
+	for _, p := range s.Paths {
+		cs.Paths = append(cs.Paths, newClientPathStats(p))
+	}
+	for _, c := range s.Conns {
+		cs.Conns = append(cs.Conns, ClientConnStats{
+			ID:    c.ID,
+			Type:  c.Type,
+			Addr:  c.Addr,
+			Tx:    c.Tx,
+			Rx:    c.Rx,
+			Since: c.Since.UnixMilli(),
+		})
+	}
+	return cs
+}
+
+// Stats returns the current traffic counters of the client.
+func (inst *ClientInstance) Stats() ClientStats {
+	return newClientStats(inst.client.Stats())
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateClientJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		category ErrorCategory // empty if valid
+		field    string
+	}{
+		{
+			name: "valid",
+			json: `{"server": "127.0.0.1:443", "auth": "pw", "socks5": {"listen": "127.0.0.1:1080"}}`,
+		},
+		{
+			name:     "malformed json",
+			json:     `{"server": `,
+			category: ErrorCategoryConfig,
+		},
+		{
+			name:     "no mode",
+			json:     `{"server": "127.0.0.1:443", "auth": "pw"}`,
+			category: ErrorCategoryConfig,
+		},
+		{
+			name:     "empty server",
+			json:     `{"auth": "pw", "socks5": {"listen": "127.0.0.1:1080"}}`,
+			category: ErrorCategoryConfig,
+			field:    "server",
+		},
+		{
+			name:     "invalid bandwidth",
+			json:     `{"server": "127.0.0.1:443", "bandwidth": {"up": "fast"}, "socks5": {"listen": "127.0.0.1:1080"}}`,
+			category: ErrorCategoryConfig,
+			field:    "bandwidth.up",
+		},
+		{
+			name:     "invalid log level",
+			json:     `{"server": "127.0.0.1:443", "log": {"level": "loud"}, "socks5": {"listen": "127.0.0.1:1080"}}`,
+			category: ErrorCategoryConfig,
+			field:    "log.level",
+		},
+		{
+			name: "api without secret on loopback",
+			json: `{"server": "127.0.0.1:443", "api": {"listen": "127.0.0.1:9999"}}`,
+		},
+		{
+			name:     "api without secret on all interfaces",
+			json:     `{"server": "127.0.0.1:443", "api": {"listen": ":9999"}}`,
+			category: ErrorCategoryConfig,
+			field:    "api.secret",
+		},
+		{
+			name: "api with secret on all interfaces",
+			json: `{"server": "127.0.0.1:443", "api": {"listen": ":9999", "secret": "s3cret"}}`,
+		},
+		{
+			name:     "empty server in list",
+			json:     `{"servers": [{"server": "127.0.0.1:443"}, {"auth": "pw"}], "socks5": {"listen": "127.0.0.1:1080"}}`,
+			category: ErrorCategoryConfig,
+			field:    "servers[1].server",
+		},
+		{
+			name:     "invalid entry in list",
+			json:     `{"servers": [{"server": "127.0.0.1:443", "transport": {"type": "nope"}}], "socks5": {"listen": "127.0.0.1:1080"}}`,
+			category: ErrorCategoryConfig,
+			field:    "servers[0].transport.type",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateClientJSON(test.json)
+			if test.category == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var ce *ClientError
+			if assert.ErrorAs(t, err, &ce) {
+				assert.Equal(t, test.category, ce.Category)
+				assert.Equal(t, test.field, ce.Field)
+				assert.NotEmpty(t, ce.Message)
+			}
+		})
+	}
+}
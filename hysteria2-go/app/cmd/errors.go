@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
 )
 
 type configError struct {
@@ -16,3 +19,60 @@ func (e configError) Error() string {
 func (e configError) Unwrap() error {
 	return e.Err
 }
+
+// ErrorCategory tells embedders what kind of failure a ClientError is.
+type ErrorCategory string
+
+const (
+	ErrorCategoryConfig  ErrorCategory = "config"
+	ErrorCategoryConnect ErrorCategory = "connect"
+	ErrorCategoryAuth    ErrorCategory = "auth"
+	ErrorCategoryRuntime ErrorCategory = "runtime"
+)
+
+// ClientError is the structured error returned by the embedding API
+// in place of logging fatally and exiting the process.
+type ClientError struct {
+	Category ErrorCategory `json:"category"`
+	Field    string        `json:"field,omitempty"`
+	Message  string        `json:"message"`
+	Err      error         `json:"-"`
+}
+
+func (e *ClientError) Error() string {
+	return e.Message
+}
+
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+// newClientError classifies err by the config/core error types it wraps.
+// def is used as the category for errors that don't match any of them.
+func newClientError(def ErrorCategory, err error) *ClientError {
+	if err == nil {
+		return nil
+	}
+	var ce *ClientError
+	if errors.As(err, &ce) {
+		return ce
+	}
+	e := &ClientError{Category: def, Message: err.Error(), Err: err}
+	var cfgErr configError
+	var coreCfgErr hyErrors.ConfigError
+	var authErr hyErrors.AuthError
+	var connErr hyErrors.ConnectError
+	switch {
+	case errors.As(err, &cfgErr):
+		e.Category = ErrorCategoryConfig
+		e.Field = cfgErr.Field
+	case errors.As(err, &coreCfgErr):
+		e.Category = ErrorCategoryConfig
+		e.Field = coreCfgErr.Field
+	case errors.As(err, &authErr):
+		e.Category = ErrorCategoryAuth
+	case errors.As(err, &connErr):
+		e.Category = ErrorCategoryConnect
+	}
+	return e
+}
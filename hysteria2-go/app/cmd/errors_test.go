@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+func TestNewClientError(t *testing.T) {
+	tests := []struct {
+		name     string
+		def      ErrorCategory
+		err      error
+		category ErrorCategory
+		field    string
+	}{
+		{
+			name:     "config error",
+			def:      ErrorCategoryRuntime,
+			err:      configError{Field: "bandwidth.up", Err: errors.New("bad")},
+			category: ErrorCategoryConfig,
+			field:    "bandwidth.up",
+		},
+		{
+			name:     "wrapped config error",
+			def:      ErrorCategoryRuntime,
+			err:      fmt.Errorf("failed to run SOCKS5 server: %w", configError{Field: "listen", Err: errors.New("in use")}),
+			category: ErrorCategoryConfig,
+			field:    "listen",
+		},
+		{
+			name:     "core config error",
+			def:      ErrorCategoryConnect,
+			err:      hyErrors.ConfigError{Field: "TLSConfig.RootCAs", Reason: "bad"},
+			category: ErrorCategoryConfig,
+			field:    "TLSConfig.RootCAs",
+		},
+		{
+			name:     "auth error",
+			def:      ErrorCategoryConnect,
+			err:      hyErrors.AuthError{StatusCode: 404},
+			category: ErrorCategoryAuth,
+		},
+		{
+			name:     "connect error",
+			def:      ErrorCategoryRuntime,
+			err:      hyErrors.ConnectError{Err: errors.New("timeout")},
+			category: ErrorCategoryConnect,
+		},
+		{
+			name:     "other error",
+			def:      ErrorCategoryRuntime,
+			err:      errors.New("something"),
+			category: ErrorCategoryRuntime,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := newClientError(test.def, test.err)
+			assert.Equal(t, test.category, e.Category)
+			assert.Equal(t, test.field, e.Field)
+			assert.Equal(t, test.err.Error(), e.Message)
+			assert.ErrorIs(t, e, test.err)
+		})
+	}
+
+	assert.Nil(t, newClientError(ErrorCategoryRuntime, nil))
+
+	// Already classified errors are kept as they are
+	ce := &ClientError{Category: ErrorCategoryAuth, Message: "denied"}
+	assert.Same(t, ce, newClientError(ErrorCategoryRuntime, fmt.Errorf("wrapped: %w", ce)))
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	EventConnected    EventType = "connected"
+	EventDisconnected EventType = "disconnected"
+	EventAuthFailed   EventType = "authFailed"
+	EventListenerUp   EventType = "listenerUp"
+	EventListenerDown EventType = "listenerDown"
+	EventMessage      EventType = "message"
+
+	EventSOCKS5TCPRequest   EventType = "socks5TCPRequest"
+	EventSOCKS5TCPError     EventType = "socks5TCPError"
+	EventSOCKS5UDPRequest   EventType = "socks5UDPRequest"
+	EventSOCKS5UDPError     EventType = "socks5UDPError"
+	EventHTTPConnectRequest EventType = "httpConnectRequest"
+	EventHTTPConnectError   EventType = "httpConnectError"
+	EventHTTPRequest        EventType = "httpRequest"
+	EventHTTPError          EventType = "httpError"
+	EventTUNTCPRequest      EventType = "tunTCPRequest"
+	EventTUNTCPError        EventType = "tunTCPError"
+	EventTUNUDPRequest      EventType = "tunUDPRequest"
+	EventTUNUDPError        EventType = "tunUDPError"
+)
+
+// Event is a structured notification about the state of a running client.
+// Only the fields relevant to its type are set. For the *Error types,
+// an empty Error means the connection was closed normally.
+type Event struct {
+	Type EventType `json:"type"`
+	// Mode is the config key of the mode a listener event is about, e.g. "socks5".
+	Mode string `json:"mode,omitempty"`
+	// Addr is the server address for connected events, the listen address
+	// for listener events, and the client address for everything else.
+	Addr    string `json:"addr,omitempty"`
+	ReqAddr string `json:"reqAddr,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	// Connected only
+	UDPEnabled  bool   `json:"udpEnabled,omitempty"`
+	Tx          uint64 `json:"tx,omitempty"`
+	ECHAccepted bool   `json:"ech,omitempty"`
+	Used0RTT    bool   `json:"0rtt,omitempty"`
+	Count       int    `json:"count,omitempty"`
+
+	// Message only, pushed by the server
+	MessageType string `json:"messageType,omitempty"`
+	Value       uint64 `json:"value,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+// EventSink receives events from a client started through the embedding API.
+// Event may be called concurrently from multiple goroutines and should return quickly.
+type EventSink interface {
+	Event(e Event)
+}
+
+// EventSinkFunc adapts an ordinary function to EventSink.
+type EventSinkFunc func(e Event)
+
+func (f EventSinkFunc) Event(e Event) {
+	f(e)
+}
+
+// emitEvent sends e to sink, which can be nil.
+func emitEvent(sink EventSink, e Event) {
+	if sink != nil {
+		sink.Event(e)
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func connectedEvent(info *client.HandshakeInfo, count int) Event {
+	return Event{
+		Type:        EventConnected,
+		Addr:        info.ServerAddr.String(),
+		UDPEnabled:  info.UDPEnabled,
+		Tx:          info.Tx,
+		ECHAccepted: info.ECHAccepted,
+		Used0RTT:    info.Used0RTT,
+		Count:       count,
+	}
+}
+
+func messageEvent(msg client.ControlMessage) Event {
+	return Event{
+		Type:        EventMessage,
+		MessageType: msg.Type.String(),
+		Value:       msg.Value,
+		Text:        msg.Text,
+	}
+}
+
+// disconnectedEvent reports authentication failures as their own event type,
+// as they won't go away by reconnecting.
+func disconnectedEvent(err error) Event {
+	var authErr hyErrors.AuthError
+	if errors.As(err, &authErr) {
+		return Event{Type: EventAuthFailed, Error: err.Error()}
+	}
+	return Event{Type: EventDisconnected, Error: errorString(err)}
+}
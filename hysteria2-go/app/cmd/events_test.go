@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+func TestDisconnectedEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		event Event
+	}{
+		{
+			name:  "auth failure",
+			err:   hyErrors.AuthError{StatusCode: 404},
+			event: Event{Type: EventAuthFailed, Error: "authentication error, HTTP status code: 404"},
+		},
+		{
+			name:  "auth failure while reconnecting",
+			err:   hyErrors.ReconnectingError{Err: hyErrors.AuthError{StatusCode: 404}},
+			event: Event{Type: EventAuthFailed, Error: "reconnecting: authentication error, HTTP status code: 404"},
+		},
+		{
+			name:  "connect failure",
+			err:   hyErrors.ConnectError{Err: errors.New("timeout")},
+			event: Event{Type: EventDisconnected, Error: "connect error: timeout"},
+		},
+		{
+			name:  "connection lost",
+			err:   hyErrors.ClosedError{},
+			event: Event{Type: EventDisconnected, Error: "connection closed"},
+		},
+		{
+			name:  "no error",
+			err:   nil,
+			event: Event{Type: EventDisconnected},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.event, disconnectedEvent(test.err))
+		})
+	}
+}
+
+// eventRecorder is an EventSink that keeps all events.
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) Event(e Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) Events() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func TestClientModeRunnerListenerEvents(t *testing.T) {
+	// Taken, so that the second mode fails to listen
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	taken := l.Addr().String()
+
+	tests := []struct {
+		name   string
+		listen string
+		ok     bool
+		events []Event // with the Error of listenerDown only checked to be set if !ok
+	}{
+		{
+			name:   "stopped",
+			listen: "127.0.0.1:0",
+			ok:     true,
+			events: []Event{
+				{Type: EventListenerUp, Mode: "socks5", Addr: "127.0.0.1:0"},
+				{Type: EventListenerDown, Mode: "socks5"},
+			},
+		},
+		{
+			name:   "failed to listen",
+			listen: taken,
+			ok:     false,
+			events: []Event{
+				{Type: EventListenerDown, Mode: "socks5"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &eventRecorder{}
+			runner := &clientModeRunner{Events: sink}
+			runner.Add(&clientMode{Key: "socks5", Name: "SOCKS5 server", Run: func(closers *utils.CloseGroup) error {
+				return clientSOCKS5(socks5Config{Listen: test.listen}, nil, closers, sink)
+			}})
+			resultCh := make(chan clientModeRunnerResult, 1)
+			go func() {
+				resultCh <- runner.Run()
+			}()
+			if test.ok {
+				assert.Eventually(t, func() bool { return len(sink.Events()) == 1 }, 5*time.Second, 10*time.Millisecond)
+				assert.NoError(t, runner.Close())
+			}
+			r := <-resultCh
+			assert.Equal(t, test.ok, r.OK)
+			if !test.ok {
+				ce := r.clientError()
+				assert.Equal(t, ErrorCategoryConfig, ce.Category)
+				assert.Equal(t, "listen", ce.Field)
+			}
+
+			// The mode reports going down after Run has returned
+			assert.Eventually(t, func() bool { return len(sink.Events()) == len(test.events) }, 5*time.Second, 10*time.Millisecond)
+			events := sink.Events()
+			if last := &events[len(events)-1]; !test.ok {
+				assert.NotEmpty(t, last.Error)
+				last.Error = ""
+			}
+			assert.Equal(t, test.events, events)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+// The global logger never changes once created. Reconfiguring the output
+// swaps the core behind it instead, so running goroutines can keep logging.
+var (
+	logAtomicLevel = zap.NewAtomicLevel()
+	logCore        atomic.Pointer[zapcore.Core]
+
+	logOutputMutex sync.Mutex
+	logFormatName  string
+	logFile        *utils.RotatingFile
+	logCallback    func(line string)
+
+	logClientsMutex sync.Mutex
+	logClients      int // clients started with startClientLog that are still running
+)
+
+// errLogShared refuses a log section that would reconfigure the logger under other running clients.
+var errLogShared = configError{Field: "log", Err: errors.New("the logger is shared by all clients in the process, it can't be configured while other clients are running")}
+
+type clientConfigLog struct {
+	Level      string `mapstructure:"level"`
+	Format     string `mapstructure:"format"`
+	File       string `mapstructure:"file"`
+	MaxSize    int    `mapstructure:"maxSize"` // MB, 0 to disable rotation
+	MaxBackups int    `mapstructure:"maxBackups"`
+}
+
+func (c *clientConfigLog) validate() error {
+	if c.Level != "" {
+		if _, ok := logLevelMap[strings.ToLower(c.Level)]; !ok {
+			return configError{Field: "log.level", Err: errors.New("unsupported log level")}
+		}
+	}
+	if c.Format != "" {
+		if _, ok := logFormatMap[strings.ToLower(c.Format)]; !ok {
+			return configError{Field: "log.format", Err: errors.New("unsupported log format")}
+		}
+	}
+	if c.MaxSize < 0 {
+		return configError{Field: "log.maxSize", Err: errors.New("must not be negative")}
+	}
+	if c.MaxBackups < 0 {
+		return configError{Field: "log.maxBackups", Err: errors.New("must not be negative")}
+	}
+	return nil
+}
+
+// apply reconfigures the global logger. Unset fields keep their current values.
+// As the logger is shared, this affects all clients running in the process.
+func (c *clientConfigLog) apply() error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	if c.Level != "" {
+		_ = SetLogLevel(c.Level)
+	}
+	logOutputMutex.Lock()
+	defer logOutputMutex.Unlock()
+	if c.Format != "" {
+		logFormatName = strings.ToLower(c.Format)
+	}
+	if c.File != "" {
+		if logFile == nil || logFile.Path != c.File ||
+			logFile.MaxSize != int64(c.MaxSize)<<20 || logFile.MaxBackups != c.MaxBackups {
+			if logFile != nil {
+				_ = logFile.Close()
+			}
+			logFile = &utils.RotatingFile{
+				Path:       c.File,
+				MaxSize:    int64(c.MaxSize) << 20,
+				MaxBackups: c.MaxBackups,
+			}
+		}
+	}
+	swapLogCore()
+	return nil
+}
+
+// startClientLog applies the log section of a client that is starting, and counts the
+// client as running until stopClientLog is called. As the logger is shared, a log section
+// is refused while other clients are running, instead of silently overriding their settings.
+func startClientLog(c clientConfigLog) error {
+	logClientsMutex.Lock()
+	defer logClientsMutex.Unlock()
+	if c != (clientConfigLog{}) && logClients > 0 {
+		return errLogShared
+	}
+	if err := c.apply(); err != nil {
+		return err
+	}
+	logClients++
+	return nil
+}
+
+func stopClientLog() {
+	logClientsMutex.Lock()
+	defer logClientsMutex.Unlock()
+	logClients--
+}
+
+// reloadClientLog calls reload, and applies next once it succeeds.
+// Changing the log section is refused while other clients are running, as with startClientLog.
+func reloadClientLog(cur, next clientConfigLog, reload func() error) error {
+	logClientsMutex.Lock()
+	defer logClientsMutex.Unlock()
+	if next == cur {
+		return reload()
+	}
+	if next != (clientConfigLog{}) && logClients > 1 {
+		return errLogShared
+	}
+	if err := next.validate(); err != nil {
+		return err
+	}
+	if err := reload(); err != nil {
+		return err
+	}
+	return next.apply()
+}
+
+// SetLogLevel changes the level of the global logger at runtime.
+func SetLogLevel(level string) error {
+	l, ok := logLevelMap[strings.ToLower(level)]
+	if !ok {
+		return errors.New("unsupported log level: " + level)
+	}
+	logAtomicLevel.SetLevel(l)
+	return nil
+}
+
+// SetLogCallback delivers every log entry, formatted as a single line in the
+// configured format, to cb instead of stderr. A log file, if configured, keeps
+// being written. Pass nil to go back to stderr.
+func SetLogCallback(cb func(line string)) {
+	InitLogger()
+	logOutputMutex.Lock()
+	defer logOutputMutex.Unlock()
+	logCallback = cb
+	swapLogCore()
+}
+
+// swapLogCore builds a core from the current output settings and puts it
+// behind the global logger. Must be called with logOutputMutex held.
+func swapLogCore() {
+	enc := logFormatMap[logFormatName]
+	var encoder zapcore.Encoder
+	if logFormatName == "json" {
+		encoder = zapcore.NewJSONEncoder(enc)
+	} else {
+		encoder = zapcore.NewConsoleEncoder(enc)
+	}
+	var writers []zapcore.WriteSyncer
+	if logFile != nil {
+		writers = append(writers, zapcore.AddSync(logFile))
+	}
+	if logCallback != nil {
+		writers = append(writers, zapcore.AddSync(logCallbackWriter(logCallback)))
+	}
+	if len(writers) == 0 {
+		writers = append(writers, zapcore.Lock(os.Stderr))
+	}
+	core := zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writers...), logAtomicLevel)
+	logCore.Store(&core)
+}
+
+// logCallbackWriter passes each write, which zap does once per entry, to the callback.
+type logCallbackWriter func(line string)
+
+var _ io.Writer = logCallbackWriter(nil)
+
+func (w logCallbackWriter) Write(p []byte) (int, error) {
+	w(strings.TrimSuffix(string(p), zapcore.DefaultLineEnding))
+	return len(p), nil
+}
+
+// swapCore forwards everything to the core currently stored in logCore.
+type swapCore struct {
+	fields []zapcore.Field
+	cached atomic.Pointer[swapCoreCache]
+}
+
+// swapCoreCache is the core derived from base with the fields of a swapCore,
+// so that it is only derived again after a swap.
+type swapCoreCache struct {
+	base *zapcore.Core
+	core zapcore.Core
+}
+
+func (c *swapCore) current() zapcore.Core {
+	base := logCore.Load()
+	if len(c.fields) == 0 {
+		return *base
+	}
+	if cached := c.cached.Load(); cached != nil && cached.base == base {
+		return cached.core
+	}
+	core := (*base).With(c.fields)
+	c.cached.Store(&swapCoreCache{base: base, core: core})
+	return core
+}
+
+func (c *swapCore) Enabled(level zapcore.Level) bool {
+	return logAtomicLevel.Enabled(level)
+}
+
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	return &swapCore{fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *swapCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *swapCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(ent, fields)
+}
+
+func (c *swapCore) Sync() error {
+	return c.current().Sync()
+}
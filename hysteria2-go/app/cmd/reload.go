@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/spf13/viper"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/server"
+	"github.com/apernet/hysteria/extras/v2/auth"
+)
+
+// readClientConfig (re-)reads the config file of v.
+func readClientConfig(v *viper.Viper) (*clientConfig, error) {
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var config clientConfig
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// clientConnectionChanged reports whether any of the sections that make up
+// the client.Config differ between the two configs.
+func clientConnectionChanged(a, b *clientConfig) bool {
+	return a.Server != b.Server ||
+		!reflect.DeepEqual(a.Servers, b.Servers) ||
+		a.ServerSelection != b.ServerSelection ||
+		a.Auth != b.Auth ||
+		!reflect.DeepEqual(a.Realm, b.Realm) ||
+		!reflect.DeepEqual(a.Transport, b.Transport) ||
+		!reflect.DeepEqual(a.Obfs, b.Obfs) ||
+		!reflect.DeepEqual(a.TLS, b.TLS) ||
+		!reflect.DeepEqual(a.QUIC, b.QUIC) ||
+		!reflect.DeepEqual(a.Congestion, b.Congestion) ||
+		!reflect.DeepEqual(a.Bandwidth, b.Bandwidth) ||
+		a.FastOpen != b.FastOpen
+}
+
+// reloadClient applies next to a client running with cur. Both must be the
+// configs as parsed, before Config has filled anything in from the server URI.
+//
+// If the connection settings changed, the client connects with the new config
+// in the background and then switches to it. Of the inbound modes, only those whose sections changed
+// are restarted. Nothing is changed if next fails validation, or if one of the restarted modes
+// fails to start.
+func reloadClient(cur, next clientConfig, hyClient client.ReconnectableClient, runner *clientModeRunner) error {
+	reconnect := clientConnectionChanged(&cur, &next)
+	if err := next.validate(); err != nil {
+		return err
+	}
+	modes := next.modes(hyClient, runner.Events)
+	if len(modes) == 0 {
+		return errors.New("no mode specified")
+	}
+	if !reflect.DeepEqual(cur.Mimic, next.Mimic) {
+		logger.Warn("mimic config changes require a restart, ignoring them")
+	}
+	if cur.Lazy != next.Lazy {
+		logger.Warn("lazy config changes require a restart, ignoring them")
+	}
+	if !reflect.DeepEqual(cur.Reconnect, next.Reconnect) {
+		logger.Warn("reconnect config changes require a restart, ignoring them")
+	}
+	var group *client.ServerGroup
+	if reconnect && len(next.Servers) > 0 {
+		var err error
+		group, err = next.serverGroup()
+		if err != nil {
+			return err
+		}
+	}
+	if err := runner.Sync(modes); err != nil {
+		return err
+	}
+	if reconnect {
+		logger.Info("connection config changed, reconnecting")
+		if group != nil {
+			hyClient.SetServerGroup(group)
+		} else {
+			hyClient.SetConfigFunc(next.Config)
+		}
+	}
+	return nil
+}
+
+// serverReloadRequests passes reload requests from the traffic stats API
+// to runServer, which replies with the result.
+var serverReloadRequests = make(chan chan error)
+
+// serverReloadHandler adds POST /reload to the traffic stats API,
+// which reloads the server config the same way SIGHUP does.
+type serverReloadHandler struct {
+	http.Handler
+	Secret string
+}
+
+func (h *serverReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/reload" {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+	if h.Secret != "" && r.Header.Get("Authorization") != h.Secret {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	errChan := make(chan error, 1)
+	select {
+	case serverReloadRequests <- errChan:
+	case <-r.Context().Done():
+		return
+	}
+	if err := <-errChan; err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func readServerConfig(v *viper.Viper) (*serverConfig, error) {
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var config serverConfig
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// withoutReloadable returns c with the sections that reloadServer applies cleared.
+func (c serverConfig) withoutReloadable() serverConfig {
+	c.Bandwidth = serverConfigBandwidth{}
+	c.IgnoreClientBandwidth = false
+	c.SpeedTest = false
+	c.Auth = serverConfigAuth{}
+	c.Resolver = serverConfigResolver{}
+	c.ACL = serverConfigACL{}
+	c.Outbounds = nil
+	c.Masquerade = serverConfigMasquerade{
+		ListenHTTP:  c.Masquerade.ListenHTTP,
+		ListenHTTPS: c.Masquerade.ListenHTTPS,
+		ForceHTTPS:  c.Masquerade.ForceHTTPS,
+	}
+	return c
+}
+
+// reloadServer applies the auth, outbound (resolver, ACL, outbounds, speed test),
+// masquerade and bandwidth sections of next to a server running with cur.
+// Existing connections keep running. Changes to any other section are
+// logged and ignored, as they need a restart. Nothing is changed if next is invalid.
+func reloadServer(cur, next serverConfig, s server.Server, banList *auth.BanList) error {
+	rc, banConfig, err := next.ReloadConfig(banList)
+	if err != nil {
+		return err
+	}
+	if err := s.Reload(rc); err != nil {
+		return err
+	}
+	banList.SetConfig(banConfig)
+	if !reflect.DeepEqual(cur.withoutReloadable(), next.withoutReloadable()) {
+		logger.Warn("config changes outside of auth, resolver, acl, outbounds, masquerade and bandwidth require a restart, ignoring them")
+	}
+	if cur.Auth.BruteForce.Drop != next.Auth.BruteForce.Drop {
+		logger.Warn("auth.bruteForce.drop changes require a restart, ignoring them")
+	}
+	if next.Masquerade.ListenHTTP != "" || next.Masquerade.ListenHTTPS != "" {
+		if !reflect.DeepEqual(cur.Masquerade, next.Masquerade) {
+			logger.Warn("masquerade HTTP/HTTPS servers keep their previous config until restart")
+		}
+	}
+	return nil
+}
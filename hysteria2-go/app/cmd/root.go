@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -142,30 +143,24 @@ func initLogger() {
 		fmt.Printf("unsupported log level: %s\n", logLevel)
 		os.Exit(1)
 	}
-	enc, ok := logFormatMap[strings.ToLower(logFormat)]
-	if !ok {
+	if _, ok := logFormatMap[strings.ToLower(logFormat)]; !ok {
 		fmt.Printf("unsupported log format: %s\n", logFormat)
 		os.Exit(1)
 	}
-	c := zap.Config{
-		Level:             zap.NewAtomicLevelAt(level),
-		DisableCaller:     true,
-		DisableStacktrace: true,
-		Encoding:          strings.ToLower(logFormat),
-		EncoderConfig:     enc,
-		OutputPaths:       []string{"stderr"},
-		ErrorOutputPaths:  []string{"stderr"},
-	}
-	var err error
-	logger, err = c.Build()
-	if err != nil {
-		fmt.Printf("failed to initialize logger: %s\n", err)
-		os.Exit(1)
-	}
+	logAtomicLevel.SetLevel(level)
+	logOutputMutex.Lock()
+	logFormatName = strings.ToLower(logFormat)
+	swapLogCore()
+	logOutputMutex.Unlock()
+	logger = zap.New(&swapCore{})
 }
 
+var initLoggerOnce sync.Once
+
+// InitLogger creates the global logger from the log level & format flags (or their env vars).
+// It only does so once, later calls are no-ops.
 func InitLogger() {
-	initLogger()
+	initLoggerOnce.Do(initLogger)
 }
 
 func envOrDefaultString(key, def string) string {
@@ -79,6 +79,8 @@ type serverConfig struct {
 	Mimic                 mimicConfig                 `mapstructure:"mimic"`
 	Congestion            serverConfigCongestion      `mapstructure:"congestion"`
 	Bandwidth             serverConfigBandwidth       `mapstructure:"bandwidth"`
+	RateLimit             serverConfigRateLimit       `mapstructure:"rateLimit"`
+	ConnLimit             serverConfigConnLimit       `mapstructure:"connLimit"`
 	IgnoreClientBandwidth bool                        `mapstructure:"ignoreClientBandwidth"`
 	SpeedTest             bool                        `mapstructure:"speedTest"`
 	DisableUDP            bool                        `mapstructure:"disableUDP"`
@@ -89,7 +91,9 @@ type serverConfig struct {
 	ACL                   serverConfigACL             `mapstructure:"acl"`
 	Outbounds             []serverConfigOutboundEntry `mapstructure:"outbounds"`
 	TrafficStats          serverConfigTrafficStats    `mapstructure:"trafficStats"`
+	Quota                 serverConfigQuota           `mapstructure:"quota"`
 	Masquerade            serverConfigMasquerade      `mapstructure:"masquerade"`
+	DrainTimeout          time.Duration               `mapstructure:"drainTimeout"` // 0 to close immediately on shutdown
 }
 
 type serverConfigRealm struct {
@@ -172,6 +176,8 @@ type serverConfigQUIC struct {
 	MaxIdleTimeout              time.Duration `mapstructure:"maxIdleTimeout"`
 	MaxIncomingStreams          int64         `mapstructure:"maxIncomingStreams"`
 	DisablePathMTUDiscovery     bool          `mapstructure:"disablePathMTUDiscovery"`
+	Enable0RTT                  bool          `mapstructure:"enable0RTT"`
+	EnableMigration             bool          `mapstructure:"enableMigration"`
 }
 
 type serverConfigBandwidth struct {
@@ -191,11 +197,20 @@ type serverConfigAuthHTTP struct {
 }
 
 type serverConfigAuth struct {
-	Type     string               `mapstructure:"type"`
-	Password string               `mapstructure:"password"`
-	UserPass map[string]string    `mapstructure:"userpass"`
-	HTTP     serverConfigAuthHTTP `mapstructure:"http"`
-	Command  string               `mapstructure:"command"`
+	Type       string                     `mapstructure:"type"`
+	Password   string                     `mapstructure:"password"`
+	UserPass   map[string]string          `mapstructure:"userpass"`
+	HTTP       serverConfigAuthHTTP       `mapstructure:"http"`
+	Command    string                     `mapstructure:"command"`
+	BruteForce serverConfigAuthBruteForce `mapstructure:"bruteForce"`
+}
+
+type serverConfigAuthBruteForce struct {
+	MaxFailures int           `mapstructure:"maxFailures"`
+	Window      time.Duration `mapstructure:"window"`
+	BanDuration time.Duration `mapstructure:"banDuration"`
+	BanFile     string        `mapstructure:"banFile"`
+	Drop        bool          `mapstructure:"drop"`
 }
 
 type serverConfigResolverTCP struct {
@@ -278,6 +293,26 @@ type serverConfigTrafficStats struct {
 	Secret string `mapstructure:"secret"`
 }
 
+// serverConfigRateLimit is enforced per user, unlike bandwidth which only sets the congestion control.
+type serverConfigRateLimit struct {
+	Up   string `mapstructure:"up"`
+	Down string `mapstructure:"down"`
+}
+
+type serverConfigConnLimit struct {
+	MaxPerUser  int  `mapstructure:"maxPerUser"`
+	MaxPerIP    int  `mapstructure:"maxPerIP"`
+	IPv4Prefix  int  `mapstructure:"ipv4Prefix"`
+	IPv6Prefix  int  `mapstructure:"ipv6Prefix"`
+	EvictOldest bool `mapstructure:"evictOldest"`
+}
+
+type serverConfigQuota struct {
+	File         string        `mapstructure:"file"`      // limits per user
+	StateFile    string        `mapstructure:"stateFile"` // usage, kept across restarts
+	SaveInterval time.Duration `mapstructure:"saveInterval"`
+}
+
 type serverConfigMasqueradeFile struct {
 	Dir string `mapstructure:"dir"`
 }
@@ -305,12 +340,12 @@ type serverConfigMasquerade struct {
 	ForceHTTPS  bool                         `mapstructure:"forceHTTPS"`
 }
 
-func (c *serverConfig) fillConn(hyConfig *server.Config) error {
+func (c *serverConfig) fillConn(hyConfig *server.Config, banList *auth.BanList) error {
 	if realmAddr, ok, err := parseServerRealmAddr(c.Listen); ok || err != nil {
 		if err != nil {
 			return configError{Field: "listen", Err: err}
 		}
-		return c.fillRealmConn(hyConfig, realmAddr)
+		return c.fillRealmConn(hyConfig, realmAddr, banList)
 	}
 	listenAddr := c.Listen
 	if listenAddr == "" {
@@ -333,7 +368,7 @@ func (c *serverConfig) fillConn(hyConfig *server.Config) error {
 			return configError{Field: "listen", Err: err}
 		}
 	}
-	wrapped, err := c.wrapObfs(packetConn)
+	wrapped, err := c.wrapObfs(c.wrapBanFilter(packetConn, banList))
 	if err != nil {
 		_ = conn.Close()
 		if cleanup != nil {
@@ -357,7 +392,7 @@ func parseServerRealmAddr(listen string) (*realm.Addr, bool, error) {
 	return nil, false, nil
 }
 
-func (c *serverConfig) fillRealmConn(hyConfig *server.Config, addr *realm.Addr) error {
+func (c *serverConfig) fillRealmConn(hyConfig *server.Config, addr *realm.Addr, banList *auth.BanList) error {
 	logger.Debug("realm server mode detected",
 		zap.String("realm", addr.RealmID),
 		zap.String("realmServer", addr.HostPort),
@@ -383,7 +418,7 @@ func (c *serverConfig) fillRealmConn(hyConfig *server.Config, addr *realm.Addr)
 		_ = conn.Close()
 		return configError{Field: "realm", Err: err}
 	}
-	packetConn, err := c.wrapObfs(punchConn)
+	packetConn, err := c.wrapObfs(c.wrapBanFilter(punchConn, banList))
 	if err != nil {
 		_ = conn.Close()
 		return err
@@ -401,6 +436,15 @@ func (c *serverConfig) fillRealmConn(hyConfig *server.Config, addr *realm.Addr)
 	return nil
 }
 
+// wrapBanFilter drops the packets of banned client IPs if auth.bruteForce.drop is set.
+// It goes before obfs so that their packets are not even deobfuscated.
+func (c *serverConfig) wrapBanFilter(conn net.PacketConn, banList *auth.BanList) net.PacketConn {
+	if !c.Auth.BruteForce.Drop {
+		return conn
+	}
+	return banList.WrapPacketConn(conn)
+}
+
 func (c *serverConfig) wrapObfs(conn net.PacketConn) (net.PacketConn, error) {
 	switch strings.ToLower(c.Obfs.Type) {
 	case "", "plain":
@@ -1181,6 +1225,8 @@ func (c *serverConfig) fillQUICConfig(hyConfig *server.Config) error {
 		MaxIdleTimeout:                 c.QUIC.MaxIdleTimeout,
 		MaxIncomingStreams:             c.QUIC.MaxIncomingStreams,
 		DisablePathMTUDiscovery:        c.QUIC.DisablePathMTUDiscovery,
+		Enable0RTT:                     c.QUIC.Enable0RTT,
+		EnableMigration:                c.QUIC.EnableMigration,
 		// See the client side: Mimic and GSO are mutually exclusive.
 		DisableGSO: c.Mimic.Enabled,
 	}
@@ -1334,46 +1380,66 @@ func (c *serverConfig) fillOutboundConfig(hyConfig *server.Config) error {
 		uOb = obs[0].Outbound
 	}
 
+	uOb, err := c.wrapOutbound(uOb, hasACL)
+	if err != nil {
+		return err
+	}
+	hyConfig.Outbound = &outbounds.PluggableOutboundAdapter{PluggableOutbound: uOb}
+
+	// Named outbounds that per-user policies of the authenticator can pick,
+	// they are used as they are, without the ACL.
+	hyConfig.Outbounds = make(map[string]server.Outbound, len(obs))
+	for _, entry := range obs {
+		ob, err := c.wrapOutbound(entry.Outbound, false)
+		if err != nil {
+			return err
+		}
+		hyConfig.Outbounds[entry.Name] = &outbounds.PluggableOutboundAdapter{PluggableOutbound: ob}
+	}
+	return nil
+}
+
+// wrapOutbound puts the resolver and the speed test handler in front of ob.
+func (c *serverConfig) wrapOutbound(ob outbounds.PluggableOutbound, hasACL bool) (outbounds.PluggableOutbound, error) {
 	// Resolver
 	switch strings.ToLower(c.Resolver.Type) {
 	case "", "system":
 		if hasACL {
 			// If the user uses ACL, we must put a resolver in front of it,
 			// for IP rules to work on domain requests.
-			uOb = outbounds.NewSystemResolver(uOb)
+			ob = outbounds.NewSystemResolver(ob)
 		}
 		// Otherwise we can just rely on outbound handling on its own.
 	case "tcp":
 		if c.Resolver.TCP.Addr == "" {
-			return configError{Field: "resolver.tcp.addr", Err: errors.New("empty resolver address")}
+			return nil, configError{Field: "resolver.tcp.addr", Err: errors.New("empty resolver address")}
 		}
-		uOb = outbounds.NewStandardResolverTCP(c.Resolver.TCP.Addr, c.Resolver.TCP.Timeout, uOb)
+		ob = outbounds.NewStandardResolverTCP(c.Resolver.TCP.Addr, c.Resolver.TCP.Timeout, ob)
 	case "udp":
 		if c.Resolver.UDP.Addr == "" {
-			return configError{Field: "resolver.udp.addr", Err: errors.New("empty resolver address")}
+			return nil, configError{Field: "resolver.udp.addr", Err: errors.New("empty resolver address")}
 		}
-		uOb = outbounds.NewStandardResolverUDP(c.Resolver.UDP.Addr, c.Resolver.UDP.Timeout, uOb)
+		ob = outbounds.NewStandardResolverUDP(c.Resolver.UDP.Addr, c.Resolver.UDP.Timeout, ob)
 	case "tls", "tcp-tls":
 		if c.Resolver.TLS.Addr == "" {
-			return configError{Field: "resolver.tls.addr", Err: errors.New("empty resolver address")}
+			return nil, configError{Field: "resolver.tls.addr", Err: errors.New("empty resolver address")}
 		}
-		uOb = outbounds.NewStandardResolverTLS(c.Resolver.TLS.Addr, c.Resolver.TLS.Timeout, c.Resolver.TLS.SNI, c.Resolver.TLS.Insecure, uOb)
+		ob = outbounds.NewStandardResolverTLS(c.Resolver.TLS.Addr, c.Resolver.TLS.Timeout, c.Resolver.TLS.SNI, c.Resolver.TLS.Insecure, ob)
 	case "https", "http":
 		if c.Resolver.HTTPS.Addr == "" {
-			return configError{Field: "resolver.https.addr", Err: errors.New("empty resolver address")}
+			return nil, configError{Field: "resolver.https.addr", Err: errors.New("empty resolver address")}
 		}
-		uOb = outbounds.NewDoHResolver(c.Resolver.HTTPS.Addr, c.Resolver.HTTPS.Timeout, c.Resolver.HTTPS.SNI, c.Resolver.HTTPS.Insecure, uOb)
+		ob = outbounds.NewDoHResolver(c.Resolver.HTTPS.Addr, c.Resolver.HTTPS.Timeout, c.Resolver.HTTPS.SNI, c.Resolver.HTTPS.Insecure, ob)
 	default:
-		return configError{Field: "resolver.type", Err: errors.New("unsupported resolver type")}
+		return nil, configError{Field: "resolver.type", Err: errors.New("unsupported resolver type")}
 	}
 
 	// Speed test
 	if c.SpeedTest {
-		uOb = outbounds.NewSpeedtestHandler(uOb)
+		ob = outbounds.NewSpeedtestHandler(ob)
 	}
 
-	hyConfig.Outbound = &outbounds.PluggableOutboundAdapter{PluggableOutbound: uOb}
-	return nil
+	return ob, nil
 }
 
 func (c *serverConfig) fillBandwidthConfig(hyConfig *server.Config) error {
@@ -1394,6 +1460,34 @@ func (c *serverConfig) fillBandwidthConfig(hyConfig *server.Config) error {
 	return nil
 }
 
+func (c *serverConfig) fillRateLimitConfig(hyConfig *server.Config) error {
+	var err error
+	if c.RateLimit.Up != "" {
+		hyConfig.RateLimitConfig.MaxTx, err = utils.ConvBandwidth(c.RateLimit.Up)
+		if err != nil {
+			return configError{Field: "rateLimit.up", Err: err}
+		}
+	}
+	if c.RateLimit.Down != "" {
+		hyConfig.RateLimitConfig.MaxRx, err = utils.ConvBandwidth(c.RateLimit.Down)
+		if err != nil {
+			return configError{Field: "rateLimit.down", Err: err}
+		}
+	}
+	return nil
+}
+
+func (c *serverConfig) fillConnLimitConfig(hyConfig *server.Config) error {
+	hyConfig.ConnLimitConfig = server.ConnLimitConfig{
+		MaxPerUser:  c.ConnLimit.MaxPerUser,
+		MaxPerIP:    c.ConnLimit.MaxPerIP,
+		IPv4Prefix:  c.ConnLimit.IPv4Prefix,
+		IPv6Prefix:  c.ConnLimit.IPv6Prefix,
+		EvictOldest: c.ConnLimit.EvictOldest,
+	}
+	return nil
+}
+
 func (c *serverConfig) fillCongestionConfig(hyConfig *server.Config) error {
 	normalizedType, err := normalizeCongestionType(c.Congestion.Type)
 	if err != nil {
@@ -1425,7 +1519,10 @@ func (c *serverConfig) fillUDPIdleTimeout(hyConfig *server.Config) error {
 	return nil
 }
 
-func (c *serverConfig) fillAuthenticator(hyConfig *server.Config) error {
+// fillAuthenticator builds the authenticator, wrapped to record its failures in banList
+// if auth.bruteForce is enabled. The config of banList is left to the caller,
+// see banConfig.
+func (c *serverConfig) fillAuthenticator(hyConfig *server.Config, banList *auth.BanList) error {
 	if c.Auth.Type == "" {
 		return configError{Field: "auth.type", Err: errors.New("empty auth type")}
 	}
@@ -1435,28 +1532,49 @@ func (c *serverConfig) fillAuthenticator(hyConfig *server.Config) error {
 			return configError{Field: "auth.password", Err: errors.New("empty auth password")}
 		}
 		hyConfig.Authenticator = &auth.PasswordAuthenticator{Password: c.Auth.Password}
-		return nil
 	case "userpass":
 		if len(c.Auth.UserPass) == 0 {
 			return configError{Field: "auth.userpass", Err: errors.New("empty auth userpass")}
 		}
 		hyConfig.Authenticator = auth.NewUserPassAuthenticator(c.Auth.UserPass)
-		return nil
 	case "http", "https":
 		if c.Auth.HTTP.URL == "" {
 			return configError{Field: "auth.http.url", Err: errors.New("empty auth http url")}
 		}
 		hyConfig.Authenticator = auth.NewHTTPAuthenticator(c.Auth.HTTP.URL, c.Auth.HTTP.Insecure)
-		return nil
 	case "command", "cmd":
 		if c.Auth.Command == "" {
 			return configError{Field: "auth.command", Err: errors.New("empty auth command")}
 		}
 		hyConfig.Authenticator = &auth.CommandAuthenticator{Cmd: c.Auth.Command}
-		return nil
 	default:
 		return configError{Field: "auth.type", Err: errors.New("unsupported auth type")}
 	}
+	if c.Auth.BruteForce.MaxFailures > 0 {
+		hyConfig.Authenticator = banList.Wrap(hyConfig.Authenticator)
+	}
+	return nil
+}
+
+// banConfig validates auth.bruteForce and returns the config of the ban list.
+// It is only applied once the rest of the config is valid too.
+func (c *serverConfig) banConfig() (auth.BanConfig, error) {
+	bf := c.Auth.BruteForce
+	if bf.MaxFailures < 0 {
+		return auth.BanConfig{}, configError{Field: "auth.bruteForce.maxFailures", Err: errors.New("must not be negative")}
+	}
+	if bf.Window < 0 {
+		return auth.BanConfig{}, configError{Field: "auth.bruteForce.window", Err: errors.New("must not be negative")}
+	}
+	if bf.BanDuration < 0 {
+		return auth.BanConfig{}, configError{Field: "auth.bruteForce.banDuration", Err: errors.New("must not be negative")}
+	}
+	return auth.BanConfig{
+		MaxFailures: bf.MaxFailures,
+		Window:      bf.Window,
+		BanDuration: bf.BanDuration,
+		File:        bf.BanFile,
+	}, nil
 }
 
 func (c *serverConfig) fillEventLogger(hyConfig *server.Config) error {
@@ -1464,37 +1582,57 @@ func (c *serverConfig) fillEventLogger(hyConfig *server.Config) error {
 	return nil
 }
 
-func (c *serverConfig) fillTrafficLogger(hyConfig *server.Config) error {
+func (c *serverConfig) fillTrafficLogger(hyConfig *server.Config, banList *auth.BanList) error {
 	if c.TrafficStats.Listen != "" {
 		tss := trafficlogger.NewTrafficStatsServer(c.TrafficStats.Secret)
+		tss.SetBanList(banList)
 		hyConfig.TrafficLogger = tss
-		go runTrafficStatsServer(c.TrafficStats.Listen, tss)
+		go runTrafficStatsServer(c.TrafficStats.Listen, &serverReloadHandler{Handler: tss, Secret: c.TrafficStats.Secret})
+	}
+	if c.Quota.File != "" {
+		if c.Quota.StateFile == "" {
+			return configError{Field: "quota.stateFile", Err: errors.New("empty state file")}
+		}
+		if c.Quota.SaveInterval < 0 {
+			return configError{Field: "quota.saveInterval", Err: errors.New("must not be negative")}
+		}
+		ql, err := trafficlogger.NewQuotaLogger(c.Quota.File, c.Quota.StateFile, c.Quota.SaveInterval, hyConfig.TrafficLogger)
+		if err != nil {
+			return configError{Field: "quota.file", Err: err}
+		}
+		hyConfig.TrafficLogger = ql
+		// Save the usage when the server is closed
+		cleanup := &utils.CloseGroup{}
+		if hyConfig.Cleanup != nil {
+			_ = cleanup.Add(hyConfig.Cleanup)
+		}
+		_ = cleanup.Add(ql)
+		hyConfig.Cleanup = cleanup
 	}
 	return nil
 }
 
-// fillMasqHandler must be called after fillConn, as we may need to extract the QUIC
-// port number from Conn for MasqTCPServer.
-func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
+// newMasqHandler builds the masquerade handler shared by QUIC and MasqTCPServer.
+func (c *serverConfig) newMasqHandler() (http.Handler, error) {
 	var handler http.Handler
 	switch strings.ToLower(c.Masquerade.Type) {
 	case "", "404":
 		handler = http.NotFoundHandler()
 	case "file":
 		if c.Masquerade.File.Dir == "" {
-			return configError{Field: "masquerade.file.dir", Err: errors.New("empty file directory")}
+			return nil, configError{Field: "masquerade.file.dir", Err: errors.New("empty file directory")}
 		}
 		handler = http.FileServer(http.Dir(c.Masquerade.File.Dir))
 	case "proxy":
 		if c.Masquerade.Proxy.URL == "" {
-			return configError{Field: "masquerade.proxy.url", Err: errors.New("empty proxy url")}
+			return nil, configError{Field: "masquerade.proxy.url", Err: errors.New("empty proxy url")}
 		}
 		u, err := url.Parse(c.Masquerade.Proxy.URL)
 		if err != nil {
-			return configError{Field: "masquerade.proxy.url", Err: err}
+			return nil, configError{Field: "masquerade.proxy.url", Err: err}
 		}
 		if u.Scheme != "http" && u.Scheme != "https" {
-			return configError{Field: "masquerade.proxy.url", Err: fmt.Errorf("unsupported protocol scheme \"%s\"", u.Scheme)}
+			return nil, configError{Field: "masquerade.proxy.url", Err: fmt.Errorf("unsupported protocol scheme \"%s\"", u.Scheme)}
 		}
 		transport := http.DefaultTransport
 		if c.Masquerade.Proxy.Insecure {
@@ -1535,14 +1673,14 @@ func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
 		}
 	case "string":
 		if c.Masquerade.String.Content == "" {
-			return configError{Field: "masquerade.string.content", Err: errors.New("empty string content")}
+			return nil, configError{Field: "masquerade.string.content", Err: errors.New("empty string content")}
 		}
 		if c.Masquerade.String.StatusCode != 0 &&
 			(c.Masquerade.String.StatusCode < 200 ||
 				c.Masquerade.String.StatusCode > 599 ||
 				c.Masquerade.String.StatusCode == 233) {
 			// 233 is reserved for Hysteria authentication
-			return configError{Field: "masquerade.string.statusCode", Err: errors.New("invalid status code (must be 200-599, except 233)")}
+			return nil, configError{Field: "masquerade.string.statusCode", Err: errors.New("invalid status code (must be 200-599, except 233)")}
 		}
 		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			for k, v := range c.Masquerade.String.Headers {
@@ -1556,7 +1694,17 @@ func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
 			_, _ = w.Write([]byte(c.Masquerade.String.Content))
 		})
 	default:
-		return configError{Field: "masquerade.type", Err: errors.New("unsupported masquerade type")}
+		return nil, configError{Field: "masquerade.type", Err: errors.New("unsupported masquerade type")}
+	}
+	return handler, nil
+}
+
+// fillMasqHandler must be called after fillConn, as we may need to extract the QUIC
+// port number from Conn for MasqTCPServer.
+func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
+	handler, err := c.newMasqHandler()
+	if err != nil {
+		return err
 	}
 	hyConfig.MasqHandler = &masqHandlerLogWrapper{H: handler, QUIC: true}
 
@@ -1580,23 +1728,30 @@ func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
 	return nil
 }
 
-// Config validates the fields and returns a ready-to-use Hysteria server config
-func (c *serverConfig) Config() (*server.Config, error) {
+// Config validates the fields and returns a ready-to-use Hysteria server config.
+// banList keeps the failed authentications and bans, and is configured here.
+func (c *serverConfig) Config(banList *auth.BanList) (*server.Config, error) {
+	banConfig, err := c.banConfig()
+	if err != nil {
+		return nil, err
+	}
 	hyConfig := &server.Config{}
 	fillers := []func(*server.Config) error{
-		c.fillConn,
+		func(hyConfig *server.Config) error { return c.fillConn(hyConfig, banList) },
 		c.fillTLSConfig,
 		c.fillQUICConfig,
 		c.fillRequestHook,
 		c.fillOutboundConfig,
 		c.fillCongestionConfig,
 		c.fillBandwidthConfig,
+		c.fillRateLimitConfig,
+		c.fillConnLimitConfig,
 		c.fillIgnoreClientBandwidth,
 		c.fillDisableUDP,
 		c.fillUDPIdleTimeout,
-		c.fillAuthenticator,
+		func(hyConfig *server.Config) error { return c.fillAuthenticator(hyConfig, banList) },
 		c.fillEventLogger,
-		c.fillTrafficLogger,
+		func(hyConfig *server.Config) error { return c.fillTrafficLogger(hyConfig, banList) },
 		c.fillMasqHandler,
 	}
 	for _, f := range fillers {
@@ -1604,10 +1759,45 @@ func (c *serverConfig) Config() (*server.Config, error) {
 			return nil, err
 		}
 	}
+	banList.SetConfig(banConfig)
 
 	return hyConfig, nil
 }
 
+// ReloadConfig validates the reloadable fields and returns them ready to be applied
+// to a running server, along with the new config of its banList. Unlike Config,
+// it does not open sockets or start servers, and does not change banList.
+func (c *serverConfig) ReloadConfig(banList *auth.BanList) (*server.ReloadConfig, auth.BanConfig, error) {
+	banConfig, err := c.banConfig()
+	if err != nil {
+		return nil, auth.BanConfig{}, err
+	}
+	hyConfig := &server.Config{}
+	fillers := []func(*server.Config) error{
+		c.fillOutboundConfig,
+		c.fillBandwidthConfig,
+		c.fillIgnoreClientBandwidth,
+		func(hyConfig *server.Config) error { return c.fillAuthenticator(hyConfig, banList) },
+	}
+	for _, f := range fillers {
+		if err := f(hyConfig); err != nil {
+			return nil, auth.BanConfig{}, err
+		}
+	}
+	handler, err := c.newMasqHandler()
+	if err != nil {
+		return nil, auth.BanConfig{}, err
+	}
+	return &server.ReloadConfig{
+		Authenticator:         hyConfig.Authenticator,
+		Outbound:              hyConfig.Outbound,
+		Outbounds:             hyConfig.Outbounds,
+		MasqHandler:           &masqHandlerLogWrapper{H: handler, QUIC: true},
+		BandwidthConfig:       hyConfig.BandwidthConfig,
+		IgnoreClientBandwidth: hyConfig.IgnoreClientBandwidth,
+	}, banConfig, nil
+}
+
 func runServerCmd(cmd *cobra.Command, args []string) {
 	logger.Info("server mode")
 	runServer(defaultViper)
@@ -1621,7 +1811,13 @@ func runServer(v *viper.Viper) {
 	if err := v.Unmarshal(&config); err != nil {
 		logger.Fatal("failed to parse server config", zap.Error(err))
 	}
-	hyConfig, err := config.Config()
+	running := config // as parsed, for diffing on reload
+	if config.DrainTimeout < 0 {
+		logger.Fatal("failed to load server config", zap.Error(configError{Field: "drainTimeout", Err: errors.New("must not be negative")}))
+	}
+	// Kept across reloads, so that bans survive them
+	banList := auth.NewBanList(auth.BanConfig{})
+	hyConfig, err := config.Config(banList)
 	if err != nil {
 		logger.Fatal("failed to load server config", zap.Error(err))
 	}
@@ -1659,24 +1855,75 @@ func runServer(v *viper.Viper) {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 	defer signal.Stop(signalChan)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
 
 	serveErrChan := make(chan error, 1)
 	go func() {
 		serveErrChan <- s.Serve()
 	}()
 
-	select {
-	case <-signalChan:
-		logger.Info("received signal, shutting down gracefully")
-		if err := s.Close(); err != nil {
-			logger.Error("failed to shut down server cleanly", zap.Error(err))
-		}
-		if err := <-serveErrChan; err != nil {
-			logger.Info("server stopped", zap.Error(err))
+	reload := func() error {
+		next, err := readServerConfig(v)
+		if err == nil {
+			err = reloadServer(running, *next, s, banList)
 		}
-	case err := <-serveErrChan:
 		if err != nil {
-			logger.Fatal("failed to serve", zap.Error(err))
+			logger.Error("failed to reload server config", zap.Error(err))
+			return err
+		}
+		running = *next
+		logger.Info("server config reloaded")
+		return nil
+	}
+
+	for {
+		select {
+		case <-reloadChan:
+			logger.Info("received SIGHUP, reloading config")
+			_ = reload()
+		case errChan := <-serverReloadRequests:
+			logger.Info("received reload request, reloading config")
+			errChan <- reload()
+		case <-signalChan:
+			logger.Info("received signal, shutting down gracefully", zap.Duration("drainTimeout", config.DrainTimeout))
+			shutdownServer(s, config.DrainTimeout, signalChan)
+			if err := <-serveErrChan; err != nil {
+				logger.Info("server stopped", zap.Error(err))
+			}
+			return
+		case err := <-serveErrChan:
+			if err != nil {
+				logger.Fatal("failed to serve", zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// shutdownServer lets the server drain for up to timeout before closing it.
+// Another signal on signalChan during the drain closes it right away.
+func shutdownServer(s server.Server, timeout time.Duration, signalChan <-chan os.Signal) {
+	if timeout == 0 {
+		_ = s.Close()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-signalChan:
+			logger.Info("received another signal, shutting down now")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := s.Shutdown(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			logger.Warn("connections still active after drain, closing them")
+		} else {
+			logger.Error("failed to shut down server cleanly", zap.Error(err))
 		}
 	}
 }
@@ -1752,6 +1999,14 @@ func (l *serverLogger) UDPError(addr net.Addr, id string, sessionID uint32, err
 	}
 }
 
+func (l *serverLogger) ConnRejected(addr net.Addr, id string, limit string) {
+	logger.Warn("client rejected by connection limit", zap.String("addr", addr.String()), zap.String("id", id), zap.String("limit", limit))
+}
+
+func (l *serverLogger) ConnEvicted(addr net.Addr, id string, limit string, newAddr net.Addr) {
+	logger.Warn("client evicted by connection limit", zap.String("addr", addr.String()), zap.String("id", id), zap.String("limit", limit), zap.String("newAddr", newAddr.String()))
+}
+
 type masqHandlerLogWrapper struct {
 	H    http.Handler
 	QUIC bool
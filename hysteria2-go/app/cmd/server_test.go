@@ -94,6 +94,8 @@ func TestServerConfig(t *testing.T) {
 			MaxIdleTimeout:              999 * time.Second,
 			MaxIncomingStreams:          256,
 			DisablePathMTUDiscovery:     true,
+			Enable0RTT:                  true,
+			EnableMigration:             true,
 		},
 		Mimic: mimicConfig{
 			Enabled:   true,
@@ -111,6 +113,17 @@ func TestServerConfig(t *testing.T) {
 			Down:                    "100 mbps",
 			DisableLossCompensation: true,
 		},
+		RateLimit: serverConfigRateLimit{
+			Up:   "50 mbps",
+			Down: "20 mbps",
+		},
+		ConnLimit: serverConfigConnLimit{
+			MaxPerUser:  3,
+			MaxPerIP:    10,
+			IPv4Prefix:  24,
+			IPv6Prefix:  56,
+			EvictOldest: true,
+		},
 		IgnoreClientBandwidth: true,
 		SpeedTest:             true,
 		DisableUDP:            true,
@@ -128,6 +141,13 @@ func TestServerConfig(t *testing.T) {
 				Insecure: true,
 			},
 			Command: "/etc/some_command",
+			BruteForce: serverConfigAuthBruteForce{
+				MaxFailures: 5,
+				Window:      5 * time.Minute,
+				BanDuration: 2 * time.Hour,
+				BanFile:     "/var/log/hysteria-bans.log",
+				Drop:        true,
+			},
 		},
 		Resolver: serverConfigResolver{
 			Type: "udp",
@@ -203,6 +223,11 @@ func TestServerConfig(t *testing.T) {
 			Listen: ":9999",
 			Secret: "its_me_mario",
 		},
+		Quota: serverConfigQuota{
+			File:         "/etc/hysteria/quota.json",
+			StateFile:    "/var/lib/hysteria/quota_state.json",
+			SaveInterval: 5 * time.Minute,
+		},
 		Masquerade: serverConfigMasquerade{
 			Type: "proxy",
 			File: serverConfigMasqueradeFile{
@@ -226,6 +251,7 @@ func TestServerConfig(t *testing.T) {
 			ListenHTTPS: ":443",
 			ForceHTTPS:  true,
 		},
+		DrainTimeout: 30 * time.Second,
 	})
 }
 
@@ -0,0 +1,227 @@
+package clientapi
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/core/v2/client"
+)
+
+const (
+	indexHTML = `<!DOCTYPE html><html lang="en"><head> <meta charset="UTF-8"> <meta name="viewport" content="width=device-width, initial-scale=1.0"> <title>Hysteria Client API Server</title> <style>body{font-family: Arial, sans-serif; display: flex; justify-content: center; align-items: center; height: 100vh; margin: 0; padding: 0; background-color: #f4f4f4;}.container{padding: 20px; background-color: #fff; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1); border-radius: 5px;}</style></head><body> <div class="container"> <p>This is a Hysteria Client API server.</p><p>Check the documentation for usage.</p></div></body></html>`
+)
+
+// Server is a simple HTTP API to inspect and control a running client,
+// in the same style as the server side traffic stats API.
+type Server struct {
+	HyClient client.ReconnectableClient
+	Secret   string // required in the Authorization header if set, only leave empty on loopback
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	return http.Serve(listener, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Secret != "" && r.Header.Get("Authorization") != s.Secret {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		_, _ = w.Write([]byte(indexHTML))
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/state" {
+		s.getState(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/flows" {
+		s.getFlows(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/reconnect" {
+		s.reconnect(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/close" {
+		s.close(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/bandwidth" {
+		s.bandwidth(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+type stateEntry struct {
+	Connected   bool        `json:"connected"`
+	ServerAddr  string      `json:"server_addr,omitempty"`
+	UDPEnabled  bool        `json:"udp_enabled"`
+	BandwidthTx uint64      `json:"bandwidth_tx"` // Negotiated, 0 if using BBR
+	Tx          uint64      `json:"tx"`
+	Rx          uint64      `json:"rx"`
+	Streams     int         `json:"streams"`
+	UDPSessions int         `json:"udp_sessions"`
+	Reconnects  int         `json:"reconnects"`
+	RTT         int64       `json:"rtt"` // milliseconds
+	Loss        float64     `json:"loss"`
+	Paths       []pathEntry `json:"paths"`
+}
+
+type pathEntry struct {
+	SmoothedRTT      float64 `json:"smoothed_rtt"` // milliseconds
+	MinRTT           float64 `json:"min_rtt"`      // milliseconds
+	CongestionWindow uint64  `json:"cwnd"`
+	BytesInFlight    uint64  `json:"bytes_in_flight"`
+	PacketsSent      uint64  `json:"packets_sent"`
+	PacketsLost      uint64  `json:"packets_lost"`
+	BytesLost        uint64  `json:"bytes_lost"`
+	MTU              uint64  `json:"mtu"`
+	Congestion       string  `json:"congestion"`
+	BrutalAckRate    float64 `json:"brutal_ack_rate,omitempty"`
+	BrutalBandwidth  uint64  `json:"brutal_bandwidth,omitempty"`
+	BBRMode          string  `json:"bbr_mode,omitempty"`
+	BBRBandwidth     uint64  `json:"bbr_bandwidth,omitempty"` // bytes per second
+}
+
+func (s *Server) getState(w http.ResponseWriter, r *http.Request) {
+	stats := s.HyClient.Stats()
+	entry := stateEntry{
+		Tx:          stats.Tx,
+		Rx:          stats.Rx,
+		Streams:     stats.ActiveStreams,
+		UDPSessions: stats.ActiveUDPSessions,
+		Reconnects:  stats.ReconnectCount,
+		RTT:         stats.RTT.Milliseconds(),
+		Loss:        stats.Loss,
+		Paths:       make([]pathEntry, len(stats.Paths)),
+	}
+	for i, p := range stats.Paths {
+		entry.Paths[i] = pathEntry{
+			SmoothedRTT:      float64(p.SmoothedRTT) / float64(time.Millisecond),
+			MinRTT:           float64(p.MinRTT) / float64(time.Millisecond),
+			CongestionWindow: p.CongestionWindow,
+			BytesInFlight:    p.BytesInFlight,
+			PacketsSent:      p.PacketsSent,
+			PacketsLost:      p.PacketsLost,
+			BytesLost:        p.BytesLost,
+			MTU:              p.MTU,
+			Congestion:       p.Congestion,
+			BrutalAckRate:    p.BrutalAckRate,
+			BrutalBandwidth:  p.BrutalBandwidth,
+			BBRMode:          p.BBRMode,
+			BBRBandwidth:     p.BBRBandwidth,
+		}
+	}
+	if info := s.HyClient.HandshakeInfo(); info != nil {
+		entry.Connected = true
+		entry.ServerAddr = info.ServerAddr.String()
+		entry.UDPEnabled = info.UDPEnabled
+		entry.BandwidthTx = info.Tx
+	}
+	writeJSON(w, &entry)
+}
+
+type flowEntry struct {
+	ID        uint64 `json:"id"`
+	Type      string `json:"type"`
+	ReqAddr   string `json:"req_addr"` // the last address sent to for UDP
+	Tx        uint64 `json:"tx"`
+	Rx        uint64 `json:"rx"`
+	InitialAt string `json:"initial_at"`
+}
+
+func (s *Server) getFlows(w http.ResponseWriter, r *http.Request) {
+	stats := s.HyClient.Stats()
+	entries := make([]flowEntry, len(stats.Conns))
+	for i, c := range stats.Conns {
+		entries[i] = flowEntry{
+			ID:        c.ID,
+			Type:      c.Type,
+			ReqAddr:   c.Addr,
+			Tx:        c.Tx,
+			Rx:        c.Rx,
+			InitialAt: c.Since.Format(time.RFC3339Nano),
+		}
+	}
+	wrapper := struct {
+		Flows []flowEntry `json:"flows"`
+	}{entries}
+	writeJSON(w, &wrapper)
+}
+
+func (s *Server) reconnect(w http.ResponseWriter, r *http.Request) {
+	if err := s.HyClient.Reconnect(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// close closes the flows whose IDs are given as a JSON array in the body.
+// IDs of flows that are already gone are ignored.
+func (s *Server) close(w http.ResponseWriter, r *http.Request) {
+	var ids []uint64
+	err := json.NewDecoder(r.Body).Decode(&ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, id := range ids {
+		s.HyClient.CloseConn(id)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type bandwidthEntry struct {
+	Up   string `json:"up"`
+	Down string `json:"down"`
+}
+
+// bandwidth changes the bandwidth of the current connection without
+// reconnecting. Each value is a bandwidth string like "20 mbps",
+// an empty one means unknown, the same as leaving it out in the config.
+func (s *Server) bandwidth(w http.ResponseWriter, r *http.Request) {
+	var entry bandwidthEntry
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	up, err := parseBandwidth(entry.Up)
+	if err != nil {
+		http.Error(w, "up: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	down, err := parseBandwidth(entry.Down)
+	if err != nil {
+		http.Error(w, "down: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.HyClient.SetBandwidth(up, down); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func parseBandwidth(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return utils.StringToBps(s)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	jb, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(jb)
+}
@@ -0,0 +1,148 @@
+package clientapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/core/v2/client"
+)
+
+type mockHyClient struct {
+	info       *client.HandshakeInfo
+	stats      client.Stats
+	closed     []uint64
+	reconnects int
+	bandwidth  [2]uint64
+}
+
+func (c *mockHyClient) TCP(addr string) (net.Conn, error) {
+	return nil, nil
+}
+
+func (c *mockHyClient) UDP() (client.HyUDPConn, error) {
+	return nil, nil
+}
+
+func (c *mockHyClient) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	return nil, nil
+}
+
+func (c *mockHyClient) UDPContext(ctx context.Context) (client.HyUDPConn, error) {
+	return nil, nil
+}
+
+func (c *mockHyClient) Close() error {
+	return nil
+}
+
+func (c *mockHyClient) Stats() client.Stats {
+	return c.stats
+}
+
+func (c *mockHyClient) CloseConn(id uint64) bool {
+	c.closed = append(c.closed, id)
+	return true
+}
+
+func (c *mockHyClient) Reconnect() error {
+	c.reconnects++
+	return nil
+}
+
+func (c *mockHyClient) HandshakeInfo() *client.HandshakeInfo {
+	return c.info
+}
+
+func (c *mockHyClient) SetConfigFunc(configFunc func() (*client.Config, error)) {}
+
+func (c *mockHyClient) SetServerGroup(group *client.ServerGroup) {}
+
+func (c *mockHyClient) SetMessageFunc(f func(msg client.ControlMessage)) {}
+
+func (c *mockHyClient) SetBandwidth(tx, rx uint64) error {
+	c.bandwidth = [2]uint64{tx, rx}
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	hc := &mockHyClient{
+		info: &client.HandshakeInfo{
+			UDPEnabled: true,
+			Tx:         12345,
+			ServerAddr: &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 443},
+		},
+		stats: client.Stats{
+			Tx:            100,
+			Rx:            200,
+			ActiveStreams: 1,
+			RTT:           50 * time.Millisecond,
+			Paths: []client.PathStats{
+				{SmoothedRTT: 50 * time.Millisecond, MinRTT: 40 * time.Millisecond, MTU: 1350, Congestion: "bbr", BBRMode: "probeBW"},
+			},
+			Conns: []client.ConnStats{
+				{ID: 7, Type: client.ConnTypeTCP, Addr: "example.com:80", Tx: 10, Rx: 20, Since: time.Now()},
+			},
+		},
+	}
+	s := &Server{HyClient: hc, Secret: "hunter2"}
+
+	do := func(method, path, body string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if auth {
+			req.Header.Set("Authorization", "hunter2")
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/state", "", false).Code)
+
+	rec := do(http.MethodGet, "/state", "", true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var state stateEntry
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	assert.Equal(t, stateEntry{
+		Connected:   true,
+		ServerAddr:  "1.2.3.4:443",
+		UDPEnabled:  true,
+		BandwidthTx: 12345,
+		Tx:          100,
+		Rx:          200,
+		Streams:     1,
+		RTT:         50,
+		Paths: []pathEntry{
+			{SmoothedRTT: 50, MinRTT: 40, MTU: 1350, Congestion: "bbr", BBRMode: "probeBW"},
+		},
+	}, state)
+
+	rec = do(http.MethodGet, "/flows", "", true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var flows struct {
+		Flows []flowEntry `json:"flows"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flows))
+	if assert.Len(t, flows.Flows, 1) {
+		assert.Equal(t, uint64(7), flows.Flows[0].ID)
+		assert.Equal(t, "example.com:80", flows.Flows[0].ReqAddr)
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/close", "[7, 8]", true).Code)
+	assert.Equal(t, []uint64{7, 8}, hc.closed)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/close", "nope", true).Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/reconnect", "", true).Code)
+	assert.Equal(t, 1, hc.reconnects)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/bandwidth", `{"up": "8 mbps", "down": "80 mbps"}`, true).Code)
+	assert.Equal(t, [2]uint64{1000000, 10000000}, hc.bandwidth)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/bandwidth", `{"up": "fast"}`, true).Code)
+}
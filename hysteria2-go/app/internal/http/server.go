@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/apernet/hysteria/app/v2/internal/utils"
 	"github.com/apernet/hysteria/core/v2/client"
 )
 
@@ -155,8 +156,10 @@ func (s *Server) handleConnect(conn net.Conn, req *http.Request) {
 		}
 	}()
 
-	// Dial
-	rConn, err := s.HyClient.TCP(reqAddr)
+	// Dial, giving up if the client goes away in the meantime
+	ctx, stop := utils.WatchConn(context.Background(), conn)
+	rConn, err := s.HyClient.TCPContext(ctx, reqAddr)
+	conn = stop()
 	if err != nil {
 		_ = sendSimpleResponse(conn, req, http.StatusBadGateway)
 		closeErr = err
@@ -232,8 +235,7 @@ func (s *Server) initHTTPClient() {
 	s.httpClient = &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				// HyClient doesn't support context for now
-				return s.HyClient.TCP(addr)
+				return s.HyClient.TCPContext(ctx, addr)
 			},
 		},
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -2,6 +2,7 @@ package http
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"net"
 	"net/http"
@@ -31,10 +32,27 @@ func (c *mockHyClient) UDP() (client.HyUDPConn, error) {
 	return nil, errors.New("not implemented")
 }
 
+func (c *mockHyClient) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (c *mockHyClient) UDPContext(ctx context.Context) (client.HyUDPConn, error) {
+	return c.UDP()
+}
+
 func (c *mockHyClient) Close() error {
 	return nil
 }
 
+func (c *mockHyClient) Stats() client.Stats {
+	return client.Stats{}
+}
+
+func (c *mockHyClient) CloseConn(id uint64) bool {
+	return false
+}
+
 func TestServer(t *testing.T) {
 	// Start the server
 	l, err := net.Listen("tcp", "127.0.0.1:18080")
@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"unsafe"
 
+	"github.com/apernet/hysteria/app/v2/internal/utils"
 	"github.com/apernet/hysteria/core/v2/client"
 )
 
@@ -19,6 +20,8 @@ const (
 type TCPRedirect struct {
 	HyClient    client.Client
 	EventLogger TCPEventLogger
+
+	closers utils.CloseGroup
 }
 
 type TCPEventLogger interface {
@@ -31,7 +34,10 @@ func (r *TCPRedirect) ListenAndServe(laddr *net.TCPAddr) error {
 	if err != nil {
 		return err
 	}
-	defer listener.Close()
+	if err := r.closers.Add(listener); err != nil {
+		return err
+	}
+	defer r.closers.Close()
 	for {
 		c, err := listener.AcceptTCP()
 		if err != nil {
@@ -41,6 +47,12 @@ func (r *TCPRedirect) ListenAndServe(laddr *net.TCPAddr) error {
 	}
 }
 
+// Close stops a running ListenAndServe.
+// Connections that are already established are not affected.
+func (r *TCPRedirect) Close() error {
+	return r.closers.Close()
+}
+
 func (r *TCPRedirect) handle(conn *net.TCPConn) {
 	defer conn.Close()
 	dstAddr, err := getDstAddr(conn)
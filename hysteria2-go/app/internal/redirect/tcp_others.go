@@ -22,3 +22,7 @@ type TCPEventLogger interface {
 func (r *TCPRedirect) ListenAndServe(laddr *net.TCPAddr) error {
 	return errors.New("not supported on this platform")
 }
+
+func (r *TCPRedirect) Close() error {
+	return nil
+}
@@ -0,0 +1,356 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/extras/v2/outbounds/acl"
+)
+
+const (
+	aclCacheSize  = 1024
+	udpBufferSize = 65536
+)
+
+var errRejected = errors.New("rejected")
+
+// Outbound is where a connection is sent to after matching the rules.
+type Outbound int
+
+const (
+	// OutboundProxy sends the connection through Hysteria.
+	// It is the zero value, so destinations that match no rule are proxied.
+	OutboundProxy Outbound = iota
+	// OutboundDirect connects to the destination without going through Hysteria.
+	OutboundDirect
+	// OutboundReject refuses the connection.
+	OutboundReject
+)
+
+func (o Outbound) String() string {
+	switch o {
+	case OutboundProxy:
+		return "proxy"
+	case OutboundDirect:
+		return "direct"
+	case OutboundReject:
+		return "reject"
+	default:
+		return "Outbound(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// outbounds are the names usable in client-side rules.
+var outbounds = map[string]Outbound{
+	"proxy":  OutboundProxy,
+	"direct": OutboundDirect,
+	"reject": OutboundReject,
+}
+
+// RuleSet is a compiled set of client-side routing rules.
+type RuleSet = acl.CompiledRuleSet[Outbound]
+
+// NewRuleSetFromString compiles rules in the same text format as the server ACL,
+// with the outbounds "proxy", "direct" and "reject".
+func NewRuleSetFromString(rules string, geoLoader acl.GeoLoader) (RuleSet, error) {
+	trs, err := acl.ParseTextRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return acl.Compile[Outbound](trs, outbounds, aclCacheSize, geoLoader)
+}
+
+func NewRuleSetFromFile(filename string, geoLoader acl.GeoLoader) (RuleSet, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleSetFromString(string(bs), geoLoader)
+}
+
+// CheckRules checks the syntax and outbound names of rules. Unlike compiling them,
+// it does not load the GeoIP and GeoSite databases, so it can be used for validation.
+func CheckRules(rules string) error {
+	trs, err := acl.ParseTextRules(rules)
+	if err != nil {
+		return err
+	}
+	for _, tr := range trs {
+		if _, ok := outbounds[strings.ToLower(tr.Outbound)]; !ok {
+			return &acl.CompilationError{LineNum: tr.LineNum, Message: "outbound " + tr.Outbound + " not found"}
+		}
+	}
+	return nil
+}
+
+// Router is a Hysteria client that matches every TCP connection and UDP packet
+// against a RuleSet, and either sends it through HyClient, connects to the
+// destination directly, or rejects it.
+type Router struct {
+	HyClient client.Client
+	RuleSet  RuleSet
+	// Resolve makes the router resolve domain names locally before matching,
+	// so that IP, CIDR and GeoIP rules also apply to them.
+	// Proxied connections are still sent to the server by name.
+	Resolve     bool
+	EventLogger EventLogger
+}
+
+type EventLogger interface {
+	Match(network, reqAddr string, outbound Outbound)
+}
+
+var _ client.Client = (*Router)(nil)
+
+// match returns the outbound for the address, along with the address
+// to connect to, which differs from reqAddr if the rule hijacks it.
+func (r *Router) match(network, reqAddr string) (Outbound, string) {
+	host, portStr, err := net.SplitHostPort(reqAddr)
+	if err != nil {
+		// Let the proxy report the error
+		return OutboundProxy, reqAddr
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return OutboundProxy, reqAddr
+	}
+	proto := acl.ProtocolTCP
+	if network == "udp" {
+		proto = acl.ProtocolUDP
+	}
+	hostInfo := acl.HostInfo{Name: host}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			hostInfo.IPv4 = ip4
+		} else {
+			hostInfo.IPv6 = ip
+		}
+	} else if r.Resolve {
+		hostInfo.IPv4, hostInfo.IPv6 = resolve(host)
+	}
+	ob, hijackIP := r.RuleSet.Match(hostInfo, proto, uint16(port))
+	if hijackIP != nil {
+		reqAddr = net.JoinHostPort(hijackIP.String(), portStr)
+	}
+	if r.EventLogger != nil {
+		r.EventLogger.Match(network, reqAddr, ob)
+	}
+	return ob, reqAddr
+}
+
+func resolve(host string) (net.IP, net.IP) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, nil
+	}
+	var ipv4, ipv6 net.IP
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			if ipv4 == nil {
+				ipv4 = ip4
+			}
+		} else if ipv6 == nil {
+			ipv6 = ip
+		}
+	}
+	return ipv4, ipv6
+}
+
+func (r *Router) TCP(addr string) (net.Conn, error) {
+	return r.TCPContext(context.Background(), addr)
+}
+
+func (r *Router) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	ob, addr := r.match("tcp", addr)
+	switch ob {
+	case OutboundDirect:
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	case OutboundReject:
+		return nil, errRejected
+	default:
+		return r.HyClient.TCPContext(ctx, addr)
+	}
+}
+
+func (r *Router) UDP() (client.HyUDPConn, error) {
+	return r.UDPContext(context.Background())
+}
+
+// UDPContext returns right away. The Hysteria UDP session and the local UDP socket
+// are each opened on the first packet routed to them, so a session that only sends
+// directly works even when the server has UDP disabled.
+func (r *Router) UDPContext(ctx context.Context) (client.HyUDPConn, error) {
+	return &udpConn{
+		router:  r,
+		recvCh:  make(chan udpPacket),
+		closeCh: make(chan struct{}),
+	}, nil
+}
+
+// Close does nothing, as HyClient is shared with other modes and closed by its owner.
+func (r *Router) Close() error {
+	return nil
+}
+
+func (r *Router) Stats() client.Stats {
+	return r.HyClient.Stats()
+}
+
+func (r *Router) CloseConn(id uint64) bool {
+	return r.HyClient.CloseConn(id)
+}
+
+type udpPacket struct {
+	Data []byte
+	Addr string
+}
+
+// udpConn merges the packets received from the Hysteria session
+// and the direct socket into a single HyUDPConn.
+type udpConn struct {
+	router *Router
+
+	mutex      sync.Mutex
+	hyConn     client.HyUDPConn // nil until the first proxied packet
+	directConn net.PacketConn   // nil until the first direct packet
+
+	recvCh    chan udpPacket
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	errOnce   sync.Once
+	err       error // set before closeCh is closed
+}
+
+func (u *udpConn) receiveLoop(receive func() ([]byte, string, error)) {
+	for {
+		data, addr, err := receive()
+		if err != nil {
+			u.fail(err)
+			return
+		}
+		select {
+		case u.recvCh <- udpPacket{data, addr}:
+		case <-u.closeCh:
+			return
+		}
+	}
+}
+
+// fail records the first error from either source and ends the session.
+func (u *udpConn) fail(err error) {
+	u.errOnce.Do(func() {
+		u.err = err
+	})
+	_ = u.Close()
+}
+
+func (u *udpConn) Receive() ([]byte, string, error) {
+	select {
+	case p := <-u.recvCh:
+		return p.Data, p.Addr, nil
+	case <-u.closeCh:
+		u.errOnce.Do(func() {
+			u.err = net.ErrClosed
+		})
+		return nil, "", u.err
+	}
+}
+
+// Send routes each packet separately. Rejected packets are dropped silently,
+// as an error would end the whole session along with its other destinations.
+func (u *udpConn) Send(data []byte, addr string) error {
+	ob, addr := u.router.match("udp", addr)
+	switch ob {
+	case OutboundDirect:
+		pc, err := u.direct()
+		if err != nil {
+			return err
+		}
+		uAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			return err
+		}
+		_, err = pc.WriteTo(data, uAddr)
+		return err
+	case OutboundReject:
+		return nil
+	default:
+		hyConn, err := u.proxy()
+		if err != nil {
+			return err
+		}
+		return hyConn.Send(data, addr)
+	}
+}
+
+func (u *udpConn) proxy() (client.HyUDPConn, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	select {
+	case <-u.closeCh:
+		return nil, net.ErrClosed
+	default:
+	}
+	if u.hyConn != nil {
+		return u.hyConn, nil
+	}
+	hyConn, err := u.router.HyClient.UDP()
+	if err != nil {
+		return nil, err
+	}
+	u.hyConn = hyConn
+	go u.receiveLoop(func() ([]byte, string, error) {
+		return hyConn.Receive()
+	})
+	return hyConn, nil
+}
+
+func (u *udpConn) direct() (net.PacketConn, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	select {
+	case <-u.closeCh:
+		return nil, net.ErrClosed
+	default:
+	}
+	if u.directConn != nil {
+		return u.directConn, nil
+	}
+	pc, err := net.ListenPacket("udp", "")
+	if err != nil {
+		return nil, err
+	}
+	u.directConn = pc
+	go u.receiveLoop(func() ([]byte, string, error) {
+		buf := make([]byte, udpBufferSize)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return nil, "", err
+		}
+		return buf[:n], addr.String(), nil
+	})
+	return pc, nil
+}
+
+func (u *udpConn) Close() error {
+	var err error
+	u.closeOnce.Do(func() {
+		u.mutex.Lock()
+		close(u.closeCh)
+		if u.directConn != nil {
+			_ = u.directConn.Close()
+		}
+		if u.hyConn != nil {
+			err = u.hyConn.Close()
+		}
+		u.mutex.Unlock()
+	})
+	return err
+}
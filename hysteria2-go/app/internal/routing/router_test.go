@@ -0,0 +1,177 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils_test"
+	"github.com/apernet/hysteria/core/v2/client"
+)
+
+const testRules = `
+direct(127.0.0.1)
+direct(hijack.example.com, tcp, 127.0.0.1)
+reject(ads.example.com)
+`
+
+func TestCheckRules(t *testing.T) {
+	assert.NoError(t, CheckRules(testRules))
+	assert.Error(t, CheckRules("direct(127.0.0.1"))
+	assert.Error(t, CheckRules("block(ads.example.com)"))
+}
+
+func TestRouterTCP(t *testing.T) {
+	rs, err := NewRuleSetFromString(testRules, nil)
+	assert.NoError(t, err)
+	r := &Router{
+		HyClient: &utils_test.MockEchoHyClient{},
+		RuleSet:  rs,
+	}
+
+	// Direct connections reach the local server, which greets them
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("direct"))
+			_ = conn.Close()
+		}
+	}()
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	for _, addr := range []string{
+		l.Addr().String(),
+		net.JoinHostPort("hijack.example.com", port),
+	} {
+		conn, err := r.TCP(addr)
+		assert.NoError(t, err)
+		bs, err := io.ReadAll(conn)
+		assert.NoError(t, err)
+		assert.Equal(t, "direct", string(bs))
+		_ = conn.Close()
+	}
+
+	// Rejected
+	_, err = r.TCP("ads.example.com:443")
+	assert.Error(t, err)
+
+	// Everything else goes through the (echo) proxy
+	conn, err := r.TCP("example.com:443")
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("proxy"))
+	assert.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy", string(buf))
+	_ = conn.Close()
+}
+
+func TestRouterUDP(t *testing.T) {
+	rs, err := NewRuleSetFromString(testRules, nil)
+	assert.NoError(t, err)
+	r := &Router{
+		HyClient: &utils_test.MockEchoHyClient{},
+		RuleSet:  rs,
+	}
+
+	// Local UDP echo server for direct packets
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	u, err := r.UDP()
+	assert.NoError(t, err)
+	defer u.Close()
+
+	// Direct
+	assert.NoError(t, u.Send([]byte("direct"), pc.LocalAddr().String()))
+	data, addr, err := u.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, "direct", string(data))
+	assert.Equal(t, pc.LocalAddr().String(), addr)
+
+	// Rejected packets are dropped without error
+	assert.NoError(t, u.Send([]byte("reject"), "ads.example.com:53"))
+
+	// Proxy
+	assert.NoError(t, u.Send([]byte("proxy"), "example.com:53"))
+	data, addr, err = u.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy", string(data))
+	assert.Equal(t, "example.com:53", addr)
+
+	// Closed
+	assert.NoError(t, u.Close())
+	_, _, err = u.Receive()
+	assert.Error(t, err)
+}
+
+// noUDPHyClient behaves like a server with UDP disabled.
+type noUDPHyClient struct {
+	utils_test.MockEchoHyClient
+}
+
+func (c *noUDPHyClient) UDP() (client.HyUDPConn, error) {
+	return nil, errors.New("UDP disabled")
+}
+
+func (c *noUDPHyClient) UDPContext(ctx context.Context) (client.HyUDPConn, error) {
+	return c.UDP()
+}
+
+func TestRouterUDPDirectOnly(t *testing.T) {
+	rs, err := NewRuleSetFromString(testRules, nil)
+	assert.NoError(t, err)
+	r := &Router{
+		HyClient: &noUDPHyClient{},
+		RuleSet:  rs,
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	// The proxy session is not opened until something is proxied
+	u, err := r.UDP()
+	assert.NoError(t, err)
+	defer u.Close()
+
+	assert.NoError(t, u.Send([]byte("direct"), pc.LocalAddr().String()))
+	data, _, err := u.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, "direct", string(data))
+
+	assert.Error(t, u.Send([]byte("proxy"), "example.com:53"))
+}
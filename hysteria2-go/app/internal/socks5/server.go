@@ -1,12 +1,14 @@
 package socks5
 
 import (
+	"context"
 	"encoding/binary"
 	"io"
 	"net"
 
 	"github.com/txthinking/socks5"
 
+	"github.com/apernet/hysteria/app/v2/internal/utils"
 	"github.com/apernet/hysteria/core/v2/client"
 )
 
@@ -134,8 +136,10 @@ func (s *Server) handleTCP(conn net.Conn, req *socks5.Request) {
 		}
 	}()
 
-	// Dial
-	rConn, err := s.HyClient.TCP(addr)
+	// Dial, giving up if the client goes away in the meantime
+	ctx, stop := utils.WatchConn(context.Background(), conn)
+	rConn, err := s.HyClient.TCPContext(ctx, addr)
+	conn = stop()
 	if err != nil {
 		_ = sendSimpleReply(conn, socks5.RepHostUnreachable)
 		closeErr = err
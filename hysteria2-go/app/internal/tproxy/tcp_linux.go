@@ -5,12 +5,15 @@ import (
 	"net"
 
 	"github.com/apernet/go-tproxy"
+	"github.com/apernet/hysteria/app/v2/internal/utils"
 	"github.com/apernet/hysteria/core/v2/client"
 )
 
 type TCPTProxy struct {
 	HyClient    client.Client
 	EventLogger TCPEventLogger
+
+	closers utils.CloseGroup
 }
 
 type TCPEventLogger interface {
@@ -23,7 +26,10 @@ func (r *TCPTProxy) ListenAndServe(laddr *net.TCPAddr) error {
 	if err != nil {
 		return err
 	}
-	defer listener.Close()
+	if err := r.closers.Add(listener); err != nil {
+		return err
+	}
+	defer r.closers.Close()
 	for {
 		c, err := listener.Accept()
 		if err != nil {
@@ -33,6 +39,12 @@ func (r *TCPTProxy) ListenAndServe(laddr *net.TCPAddr) error {
 	}
 }
 
+// Close stops a running ListenAndServe.
+// Connections that are already established are not affected.
+func (r *TCPTProxy) Close() error {
+	return r.closers.Close()
+}
+
 func (r *TCPTProxy) handle(conn net.Conn) {
 	defer conn.Close()
 	// In TProxy mode, we are masquerading as the remote server.
@@ -22,3 +22,7 @@ type TCPEventLogger interface {
 func (r *TCPTProxy) ListenAndServe(laddr *net.TCPAddr) error {
 	return errors.New("not supported on this platform")
 }
+
+func (r *TCPTProxy) Close() error {
+	return nil
+}
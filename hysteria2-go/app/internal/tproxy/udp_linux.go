@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/apernet/go-tproxy"
+	"github.com/apernet/hysteria/app/v2/internal/utils"
 	"github.com/apernet/hysteria/core/v2/client"
 )
 
@@ -18,6 +19,8 @@ type UDPTProxy struct {
 	HyClient    client.Client
 	Timeout     time.Duration
 	EventLogger UDPEventLogger
+
+	closers utils.CloseGroup
 }
 
 type UDPEventLogger interface {
@@ -30,7 +33,10 @@ func (r *UDPTProxy) ListenAndServe(laddr *net.UDPAddr) error {
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
+	if err := r.closers.Add(conn); err != nil {
+		return err
+	}
+	defer r.closers.Close()
 	buf := make([]byte, udpBufferSize)
 	for {
 		// We will only get the first packet of each src/dst pair here,
@@ -44,6 +50,12 @@ func (r *UDPTProxy) ListenAndServe(laddr *net.UDPAddr) error {
 	}
 }
 
+// Close stops a running ListenAndServe.
+// Existing src/dst pairs keep forwarding until they time out.
+func (r *UDPTProxy) Close() error {
+	return r.closers.Close()
+}
+
 func (r *UDPTProxy) newPair(srcAddr, dstAddr *net.UDPAddr, initPkt []byte) {
 	if r.EventLogger != nil {
 		r.EventLogger.Connect(srcAddr, dstAddr)
@@ -24,3 +24,7 @@ type UDPEventLogger interface {
 func (r *UDPTProxy) ListenAndServe(laddr *net.UDPAddr) error {
 	return errors.New("not supported on this platform")
 }
+
+func (r *UDPTProxy) Close() error {
+	return nil
+}
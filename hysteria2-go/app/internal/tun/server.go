@@ -14,6 +14,7 @@ import (
 	"github.com/sagernet/sing/common/network"
 	"go.uber.org/zap"
 
+	"github.com/apernet/hysteria/app/v2/internal/utils"
 	"github.com/apernet/hysteria/core/v2/client"
 )
 
@@ -39,6 +40,8 @@ type Server struct {
 	Inet6RouteAddress        []netip.Prefix
 	Inet4RouteExcludeAddress []netip.Prefix
 	Inet6RouteExcludeAddress []netip.Prefix
+
+	closers utils.CloseGroup
 }
 
 type EventLogger interface {
@@ -74,7 +77,10 @@ func (s *Server) Serve() error {
 	if err != nil {
 		return fmt.Errorf("failed to create tun interface: %w", err)
 	}
-	defer tunIf.Close()
+	defer s.closers.Close()
+	if err := s.closers.Add(tunIf); err != nil {
+		return err
+	}
 
 	tunStack, err := tun.NewSystem(tun.StackOptions{
 		Context:    context.Background(),
@@ -92,10 +98,17 @@ func (s *Server) Serve() error {
 	if err != nil {
 		return fmt.Errorf("failed to create tun stack: %w", err)
 	}
-	defer tunStack.Close()
+	if err := s.closers.Add(tunStack); err != nil {
+		return err
+	}
 	return tunStack.(tun.StackRunner).Run()
 }
 
+// Close stops a running Serve, tearing down the TUN stack and interface.
+func (s *Server) Close() error {
+	return s.closers.Close()
+}
+
 type tunHandler struct {
 	*Server
 }
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// CloseGroup collects the resources (listeners, interfaces, etc.) opened by
+// a blocking server, so that another goroutine can release all of them and
+// make the server return. Once Close has been called, anything added
+// afterwards is closed immediately.
+// The zero value is ready to use.
+type CloseGroup struct {
+	mu      sync.Mutex
+	closers []io.Closer
+	closed  bool
+}
+
+// Add registers c to be closed by Close.
+// If the group is already closed, c is closed right away and net.ErrClosed
+// is returned, so the caller can abort whatever it was about to serve.
+func (g *CloseGroup) Add(c io.Closer) error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		_ = c.Close()
+		return net.ErrClosed
+	}
+	g.closers = append(g.closers, c)
+	g.mu.Unlock()
+	return nil
+}
+
+// Close closes everything added so far in reverse order of addition.
+// It is safe to call Close multiple times.
+func (g *CloseGroup) Close() error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	g.closed = true
+	closers := g.closers
+	g.closers = nil
+	g.mu.Unlock()
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCloser struct {
+	id    int
+	order *[]int
+	err   error
+}
+
+func (c *testCloser) Close() error {
+	*c.order = append(*c.order, c.id)
+	return c.err
+}
+
+func TestCloseGroup(t *testing.T) {
+	var order []int
+	var g CloseGroup
+	assert.NoError(t, g.Add(&testCloser{id: 1, order: &order}))
+	assert.NoError(t, g.Add(&testCloser{id: 2, order: &order}))
+	assert.NoError(t, g.Close())
+	assert.Equal(t, []int{2, 1}, order)
+
+	// Closing again is a no-op
+	assert.NoError(t, g.Close())
+	assert.Equal(t, []int{2, 1}, order)
+
+	// Anything added after Close is closed immediately
+	err := g.Add(&testCloser{id: 3, order: &order})
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.Equal(t, []int{2, 1, 3}, order)
+}
+
+func TestCloseGroupErrors(t *testing.T) {
+	var order []int
+	var g CloseGroup
+	errA, errB := errors.New("a"), errors.New("b")
+	assert.NoError(t, g.Add(&testCloser{id: 1, order: &order, err: errA}))
+	assert.NoError(t, g.Add(&testCloser{id: 2, order: &order}))
+	assert.NoError(t, g.Add(&testCloser{id: 3, order: &order, err: errB}))
+	err := g.Close()
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+	assert.Equal(t, []int{3, 2, 1}, order)
+}
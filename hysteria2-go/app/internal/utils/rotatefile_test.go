@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	f := &RotatingFile{Path: path, MaxSize: 10, MaxBackups: 2}
+	defer f.Close()
+
+	for _, s := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		_, err := f.Write([]byte(s))
+		assert.NoError(t, err)
+	}
+
+	read := func(p string) string {
+		bs, err := os.ReadFile(p)
+		assert.NoError(t, err)
+		return string(bs)
+	}
+	assert.Equal(t, "dddddd\n", read(path))
+	assert.Equal(t, "cccccc\n", read(path+".1"))
+	assert.Equal(t, "bbbbbb\n", read(path+".2"))
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRotatingFileNoRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	assert.NoError(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+	f := &RotatingFile{Path: path}
+	_, err := f.Write([]byte("new\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	bs, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "old\nnew\n", string(bs))
+}
@@ -53,9 +53,8 @@ func NewClientUpdateChecker(currentVersion, platform, architecture, channel stri
 		Client: &http.Client{
 			Timeout: updateCheckTimeout,
 			Transport: &http.Transport{
-				DialContext: func(_ context.Context, network, addr string) (net.Conn, error) {
-					// Unfortunately HyClient doesn't support context for now
-					return hyClient.TCP(addr)
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return hyClient.TCPContext(ctx, addr)
 				},
 			},
 		},
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// WatchConn returns a context that is canceled when the peer of conn closes it,
+// so that a dial made on its behalf can be abandoned. It works by reading from conn
+// in the background until stop is called. stop returns the conn to use from then on,
+// which replays anything the peer sent in the meantime.
+func WatchConn(ctx context.Context, conn net.Conn) (context.Context, func() net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	var buf bytes.Buffer
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		b := make([]byte, 4096)
+		for {
+			n, err := conn.Read(b)
+			buf.Write(b[:n])
+			if err != nil {
+				if !errors.Is(err, os.ErrDeadlineExceeded) {
+					cancel()
+				}
+				return
+			}
+		}
+	}()
+	stop := func() net.Conn {
+		_ = conn.SetReadDeadline(time.Now())
+		<-doneCh
+		_ = conn.SetReadDeadline(time.Time{})
+		cancel()
+		if buf.Len() == 0 {
+			return conn
+		}
+		return &replayConn{Conn: conn, Buffer: buf}
+	}
+	return ctx, stop
+}
+
+// replayConn is a net.Conn wrapper that first Read()s from a buffer,
+// and then from the underlying net.Conn when the buffer is drained.
+type replayConn struct {
+	net.Conn
+	Buffer bytes.Buffer
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	if c.Buffer.Len() > 0 {
+		n, err := c.Buffer.Read(b)
+		if err == io.EOF {
+			// Buffer is drained, hide it from the caller
+			err = nil
+		}
+		return n, err
+	}
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchConnReplay(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	ctx, stop := WatchConn(context.Background(), c1)
+
+	_, err := c2.Write([]byte("hello"))
+	assert.NoError(t, err)
+	select {
+	case <-ctx.Done():
+		t.Fatal("context canceled while the peer is still connected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	conn := stop()
+	defer conn.Close()
+	go func() {
+		_, _ = c2.Write([]byte(" world"))
+	}()
+	b := make([]byte, 11)
+	_, err = io.ReadFull(conn, b)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+}
+
+func TestWatchConnClose(t *testing.T) {
+	c1, c2 := net.Pipe()
+	ctx, stop := WatchConn(context.Background(), c1)
+	_ = c2.Close()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not canceled after the peer closed")
+	}
+	_ = stop().Close()
+}
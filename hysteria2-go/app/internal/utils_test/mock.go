@@ -1,6 +1,7 @@
 package utils_test
 
 import (
+	"context"
 	"io"
 	"net"
 	"time"
@@ -22,10 +23,26 @@ func (c *MockEchoHyClient) UDP() (client.HyUDPConn, error) {
 	}, nil
 }
 
+func (c *MockEchoHyClient) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	return c.TCP(addr)
+}
+
+func (c *MockEchoHyClient) UDPContext(ctx context.Context) (client.HyUDPConn, error) {
+	return c.UDP()
+}
+
 func (c *MockEchoHyClient) Close() error {
 	return nil
 }
 
+func (c *MockEchoHyClient) Stats() client.Stats {
+	return client.Stats{}
+}
+
+func (c *MockEchoHyClient) CloseConn(id uint64) bool {
+	return false
+}
+
 type mockEchoTCPConn struct {
 	BufChan chan []byte
 }
@@ -2,13 +2,238 @@ package main
 
 /*
 #include <stdlib.h>
+
+typedef void (*eventCallback)(long long handle, const char* event, void* userData);
+
+static inline void callEventCallback(eventCallback cb, long long handle, const char* event, void* userData)
+{
+    cb(handle, event, userData);
+}
+
+typedef void (*logCallback)(const char* line, void* userData);
+
+static inline void callLogCallback(logCallback cb, const char* line, void* userData)
+{
+    cb(line, userData);
+}
 */
 import "C"
-import "github.com/apernet/hysteria/app/v2/cmd"
 
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/apernet/hysteria/app/v2/cmd"
+)
+
+var (
+	clientsMutex sync.Mutex
+	clients      = make(map[int64]*cmd.ClientInstance)
+	clientsNext  int64
+
+	eventMutex    sync.RWMutex
+	eventCb       C.eventCallback
+	eventUserData unsafe.Pointer
+)
+
+// callbackSink forwards the events of one client to the registered C callback
+// as JSON, along with the handle of the client (0 for startClientFromJSON).
+type callbackSink struct {
+	handle int64
+}
+
+func (s callbackSink) Event(e cmd.Event) {
+	eventMutex.RLock()
+	defer eventMutex.RUnlock()
+	if eventCb == nil {
+		return
+	}
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	cs := C.CString(string(bs))
+	defer C.free(unsafe.Pointer(cs))
+	C.callEventCallback(eventCb, C.longlong(s.handle), cs, eventUserData)
+}
+
+// setEventCallback registers the callback that receives the events of all clients,
+// replacing the previous one. Pass NULL to unregister. The event string is only
+// valid for the duration of the call. The callback is invoked from Go threads,
+// and setEventCallback waits for running invocations to return before replacing it.
+//
+//export setEventCallback
+func setEventCallback(cb C.eventCallback, userData unsafe.Pointer) {
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	eventCb = cb
+	eventUserData = userData
+}
+
+// setLogCallback makes the log entries of all clients, formatted as single lines,
+// go to the callback instead of stderr. Pass NULL to go back to stderr.
+// The line is only valid for the duration of the call. The callback is invoked
+// from Go threads, possibly concurrently, and may still be invoked for a short
+// while after it has been replaced.
+//
+//export setLogCallback
+func setLogCallback(cb C.logCallback, userData unsafe.Pointer) {
+	if cb == nil {
+		cmd.SetLogCallback(nil)
+		return
+	}
+	cmd.SetLogCallback(func(line string) {
+		cs := C.CString(line)
+		defer C.free(unsafe.Pointer(cs))
+		C.callLogCallback(cb, cs, userData)
+	})
+}
+
+// setLogLevel changes the log level (debug, info, warn or error) at runtime.
+// It returns NULL on success, or the error encoded by errorString.
+//
+//export setLogLevel
+func setLogLevel(level string) *C.char {
+	if err := cmd.SetLogLevel(level); err != nil {
+		return errorString(&cmd.ClientError{Category: cmd.ErrorCategoryConfig, Message: err.Error(), Err: err})
+	}
+	return nil
+}
+
+// errorString encodes err as a JSON object with category, field and message,
+// or returns NULL if err is nil. The caller must release it with freeString.
+func errorString(err error) *C.char {
+	if err == nil {
+		return nil
+	}
+	var ce *cmd.ClientError
+	if !errors.As(err, &ce) {
+		ce = &cmd.ClientError{Category: cmd.ErrorCategoryRuntime, Message: err.Error()}
+	}
+	bs, _ := json.Marshal(ce)
+	return C.CString(string(bs))
+}
+
+//export freeString
+func freeString(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// validateClientJSON checks the config without connecting.
+// It returns NULL if the config is valid, or the error encoded by errorString.
+//
+//export validateClientJSON
+func validateClientJSON(json string) *C.char {
+	return errorString(cmd.ValidateClientJSON(strings.Clone(json)))
+}
+
+// startClientFromJSON runs the client until it stops. It returns NULL if it
+// stopped gracefully, or the error encoded by errorString.
+//
 //export startClientFromJSON
-func startClientFromJSON(json string) {
-	cmd.StartFromJSON(json)
+func startClientFromJSON(json string) *C.char {
+	return errorString(cmd.StartFromJSON(strings.Clone(json), callbackSink{}))
+}
+
+// startClient starts a client in the background and returns a handle
+// to be passed to waitClient and stopClient. On failure, it returns 0
+// and stores the error encoded by errorString in *errOut.
+//
+//export startClient
+func startClient(json string, errOut **C.char) int64 {
+	// Allocate the handle first, as events can be emitted before StartClient returns
+	clientsMutex.Lock()
+	clientsNext++
+	handle := clientsNext
+	clientsMutex.Unlock()
+	// The string is backed by memory owned by the caller, copy it before keeping it around
+	inst, err := cmd.StartClient(strings.Clone(json), callbackSink{handle: handle})
+	if err != nil {
+		*errOut = errorString(err)
+		return 0
+	}
+	*errOut = nil
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	clients[handle] = inst
+	return handle
+}
+
+// waitClient blocks until the client identified by handle stops. It returns
+// NULL if it was stopped by stopClient, or the runtime error encoded by errorString.
+//
+//export waitClient
+func waitClient(handle int64) *C.char {
+	clientsMutex.Lock()
+	inst, ok := clients[handle]
+	clientsMutex.Unlock()
+	if !ok {
+		return nil
+	}
+	return errorString(inst.Wait())
+}
+
+// clientStats returns the traffic counters of the client identified by handle
+// as a JSON object, or NULL if the handle is unknown. The caller must release it with freeString.
+//
+//export clientStats
+func clientStats(handle int64) *C.char {
+	clientsMutex.Lock()
+	inst, ok := clients[handle]
+	clientsMutex.Unlock()
+	if !ok {
+		return nil
+	}
+	bs, err := json.Marshal(inst.Stats())
+	if err != nil {
+		return nil
+	}
+	return C.CString(string(bs))
+}
+
+// reloadClient applies a new JSON config to the client identified by handle.
+// It returns NULL on success, or the error encoded by errorString.
+//
+//export reloadClient
+func reloadClient(handle int64, json string) *C.char {
+	clientsMutex.Lock()
+	inst, ok := clients[handle]
+	clientsMutex.Unlock()
+	if !ok {
+		return errorString(&cmd.ClientError{Category: cmd.ErrorCategoryRuntime, Message: "unknown client handle"})
+	}
+	return errorString(inst.Reload(strings.Clone(json)))
+}
+
+// setClientBandwidth changes the bandwidth of the client identified by handle
+// without reconnecting. It returns NULL on success, or the error encoded by errorString.
+//
+//export setClientBandwidth
+func setClientBandwidth(handle int64, up, down string) *C.char {
+	clientsMutex.Lock()
+	inst, ok := clients[handle]
+	clientsMutex.Unlock()
+	if !ok {
+		return errorString(&cmd.ClientError{Category: cmd.ErrorCategoryRuntime, Message: "unknown client handle"})
+	}
+	return errorString(inst.SetBandwidth(up, down))
+}
+
+// stopClient stops the client identified by handle and waits for it to shut down.
+// Unknown or already stopped handles are ignored.
+//
+//export stopClient
+func stopClient(handle int64) {
+	clientsMutex.Lock()
+	inst, ok := clients[handle]
+	delete(clients, handle)
+	clientsMutex.Unlock()
+	if ok {
+		_ = inst.Stop()
+	}
 }
 
 func main() {
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff computes the delays between reconnection attempts, as described in ReconnectConfig.
+// The config must have been filled.
+type backoff struct {
+	Config ReconnectConfig
+
+	delay time.Duration // before jitter, 0 if there has been no failure yet
+}
+
+// Next returns the delay to wait after a failed attempt.
+func (b *backoff) Next() time.Duration {
+	if b.delay == 0 {
+		b.delay = b.Config.InitialDelay
+	} else {
+		b.delay = time.Duration(float64(b.delay) * b.Config.Multiplier)
+		if b.delay > b.Config.MaxDelay || b.delay <= 0 {
+			// <= 0 in case of overflow
+			b.delay = b.Config.MaxDelay
+		}
+	}
+	if b.Config.Jitter == nil || *b.Config.Jitter == 0 {
+		return b.delay
+	}
+	return time.Duration(float64(b.delay) * (1 + *b.Config.Jitter*(2*rand.Float64()-1)))
+}
+
+// Reset starts over from InitialDelay, after a successful attempt.
+func (b *backoff) Reset() {
+	b.delay = 0
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := &backoff{Config: ReconnectConfig{
+		InitialDelay: 1 * time.Second,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+	}}
+	for _, want := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		assert.Equal(t, want*time.Second, b.Next())
+	}
+	b.Reset()
+	assert.Equal(t, 1*time.Second, b.Next())
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := &backoff{Config: ReconnectConfig{
+		InitialDelay: 10 * time.Second,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+		Jitter:       float64Ref(0.2),
+	}}
+	for i := 0; i < 100; i++ {
+		d := b.Next()
+		assert.GreaterOrEqual(t, d, 8*time.Second)
+		assert.LessOrEqual(t, d, 12*time.Second)
+	}
+}
+
+func TestReconnectConfig(t *testing.T) {
+	c := ReconnectConfig{}
+	assert.NoError(t, c.verifyAndFill())
+	assert.Equal(t, ReconnectConfig{
+		InitialDelay: defaultReconnectInitialDelay,
+		MaxDelay:     defaultReconnectMaxDelay,
+		Multiplier:   defaultReconnectMultiplier,
+		Jitter:       float64Ref(defaultReconnectJitter),
+	}, c)
+
+	// Jitter can be turned off
+	c = ReconnectConfig{Jitter: float64Ref(0)}
+	assert.NoError(t, c.verifyAndFill())
+	assert.Equal(t, 0.0, *c.Jitter)
+
+	c = ReconnectConfig{InitialDelay: 2 * time.Minute}
+	assert.NoError(t, c.verifyAndFill())
+	assert.Equal(t, 2*time.Minute, c.MaxDelay)
+
+	for _, c := range []ReconnectConfig{
+		{InitialDelay: -1},
+		{InitialDelay: 10 * time.Second, MaxDelay: 5 * time.Second},
+		{Multiplier: 0.5},
+		{Jitter: float64Ref(1.5)},
+		{Jitter: float64Ref(-0.1)},
+	} {
+		assert.Error(t, c.verifyAndFill())
+	}
+}
+
+func float64Ref(f float64) *float64 {
+	return &f
+}
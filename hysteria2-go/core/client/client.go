@@ -4,9 +4,11 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	coreErrs "github.com/apernet/hysteria/core/v2/errors"
@@ -26,7 +28,17 @@ const (
 type Client interface {
 	TCP(addr string) (net.Conn, error)
 	UDP() (HyUDPConn, error)
+	// TCPContext is like TCP, but gives up when ctx is done, whether the client is still
+	// connecting or waiting for the server to answer the request. With fast open, the deadline
+	// of ctx (but not its cancellation) also applies to reading the answer on the first Read.
+	TCPContext(ctx context.Context, addr string) (net.Conn, error)
+	// UDPContext is like UDP, but gives up when ctx is done.
+	UDPContext(ctx context.Context) (HyUDPConn, error)
 	Close() error
+	Stats() Stats
+	// CloseConn closes the TCP stream or UDP session with the ID listed in Stats.
+	// It returns false if no such connection is open.
+	CloseConn(id uint64) bool
 }
 
 type HyUDPConn interface {
@@ -40,14 +52,19 @@ type HandshakeInfo struct {
 	Tx          uint64 // 0 if using BBR
 	ServerAddr  net.Addr
 	ECHAccepted bool
+	Used0RTT    bool // whether the auth request was sent in 0-RTT
 }
 
 func NewClient(config *Config) (Client, *HandshakeInfo, error) {
 	if err := config.verifyAndFill(); err != nil {
 		return nil, nil, err
 	}
+	if config.PoolSize > 1 {
+		return newPoolClient(config)
+	}
 	c := &clientImpl{
 		config: config,
+		stats:  newStatsTracker(),
 	}
 	info, err := c.connect()
 	if err != nil {
@@ -56,23 +73,57 @@ func NewClient(config *Config) (Client, *HandshakeInfo, error) {
 	return c, info, nil
 }
 
+// probeServer checks that the server in config answers a QUIC handshake, and returns
+// the handshake time. Unlike NewClient, it doesn't authenticate.
+func probeServer(config *Config) (time.Duration, error) {
+	if err := config.verifyAndFill(); err != nil {
+		return 0, err
+	}
+	c := &clientImpl{config: config}
+	return c.probe()
+}
+
 type clientImpl struct {
 	config *Config
 
-	pktConn net.PacketConn
-	tr      *quic.Transport
-	conn    *quic.Conn
+	pathMutex sync.Mutex // guards pktConn, tr, oldPaths and closed, which change on migration and Close
+	pktConn   net.PacketConn
+	tr        *quic.Transport
+	oldPaths  []io.Closer // sockets and transports migrated away from
+	closed    bool
+	conn      *quic.Conn
+
+	bwMutex      sync.Mutex
+	cc           congestion.Controller
+	maxTx        uint64 // BandwidthConfig.MaxTx, or as changed by setBandwidth
+	serverRx     uint64 // as last advertised by the server
+	serverRxAuto bool   // the server asked us to use bandwidth detection
 
-	udpSM *udpSessionManager
+	control *controlStream // nil if the server doesn't support it
+	udpSM   *udpSessionManager
+	stats   *statsTracker
+	health  *healthMonitor // nil if disabled
 }
 
+// connect sends the auth request in 0-RTT when resuming a session with a server
+// that told us it accepts it. Everything else waits for the handshake.
 func (c *clientImpl) connect() (*HandshakeInfo, error) {
-	pktConn, err := c.config.ConnFactory.New(c.config.ServerAddr)
-	if err != nil {
-		return nil, err
+	serverAddr := c.config.ServerAddr.String()
+	session := c.config.session
+	early := session != nil && !c.config.QUICConfig.Disable0RTT && session.ZeroRTT(serverAddr)
+	info, err := c.connectOnce(early)
+	if early && errors.Is(err, quic.Err0RTTRejected) {
+		// e.g. the server restarted with new session ticket keys. Don't try it again
+		// until the server says otherwise, and connect again the normal way right away.
+		session.SetZeroRTT(serverAddr, false)
+		info, err = c.connectOnce(false)
 	}
-	// Convert config to TLS config & QUIC config
-	tlsConfig := &tls.Config{
+	return info, err
+}
+
+// tlsConfig converts the TLS part of the config, without the session cache.
+func (c *clientImpl) tlsConfig() *tls.Config {
+	return &tls.Config{
 		ServerName:                     c.config.TLSConfig.ServerName,
 		InsecureSkipVerify:             c.config.TLSConfig.InsecureSkipVerify,
 		VerifyPeerCertificate:          c.config.TLSConfig.VerifyPeerCertificate,
@@ -80,7 +131,10 @@ func (c *clientImpl) connect() (*HandshakeInfo, error) {
 		GetClientCertificate:           c.config.TLSConfig.GetClientCertificate,
 		EncryptedClientHelloConfigList: c.config.TLSConfig.ECHConfigList,
 	}
-	quicConfig := &quic.Config{
+}
+
+func (c *clientImpl) quicConfig() *quic.Config {
+	return &quic.Config{
 		InitialStreamReceiveWindow:     c.config.QUICConfig.InitialStreamReceiveWindow,
 		MaxStreamReceiveWindow:         c.config.QUICConfig.MaxStreamReceiveWindow,
 		InitialConnectionReceiveWindow: c.config.QUICConfig.InitialConnectionReceiveWindow,
@@ -91,16 +145,47 @@ func (c *clientImpl) connect() (*HandshakeInfo, error) {
 		EnableDatagrams:                true,
 		MaxDatagramFrameSize:           protocol.MaxDatagramFrameSize,
 		OmitMaxDatagramFrameSize:       true,
-		DisablePathManager:             true,
+		DisablePathManager:             !c.config.QUICConfig.EnableMigration,
 		ChromeParrot:                   !c.config.QUICConfig.DisableChromeParrot,
 	}
-	tr := &quic.Transport{Conn: pktConn, DisableGSO: c.config.QUICConfig.DisableGSO}
-	if !c.config.QUICConfig.DisableChromeParrot {
-		// Chrome uses a zero-length source connection ID. This has to be set on the
-		// Transport, since it fixes the length at which incoming packets' connection
-		// IDs are parsed; leaving it default yields 4-byte IDs, visible on the wire.
-		tr.ConnectionIDGenerator = quic.ZeroLengthConnectionIDGenerator{}
+}
+
+// probe completes a QUIC handshake with the server and closes the connection right away,
+// without sending the auth request, so that it doesn't count towards the connection limits
+// or failed auth attempts of the server. It returns the handshake time.
+func (c *clientImpl) probe() (time.Duration, error) {
+	pktConn, err := c.config.ConnFactory.New(c.config.ServerAddr)
+	if err != nil {
+		return 0, err
+	}
+	defer pktConn.Close()
+	tr := c.newTransport(pktConn)
+	defer tr.Close()
+	tlsConfig := c.tlsConfig()
+	tlsConfig.NextProtos = []string{http3.NextProtoH3}
+	start := time.Now()
+	conn, err := tr.Dial(context.Background(), c.config.ServerAddr, tlsConfig, c.quicConfig())
+	if err != nil {
+		return 0, coreErrs.ConnectError{Err: err}
+	}
+	rtt := time.Since(start)
+	_ = conn.CloseWithError(closeErrCodeOK, "")
+	return rtt, nil
+}
+
+// connectOnce makes one connection attempt, with the auth request in 0-RTT if early is set.
+func (c *clientImpl) connectOnce(early bool) (*HandshakeInfo, error) {
+	pktConn, err := c.config.ConnFactory.New(c.config.ServerAddr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := c.tlsConfig()
+	session := c.config.session
+	if session != nil {
+		tlsConfig.ClientSessionCache = session.TLS
 	}
+	quicConfig := c.quicConfig()
+	tr := c.newTransport(pktConn)
 	// Prepare RoundTripper
 	var conn *quic.Conn
 	rt := &http3.Transport{
@@ -129,6 +214,11 @@ func (c *clientImpl) connect() (*HandshakeInfo, error) {
 		Auth: c.config.Auth,
 		Rx:   c.config.BandwidthConfig.MaxRx,
 	})
+	if early {
+		req.Method = http3.MethodGet0RTT
+	}
+	// A rejected status is not retried with POST: the server only accepts the request
+	// in 0-RTT if it told us so, and sending the wrong credentials twice would count as two failures.
 	resp, err := rt.RoundTrip(req)
 	if err != nil {
 		if conn != nil {
@@ -146,41 +236,161 @@ func (c *clientImpl) connect() (*HandshakeInfo, error) {
 	}
 	// Auth OK
 	authResp := protocol.AuthResponseFromHeader(resp.Header)
-	var actualTx uint64
-	if authResp.RxAuto {
-		// Server asks client to use bandwidth detection,
-		// ignore local bandwidth config and use the configured congestion controller.
-		congestion.UseConfigured(conn, c.config.CongestionConfig.Type, c.config.CongestionConfig.BBRProfile)
-	} else {
-		// actualTx = min(serverRx, clientTx)
-		actualTx = authResp.Rx
-		if actualTx == 0 || actualTx > c.config.BandwidthConfig.MaxTx {
-			// Server doesn't have a limit, or our clientTx is smaller than serverRx
-			actualTx = c.config.BandwidthConfig.MaxTx
-		}
-		if actualTx > 0 {
-			congestion.UseBrutal(conn, actualTx, c.config.BandwidthConfig.DisableLossCompensation)
-		} else {
-			// We don't know our own bandwidth either, use the configured congestion controller.
-			congestion.UseConfigured(conn, c.config.CongestionConfig.Type, c.config.CongestionConfig.BBRProfile)
-		}
+	if session != nil {
+		session.SetZeroRTT(c.config.ServerAddr.String(), authResp.ZeroRTT)
 	}
+	// Make sure nothing else is sent in 0-RTT, as it could be replayed
+	select {
+	case <-conn.HandshakeComplete():
+	case <-conn.Context().Done():
+		_ = tr.Close()
+		_ = pktConn.Close()
+		return nil, coreErrs.ConnectError{Err: context.Cause(conn.Context())}
+	}
+	c.conn = conn
+	c.bwMutex.Lock()
+	c.maxTx = c.config.BandwidthConfig.MaxTx
+	c.serverRx = authResp.Rx
+	c.serverRxAuto = authResp.RxAuto
+	actualTx := c.applyBandwidthLocked()
+	c.bwMutex.Unlock()
 	_ = resp.Body.Close()
 
 	c.pktConn = pktConn
 	c.tr = tr
-	c.conn = conn
 	if authResp.UDPEnabled {
 		c.udpSM = newUDPSessionManager(&udpIOImpl{Conn: conn})
 	}
+	if c.config.HealthConfig.Enable {
+		c.health = &healthMonitor{
+			Conn:   &quicProbeConn{Conn: conn},
+			Config: c.config.HealthConfig,
+			UnhealthyFunc: func(err error) {
+				_ = conn.CloseWithError(closeErrCodeOK, err.Error())
+			},
+		}
+		go c.health.Run(conn.Context().Done())
+	}
+	if authResp.Control {
+		// Not fatal, the connection works without it
+		_ = c.openControl()
+	}
+	if c.config.QUICConfig.EnableMigration {
+		m := &migrationMonitor{
+			Interval: migrationCheckInterval,
+			LocalIPFunc: func() (net.IP, error) {
+				return routeLocalIP(c.config.ServerAddr)
+			},
+			MigrateFunc: c.migrate,
+		}
+		go m.Run(conn.Context().Done())
+	}
 	return &HandshakeInfo{
 		UDPEnabled:  authResp.UDPEnabled,
 		Tx:          actualTx,
 		ServerAddr:  c.config.ServerAddr,
 		ECHAccepted: conn.ConnectionState().TLS.ECHAccepted,
+		Used0RTT:    conn.ConnectionState().Used0RTT,
 	}, nil
 }
 
+func (c *clientImpl) newTransport(pktConn net.PacketConn) *quic.Transport {
+	tr := &quic.Transport{Conn: pktConn, DisableGSO: c.config.QUICConfig.DisableGSO}
+	if !c.config.QUICConfig.DisableChromeParrot {
+		// Chrome uses a zero-length source connection ID. This has to be set on the
+		// Transport, since it fixes the length at which incoming packets' connection
+		// IDs are parsed; leaving it default yields 4-byte IDs, visible on the wire.
+		tr.ConnectionIDGenerator = quic.ZeroLengthConnectionIDGenerator{}
+	}
+	return tr
+}
+
+// migrate moves the connection to a new socket, bound to whatever network is in use now.
+// The new path is validated before switching to it, so the connection (with all its
+// streams and UDP sessions) stays on the old one if the server can't be reached that way.
+func (c *clientImpl) migrate() error {
+	c.pathMutex.Lock()
+	closed := c.closed
+	c.pathMutex.Unlock()
+	if closed {
+		return coreErrs.ClosedError{}
+	}
+	pktConn, err := c.config.ConnFactory.New(c.config.ServerAddr)
+	if err != nil {
+		return err
+	}
+	tr := c.newTransport(pktConn)
+	path, err := c.conn.AddPath(tr)
+	if err != nil {
+		_ = tr.Close()
+		_ = pktConn.Close()
+		return err
+	}
+	ctx, cancel := context.WithTimeout(c.conn.Context(), migrationProbeTimeout)
+	defer cancel()
+	err = path.Probe(ctx)
+	if err == nil {
+		err = path.Switch()
+	}
+	if err != nil {
+		_ = path.Close()
+		_ = tr.Close()
+		_ = pktConn.Close()
+		return err
+	}
+	c.pathMutex.Lock()
+	defer c.pathMutex.Unlock()
+	if c.closed {
+		// Closed while migrating, Close has already closed everything else
+		_ = tr.Close()
+		_ = pktConn.Close()
+		return coreErrs.ClosedError{}
+	}
+	// The old transport is kept open until the connection is closed,
+	// as closing a transport closes the connections it was created for
+	c.oldPaths = append(c.oldPaths, c.tr, c.pktConn)
+	c.tr, c.pktConn = tr, pktConn
+	return nil
+}
+
+// applyBandwidthLocked picks the congestion controller from the rate the server can receive
+// and our own, and returns the resulting send rate (0 if not Brutal).
+// A Brutal controller is retargeted rather than replaced. Must be called with bwMutex held.
+func (c *clientImpl) applyBandwidthLocked() uint64 {
+	var tx uint64
+	if !c.serverRxAuto {
+		// tx = min(serverRx, clientTx)
+		tx = c.serverRx
+		if tx == 0 || tx > c.maxTx {
+			// Server doesn't have a limit, or our clientTx is smaller than serverRx
+			tx = c.maxTx
+		}
+	}
+	if tx > 0 {
+		if !c.cc.SetBrutalBandwidth(tx) {
+			c.cc = congestion.UseBrutal(c.conn, tx, c.config.BandwidthConfig.DisableLossCompensation)
+		}
+	} else if c.cc.Type == "" || c.cc.Type == congestion.TypeBrutal {
+		// Server asks us to use bandwidth detection, or we don't know our own bandwidth either,
+		// use the configured congestion controller.
+		c.cc = congestion.UseConfigured(c.conn, c.config.CongestionConfig.Type, c.config.CongestionConfig.BBRProfile)
+	}
+	return tx
+}
+
+// setBandwidth changes our bandwidth on the connection, in bytes per second:
+// tx is applied right away, and rx is sent to the server for it to adjust.
+func (c *clientImpl) setBandwidth(tx, rx uint64) error {
+	c.bwMutex.Lock()
+	c.maxTx = tx
+	c.applyBandwidthLocked()
+	c.bwMutex.Unlock()
+	if c.control == nil {
+		return errControlUnsupported
+	}
+	return c.control.Send(protocol.ControlMessage{Type: protocol.ControlTypeBandwidth, Value: rx})
+}
+
 // openStream wraps the stream with QStream, which handles Close() properly
 func (c *clientImpl) openStream() (*utils.QStream, error) {
 	stream, err := c.conn.OpenStream()
@@ -191,56 +401,135 @@ func (c *clientImpl) openStream() (*utils.QStream, error) {
 }
 
 func (c *clientImpl) TCP(addr string) (net.Conn, error) {
+	return c.TCPContext(context.Background(), addr)
+}
+
+func (c *clientImpl) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	stream, err := c.openStream()
 	if err != nil {
 		return nil, wrapIfConnectionClosed(err)
 	}
+	// Unblock the request write and response read below when ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		_ = stream.SetDeadline(time.Now())
+	})
+	// ctxErr returns the error to report for a failed stream operation,
+	// and closes the stream
+	ctxErr := func(err error) error {
+		_ = stream.Close()
+		if !stop() {
+			return ctx.Err()
+		}
+		return wrapIfConnectionClosed(err)
+	}
 	// Send request
 	err = protocol.WriteTCPRequest(stream, addr)
 	if err != nil {
-		_ = stream.Close()
-		return nil, wrapIfConnectionClosed(err)
+		return nil, ctxErr(err)
 	}
 	if c.config.FastOpen {
 		// Don't wait for the response when fast open is enabled.
 		// Return the connection immediately, defer the response handling
 		// to the first Read() call.
-		return &tcpConn{
+		if !stop() {
+			_ = stream.Close()
+			return nil, ctx.Err()
+		}
+		conn := &tcpConn{
 			Orig:             stream,
 			PseudoLocalAddr:  c.conn.LocalAddr(),
 			PseudoRemoteAddr: c.conn.RemoteAddr(),
 			Established:      false,
-		}, nil
+		}
+		conn.DialDeadline, _ = ctx.Deadline()
+		conn.Counter = c.stats.Open(ConnTypeTCP, addr, conn.Close)
+		return conn, nil
 	}
 	// Read response
 	ok, msg, err := protocol.ReadTCPResponse(stream)
 	if err != nil {
+		return nil, ctxErr(err)
+	}
+	if !stop() {
 		_ = stream.Close()
-		return nil, wrapIfConnectionClosed(err)
+		return nil, ctx.Err()
 	}
+	_ = stream.SetDeadline(time.Time{})
 	if !ok {
 		_ = stream.Close()
 		return nil, coreErrs.DialError{Message: msg}
 	}
-	return &tcpConn{
+	conn := &tcpConn{
 		Orig:             stream,
 		PseudoLocalAddr:  c.conn.LocalAddr(),
 		PseudoRemoteAddr: c.conn.RemoteAddr(),
 		Established:      true,
-	}, nil
+	}
+	conn.Counter = c.stats.Open(ConnTypeTCP, addr, conn.Close)
+	return conn, nil
 }
 
 func (c *clientImpl) UDP() (HyUDPConn, error) {
+	return c.UDPContext(context.Background())
+}
+
+// UDPContext only checks ctx before starting, as opening a session
+// doesn't wait for the server.
+func (c *clientImpl) UDPContext(ctx context.Context) (HyUDPConn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if c.udpSM == nil {
 		return nil, coreErrs.DialError{Message: "UDP not enabled"}
 	}
-	return c.udpSM.NewUDP()
+	conn, err := c.udpSM.NewUDP()
+	if err != nil {
+		return nil, err
+	}
+	sc := &statsUDPConn{HyUDPConn: conn}
+	sc.Counter = c.stats.Open(ConnTypeUDP, "", sc.Close)
+	return sc, nil
+}
+
+func (c *clientImpl) Stats() Stats {
+	s := c.stats.Stats()
+	c.bwMutex.Lock()
+	cc := c.cc
+	c.bwMutex.Unlock()
+	path := PathStats(congestion.GetPathStats(c.conn, cc))
+	s.RTT = path.SmoothedRTT
+	s.Paths = []PathStats{path}
+	if c.health != nil {
+		s.Loss = c.health.Loss()
+	}
+	return s
+}
+
+func (c *clientImpl) CloseConn(id uint64) bool {
+	return c.stats.CloseConn(id)
+}
+
+func (c *clientImpl) done() <-chan struct{} {
+	return c.conn.Context().Done()
+}
+
+func (c *clientImpl) closeErr() error {
+	return coreErrs.ClosedError{Err: context.Cause(c.conn.Context())}
 }
 
 func (c *clientImpl) Close() error {
 	_ = c.conn.CloseWithError(closeErrCodeOK, "")
+	c.pathMutex.Lock()
+	defer c.pathMutex.Unlock()
+	c.closed = true
 	_ = c.tr.Close()
 	_ = c.pktConn.Close()
+	for _, closer := range c.oldPaths {
+		_ = closer.Close()
+	}
 	return nil
 }
 
@@ -266,12 +555,22 @@ type tcpConn struct {
 	PseudoLocalAddr  net.Addr
 	PseudoRemoteAddr net.Addr
 	Established      bool
+	DialDeadline     time.Time // for reading the response when not Established, zero if none
+	Counter          *connCounter
+
+	readDeadline utils.Atomic[time.Time] // set by the user
 }
 
 func (c *tcpConn) Read(b []byte) (n int, err error) {
 	if !c.Established {
-		// Read response
+		// Read response, within the deadline of the dial if it is earlier than the user's
+		if d := c.readDeadline.Load(); !c.DialDeadline.IsZero() && (d.IsZero() || c.DialDeadline.Before(d)) {
+			_ = c.Orig.SetReadDeadline(c.DialDeadline)
+		}
 		ok, msg, err := protocol.ReadTCPResponse(c.Orig)
+		if !c.DialDeadline.IsZero() {
+			_ = c.Orig.SetReadDeadline(c.readDeadline.Load())
+		}
 		if err != nil {
 			return 0, err
 		}
@@ -280,14 +579,19 @@ func (c *tcpConn) Read(b []byte) (n int, err error) {
 		}
 		c.Established = true
 	}
-	return c.Orig.Read(b)
+	n, err = c.Orig.Read(b)
+	c.Counter.AddRx(n)
+	return n, err
 }
 
 func (c *tcpConn) Write(b []byte) (n int, err error) {
-	return c.Orig.Write(b)
+	n, err = c.Orig.Write(b)
+	c.Counter.AddTx(n)
+	return n, err
 }
 
 func (c *tcpConn) Close() error {
+	c.Counter.Close()
 	return c.Orig.Close()
 }
 
@@ -300,10 +604,12 @@ func (c *tcpConn) RemoteAddr() net.Addr {
 }
 
 func (c *tcpConn) SetDeadline(t time.Time) error {
+	c.readDeadline.Store(t)
 	return c.Orig.SetDeadline(t)
 }
 
 func (c *tcpConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(t)
 	return c.Orig.SetReadDeadline(t)
 }
 
@@ -16,6 +16,14 @@ const (
 	defaultConnReceiveWindow   = defaultStreamReceiveWindow * 5 / 2 // 20MB
 	defaultMaxIdleTimeout      = 30 * time.Second
 	defaultKeepAlivePeriod     = 10 * time.Second
+
+	defaultHealthInterval = 5 * time.Second
+	defaultHealthTimeout  = 15 * time.Second
+
+	defaultReconnectInitialDelay = 1 * time.Second
+	defaultReconnectMaxDelay     = 1 * time.Minute
+	defaultReconnectMultiplier   = 2
+	defaultReconnectJitter       = 0.2
 )
 
 type Config struct {
@@ -26,9 +34,18 @@ type Config struct {
 	QUICConfig       QUICConfig
 	CongestionConfig CongestionConfig
 	BandwidthConfig  BandwidthConfig
+	HealthConfig     HealthConfig
 	FastOpen         bool
+	// PoolSize is the number of parallel QUIC connections to keep to the server.
+	// New TCP streams and UDP sessions go to the least loaded one. 0 or 1 for a single connection.
+	PoolSize int
+	// MessageFunc, if not nil, is called with the messages the server pushes to the client,
+	// one at a time. Servers that don't support it never send any.
+	MessageFunc func(msg ControlMessage)
 
-	filled bool // whether the fields have been verified and filled
+	session   *sessionCache   // set by ReconnectableClient to resume sessions across reconnects
+	reconnect ReconnectConfig // set by ReconnectableClient, for a pool to replace its connections the same way
+	filled    bool            // whether the fields have been verified and filled
 }
 
 // verifyAndFill fills the fields that are not set by the user with default values when possible,
@@ -74,6 +91,21 @@ func (c *Config) verifyAndFill() error {
 		return errors.ConfigError{Field: "QUICConfig.KeepAlivePeriod", Reason: "must be between 2s and 60s"}
 	}
 	c.QUICConfig.DisablePathMTUDiscovery = c.QUICConfig.DisablePathMTUDiscovery || pmtud.DisablePathMTUDiscovery
+	if c.HealthConfig.Enable {
+		if c.HealthConfig.Interval == 0 {
+			c.HealthConfig.Interval = defaultHealthInterval
+		} else if c.HealthConfig.Interval < time.Second {
+			return errors.ConfigError{Field: "HealthConfig.Interval", Reason: "must be at least 1s"}
+		}
+		if c.HealthConfig.Timeout == 0 {
+			c.HealthConfig.Timeout = max(defaultHealthTimeout, 2*c.HealthConfig.Interval)
+		} else if c.HealthConfig.Timeout <= c.HealthConfig.Interval {
+			return errors.ConfigError{Field: "HealthConfig.Timeout", Reason: "must be greater than Interval"}
+		}
+	}
+	if c.PoolSize < 0 {
+		return errors.ConfigError{Field: "PoolSize", Reason: "must not be negative"}
+	}
 	var err error
 	c.CongestionConfig.Type, err = congestion.NormalizeType(c.CongestionConfig.Type)
 	if err != nil {
@@ -90,6 +122,44 @@ func (c *Config) verifyAndFill() error {
 	return nil
 }
 
+// ReconnectConfig controls how often a ReconnectableClient tries to connect again
+// after losing its connection. The first attempt is made right away, the following ones
+// after InitialDelay, multiplied by Multiplier after every failure up to MaxDelay,
+// and randomly varied by up to Jitter (a fraction of the delay) in either direction.
+type ReconnectConfig struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       *float64 // nil for the default, 0 to disable
+}
+
+// verifyAndFill fills the fields that are not set by the user with default values,
+// and returns an error if any of them is invalid.
+func (c *ReconnectConfig) verifyAndFill() error {
+	if c.InitialDelay == 0 {
+		c.InitialDelay = defaultReconnectInitialDelay
+	} else if c.InitialDelay < 0 {
+		return errors.ConfigError{Field: "ReconnectConfig.InitialDelay", Reason: "must not be negative"}
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = max(defaultReconnectMaxDelay, c.InitialDelay)
+	} else if c.MaxDelay < c.InitialDelay {
+		return errors.ConfigError{Field: "ReconnectConfig.MaxDelay", Reason: "must not be less than InitialDelay"}
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = defaultReconnectMultiplier
+	} else if c.Multiplier < 1 {
+		return errors.ConfigError{Field: "ReconnectConfig.Multiplier", Reason: "must be at least 1"}
+	}
+	if c.Jitter == nil {
+		jitter := defaultReconnectJitter
+		c.Jitter = &jitter
+	} else if *c.Jitter < 0 || *c.Jitter > 1 {
+		return errors.ConfigError{Field: "ReconnectConfig.Jitter", Reason: "must be between 0 and 1"}
+	}
+	return nil
+}
+
 type ConnFactory interface {
 	New(net.Addr) (net.PacketConn, error)
 }
@@ -121,6 +191,23 @@ type QUICConfig struct {
 	DisablePathMTUDiscovery        bool // The server may still override this to true on unsupported platforms.
 	DisableGSO                     bool
 	DisableChromeParrot            bool // Chrome QUIC fingerprint parroting is on by default.
+	// Disable0RTT stops the client from sending the auth request in 0-RTT
+	// when resuming a session with a server that accepts it.
+	Disable0RTT bool
+	// EnableMigration makes the client move the connection to a new socket when the local
+	// address it would use to reach the server changes, instead of losing the connection.
+	// The server has to enable it too.
+	EnableMigration bool
+}
+
+// HealthConfig controls the health monitor of a connection, which is off unless Enable is set.
+// It sends a probe to the server every Interval, and closes the connection when nothing
+// has been received for Timeout, so that a ReconnectableClient or pool replaces it right away.
+// Stats.Loss is only measured while it is on.
+type HealthConfig struct {
+	Enable   bool
+	Interval time.Duration
+	Timeout  time.Duration
 }
 
 type CongestionConfig struct {
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/apernet/hysteria/core/v2/internal/protocol"
+	"github.com/apernet/hysteria/core/v2/internal/utils"
+)
+
+var errControlUnsupported = errors.New("server does not support control messages")
+
+// ControlType is the kind of a ControlMessage.
+type ControlType uint64
+
+const (
+	ControlNotice       ControlType = protocol.ControlTypeNotice       // Text only
+	ControlQuotaWarning ControlType = protocol.ControlTypeQuotaWarning // Value is the number of bytes left
+	ControlBandwidth    ControlType = protocol.ControlTypeBandwidth    // Value is the server's new max receive rate in bytes per second
+	ControlMaintenance  ControlType = protocol.ControlTypeMaintenance  // Value is the number of seconds until the server goes down
+	ControlKick         ControlType = protocol.ControlTypeKick         // The server is about to close the connection, Text is the reason
+)
+
+func (t ControlType) String() string {
+	switch t {
+	case ControlNotice:
+		return "notice"
+	case ControlQuotaWarning:
+		return "quotaWarning"
+	case ControlBandwidth:
+		return "bandwidth"
+	case ControlMaintenance:
+		return "maintenance"
+	case ControlKick:
+		return "kick"
+	default:
+		return "unknown"
+	}
+}
+
+// ControlMessage is a message the server pushed to the client.
+type ControlMessage struct {
+	Type  ControlType
+	Value uint64
+	Text  string
+}
+
+// controlStream is the stream the client opens after authentication,
+// on which the server pushes messages, and the client sends its own.
+type controlStream struct {
+	mutex  sync.Mutex // serializes the messages
+	stream *utils.QStream
+}
+
+func (c *controlStream) Send(msg protocol.ControlMessage) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return protocol.WriteControlMessage(c.stream, msg)
+}
+
+// openControl opens the control stream, and handles the messages from the server
+// until it is closed. They are also passed to Config.MessageFunc.
+func (c *clientImpl) openControl() error {
+	stream, err := c.openStream()
+	if err != nil {
+		return err
+	}
+	if err := protocol.WriteControlOpen(stream); err != nil {
+		_ = stream.Close()
+		return err
+	}
+	c.control = &controlStream{stream: stream}
+	go c.readControl(stream)
+	return nil
+}
+
+func (c *clientImpl) readControl(stream *utils.QStream) {
+	defer stream.Close()
+	for {
+		msg, err := protocol.ReadControlMessage(stream)
+		if err != nil {
+			return
+		}
+		if msg.Type == protocol.ControlTypeBandwidth {
+			// The server changed its max receive rate
+			c.bwMutex.Lock()
+			c.serverRx = msg.Value
+			c.serverRxAuto = false
+			c.applyBandwidthLocked()
+			c.bwMutex.Unlock()
+		}
+		if c.config.MessageFunc != nil {
+			c.config.MessageFunc(ControlMessage{
+				Type:  ControlType(msg.Type),
+				Value: msg.Value,
+				Text:  msg.Text,
+			})
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/apernet/quic-go"
+)
+
+// healthProbe is the datagram sent as a probe. It is too short to be a valid UDP message,
+// so the server drops it, but its QUIC stack still has to acknowledge the packet.
+var healthProbe = []byte{0}
+
+// healthLossWeight is the weight of the latest interval in the smoothed loss rate.
+const healthLossWeight = 0.25
+
+// pathStats are the counters of a connection that the health monitor works with.
+type pathStats struct {
+	RTT             time.Duration // smoothed
+	PacketsSent     uint64
+	PacketsReceived uint64
+	PacketsLost     uint64
+}
+
+// probeConn is the connection watched by a healthMonitor.
+type probeConn interface {
+	SendProbe() error
+	PathStats() pathStats
+}
+
+type quicProbeConn struct {
+	Conn *quic.Conn
+}
+
+func (c *quicProbeConn) SendProbe() error {
+	return c.Conn.SendDatagram(healthProbe)
+}
+
+func (c *quicProbeConn) PathStats() pathStats {
+	s := c.Conn.ConnectionStats()
+	return pathStats{
+		RTT:             s.SmoothedRTT,
+		PacketsSent:     s.PacketsSent,
+		PacketsReceived: s.PacketsReceived,
+		PacketsLost:     s.PacketsLost,
+	}
+}
+
+// healthMonitor sends a probe to the server every Config.Interval, and reports the
+// connection as dead when nothing at all has been received from the server for Config.Timeout,
+// which is much sooner than the QUIC idle timeout would. It also keeps a smoothed loss rate
+// of the recent intervals.
+type healthMonitor struct {
+	Conn          probeConn
+	Config        HealthConfig
+	UnhealthyFunc func(err error) // called once when the connection is found dead
+
+	loss atomic.Uint64 // float64 bits
+}
+
+// Run monitors the connection until done is closed or it is found dead.
+func (m *healthMonitor) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(m.Config.Interval)
+	defer ticker.Stop()
+	last := m.Conn.PathStats()
+	lastReceived := time.Now()
+	for {
+		// Every tick checks the answer to the probe sent on the previous one
+		_ = m.Conn.SendProbe()
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		s := m.Conn.PathStats()
+		now := time.Now()
+		if s.PacketsReceived != last.PacketsReceived {
+			lastReceived = now
+		}
+		if sent := s.PacketsSent - last.PacketsSent; sent > 0 {
+			sample := math.Min(float64(s.PacketsLost-last.PacketsLost)/float64(sent), 1)
+			loss := m.Loss()
+			m.loss.Store(math.Float64bits(loss + healthLossWeight*(sample-loss)))
+		}
+		last = s
+		if now.Sub(lastReceived) >= m.Config.Timeout {
+			m.UnhealthyFunc(fmt.Errorf("no response from server for %s", m.Config.Timeout))
+			return
+		}
+	}
+}
+
+// Loss returns the smoothed fraction of packets lost, between 0 and 1.
+func (m *healthMonitor) Loss() float64 {
+	return math.Float64frombits(m.loss.Load())
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProbeConn answers probes until it is cut, losing one packet out of every lossEvery sent.
+type fakeProbeConn struct {
+	mutex     sync.Mutex
+	stats     pathStats
+	lossEvery uint64
+	cut       bool
+}
+
+func (c *fakeProbeConn) SendProbe() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.PacketsSent++
+	if c.lossEvery > 0 && c.stats.PacketsSent%c.lossEvery == 0 {
+		c.stats.PacketsLost++
+	} else if !c.cut {
+		c.stats.PacketsReceived++
+	}
+	return nil
+}
+
+func (c *fakeProbeConn) PathStats() pathStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+func (c *fakeProbeConn) Cut() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cut = true
+}
+
+func TestHealthMonitorDead(t *testing.T) {
+	conn := &fakeProbeConn{}
+	unhealthyCh := make(chan error, 1)
+	m := &healthMonitor{
+		Conn:   conn,
+		Config: HealthConfig{Interval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond},
+		UnhealthyFunc: func(err error) {
+			unhealthyCh <- err
+		},
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go m.Run(done)
+
+	select {
+	case <-unhealthyCh:
+		t.Fatal("healthy connection reported dead")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	conn.Cut()
+	select {
+	case err := <-unhealthyCh:
+		assert.Error(t, err)
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("dead connection not detected")
+	}
+}
+
+func TestHealthMonitorLoss(t *testing.T) {
+	conn := &fakeProbeConn{lossEvery: 2}
+	m := &healthMonitor{
+		Conn:          conn,
+		Config:        HealthConfig{Interval: 5 * time.Millisecond, Timeout: time.Minute},
+		UnhealthyFunc: func(err error) {},
+	}
+	done := make(chan struct{})
+	go m.Run(done)
+	time.Sleep(300 * time.Millisecond)
+	close(done)
+	assert.InDelta(t, 0.5, m.Loss(), 0.1)
+}
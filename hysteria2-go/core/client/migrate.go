@@ -0,0 +1,65 @@
+package client
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	migrationCheckInterval = 2 * time.Second
+	migrationProbeTimeout  = 5 * time.Second
+)
+
+// routeLocalIP returns the local IP the system would send packets to addr from.
+// It works with any net.Addr whose String() is host:port with an IP host,
+// which includes the port hopping address. No packet is sent.
+func routeLocalIP(addr net.Addr) (net.IP, error) {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, &net.AddrError{Err: "not an IP address", Addr: host}
+	}
+	// The port doesn't matter, connecting a UDP socket only looks up the route
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: 443})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// migrationMonitor checks every Interval which local IP the system would use to reach
+// the server, and calls MigrateFunc when it changes, e.g. when a laptop moves from Wi-Fi
+// to Ethernet. A failed migration is not retried until the local IP changes again,
+// the health monitor takes care of a connection left on a dead path.
+type migrationMonitor struct {
+	Interval    time.Duration
+	LocalIPFunc func() (net.IP, error)
+	MigrateFunc func() error
+}
+
+// Run monitors the local IP until done is closed.
+func (m *migrationMonitor) Run(done <-chan struct{}) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	last, _ := m.LocalIPFunc()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		ip, err := m.LocalIPFunc()
+		if err != nil {
+			// No route at the moment, e.g. between networks
+			continue
+		}
+		if last != nil && !ip.Equal(last) {
+			_ = m.MigrateFunc()
+		}
+		last = ip
+	}
+}
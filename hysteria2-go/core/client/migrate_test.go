@@ -0,0 +1,53 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationMonitor(t *testing.T) {
+	var mutex sync.Mutex
+	ip := net.ParseIP("192.168.1.2")
+	var routeErr error
+	setRoute := func(newIP net.IP, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ip, routeErr = newIP, err
+	}
+	migrateCh := make(chan struct{}, 10)
+	m := &migrationMonitor{
+		Interval: 10 * time.Millisecond,
+		LocalIPFunc: func() (net.IP, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return ip, routeErr
+		},
+		MigrateFunc: func() error {
+			migrateCh <- struct{}{}
+			return nil
+		},
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go m.Run(done)
+
+	expectMigrations := func(n int) {
+		time.Sleep(100 * time.Millisecond)
+		assert.Len(t, migrateCh, n)
+		for len(migrateCh) > 0 {
+			<-migrateCh
+		}
+	}
+
+	// Same network
+	expectMigrations(0)
+	// Between networks, then on a new one
+	setRoute(nil, &net.AddrError{Err: "no route"})
+	expectMigrations(0)
+	setRoute(net.ParseIP("10.0.0.2"), nil)
+	expectMigrations(1)
+}
@@ -0,0 +1,336 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coreErrs "github.com/apernet/hysteria/core/v2/errors"
+
+	"github.com/apernet/quic-go"
+)
+
+// poolClient keeps config.PoolSize authenticated connections to the server,
+// each one a clientImpl with its own QUIC connection. New TCP streams and UDP sessions
+// go to the member with the fewest open ones. A member whose connection fails is
+// replaced in the background, without interrupting the streams on the others,
+// after a backoff as set by config.reconnect. The pool is only closed
+// when all of its members are gone at once.
+type poolClient struct {
+	config  *Config
+	backoff ReconnectConfig // for replacing members
+	ids     atomic.Uint64   // shared by the stats trackers of the members
+
+	mutex     sync.Mutex
+	members   []*clientImpl // nil while being (re)connected
+	retiredTx uint64        // traffic of replaced members
+	retiredRx uint64
+	closed    bool
+	closeCh   chan struct{}
+	lostErr   error            // why the last member was lost, if that closed the pool
+	bandwidth *BandwidthConfig // set by setBandwidth for new members, instead of the config's
+}
+
+func newPoolClient(config *Config) (Client, *HandshakeInfo, error) {
+	reconnectConfig := config.reconnect
+	if err := reconnectConfig.verifyAndFill(); err != nil {
+		return nil, nil, err
+	}
+	p := &poolClient{
+		config:  config,
+		backoff: reconnectConfig,
+		members: make([]*clientImpl, config.PoolSize),
+		closeCh: make(chan struct{}),
+	}
+	// Only the first connection is made right away, so that handshake
+	// and auth errors are reported. The rest are connected in the background.
+	c, info, err := p.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.members[0] = c
+	go p.maintain(0, c)
+	for i := 1; i < len(p.members); i++ {
+		go p.maintain(i, nil)
+	}
+	return p, info, nil
+}
+
+func (p *poolClient) dial() (*clientImpl, *HandshakeInfo, error) {
+	config := p.config
+	p.mutex.Lock()
+	if p.bandwidth != nil {
+		cc := *config
+		cc.BandwidthConfig = *p.bandwidth
+		config = &cc
+	}
+	p.mutex.Unlock()
+	c := &clientImpl{
+		config: config,
+		stats:  newSharedStatsTracker(&p.ids),
+	}
+	info, err := c.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, info, nil
+}
+
+// maintain keeps slot i filled until the pool is closed.
+// c is the current member of the slot, or nil if it has yet to be connected.
+func (p *poolClient) maintain(i int, c *clientImpl) {
+	b := backoff{Config: p.backoff}
+	for {
+		if c == nil {
+			var err error
+			c, _, err = p.dial()
+			if err != nil {
+				select {
+				case <-time.After(b.Next()):
+					continue
+				case <-p.closeCh:
+					return
+				}
+			}
+			b.Reset()
+			if !p.set(i, c) {
+				_ = c.Close()
+				return
+			}
+		}
+		select {
+		case <-c.done():
+		case <-p.closeCh:
+			return
+		}
+		p.remove(i, c)
+		_ = c.Close()
+		c = nil
+	}
+}
+
+// set returns false if the pool has been closed.
+func (p *poolClient) set(i int, c *clientImpl) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return false
+	}
+	p.members[i] = c
+	return true
+}
+
+// remove drops c from slot i, and closes the pool if it was the last member left.
+func (p *poolClient) remove(i int, c *clientImpl) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.members[i] != c {
+		return
+	}
+	s := c.Stats()
+	p.retiredTx += s.Tx
+	p.retiredRx += s.Rx
+	p.members[i] = nil
+	if !p.closed && !slices.ContainsFunc(p.members, func(m *clientImpl) bool { return m != nil }) {
+		p.lostErr = c.closeErr()
+		p.closeLocked()
+	}
+}
+
+// pick returns the connected members, least loaded first.
+func (p *poolClient) pick() ([]*clientImpl, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil, coreErrs.ClosedError{}
+	}
+	var cs []*clientImpl
+	var loads []int
+	for _, c := range p.members {
+		if c != nil {
+			cs = append(cs, c)
+			loads = append(loads, c.stats.Active())
+		}
+	}
+	if len(cs) == 0 {
+		// The pool is only closed by remove, the slots are still being connected
+		return nil, coreErrs.ReconnectingError{Err: errors.New("no pooled connection is ready")}
+	}
+	sort.Stable(byLoad{cs, loads})
+	return cs, nil
+}
+
+type byLoad struct {
+	cs    []*clientImpl
+	loads []int
+}
+
+func (b byLoad) Len() int           { return len(b.cs) }
+func (b byLoad) Less(i, j int) bool { return b.loads[i] < b.loads[j] }
+func (b byLoad) Swap(i, j int) {
+	b.cs[i], b.cs[j] = b.cs[j], b.cs[i]
+	b.loads[i], b.loads[j] = b.loads[j], b.loads[i]
+}
+
+// do calls f with each member in order of load, until it succeeds.
+// A member that is closed or out of streams is skipped for the next one.
+// A ClosedError is only returned once the pool itself is closed, as a caller
+// such as reconnectableClientImpl takes it to mean the whole client is lost,
+// while closed members are replaced in the background.
+func (p *poolClient) do(f func(c *clientImpl) (interface{}, error)) (interface{}, error) {
+	cs, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cs {
+		var ret interface{}
+		ret, err = f(c)
+		if err == nil {
+			return ret, nil
+		}
+		if _, ok := err.(coreErrs.ClosedError); !ok && !errors.Is(err, quic.StreamLimitReachedError{}) {
+			return nil, err
+		}
+	}
+	if _, ok := err.(coreErrs.ClosedError); ok && !p.isClosed() {
+		return nil, coreErrs.ReconnectingError{Err: err}
+	}
+	return nil, err
+}
+
+func (p *poolClient) isClosed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.closed
+}
+
+func (p *poolClient) TCP(addr string) (net.Conn, error) {
+	return p.TCPContext(context.Background(), addr)
+}
+
+func (p *poolClient) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	if c, err := p.do(func(c *clientImpl) (interface{}, error) {
+		return c.TCPContext(ctx, addr)
+	}); err != nil {
+		return nil, err
+	} else {
+		return c.(net.Conn), nil
+	}
+}
+
+func (p *poolClient) UDP() (HyUDPConn, error) {
+	return p.UDPContext(context.Background())
+}
+
+func (p *poolClient) UDPContext(ctx context.Context) (HyUDPConn, error) {
+	if c, err := p.do(func(c *clientImpl) (interface{}, error) {
+		return c.UDPContext(ctx)
+	}); err != nil {
+		return nil, err
+	} else {
+		return c.(HyUDPConn), nil
+	}
+}
+
+func (p *poolClient) Stats() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	s := Stats{
+		Tx: p.retiredTx,
+		Rx: p.retiredRx,
+	}
+	var n int
+	for _, c := range p.members {
+		if c == nil {
+			continue
+		}
+		cs := c.Stats()
+		s.Tx += cs.Tx
+		s.Rx += cs.Rx
+		s.ActiveStreams += cs.ActiveStreams
+		s.ActiveUDPSessions += cs.ActiveUDPSessions
+		s.RTT += cs.RTT
+		s.Loss += cs.Loss
+		s.Paths = append(s.Paths, cs.Paths...)
+		s.Conns = append(s.Conns, cs.Conns...)
+		n++
+	}
+	if n > 0 {
+		s.RTT /= time.Duration(n)
+		s.Loss /= float64(n)
+	}
+	sort.Slice(s.Conns, func(i, j int) bool {
+		return s.Conns[i].ID < s.Conns[j].ID
+	})
+	return s
+}
+
+// setBandwidth changes the bandwidth of all members, and of those connected later.
+func (p *poolClient) setBandwidth(tx, rx uint64) error {
+	p.mutex.Lock()
+	bw := p.config.BandwidthConfig
+	bw.MaxTx, bw.MaxRx = tx, rx
+	p.bandwidth = &bw
+	cs := append([]*clientImpl(nil), p.members...)
+	p.mutex.Unlock()
+	var errs []error
+	for _, c := range cs {
+		if c != nil {
+			errs = append(errs, c.setBandwidth(tx, rx))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *poolClient) CloseConn(id uint64) bool {
+	p.mutex.Lock()
+	cs := append([]*clientImpl(nil), p.members...)
+	p.mutex.Unlock()
+	for _, c := range cs {
+		if c != nil && c.CloseConn(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// done is closed when the pool is closed, by Close or because all members are gone.
+func (p *poolClient) done() <-chan struct{} {
+	return p.closeCh
+}
+
+func (p *poolClient) closeErr() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.lostErr != nil {
+		return p.lostErr
+	}
+	return coreErrs.ClosedError{}
+}
+
+func (p *poolClient) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closeLocked()
+	return nil
+}
+
+// closeLocked must be called with p.mutex held.
+func (p *poolClient) closeLocked() {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.closeCh)
+	for i, c := range p.members {
+		if c != nil {
+			_ = c.Close()
+			p.members[i] = nil
+		}
+	}
+}
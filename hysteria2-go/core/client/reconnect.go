@@ -1,97 +1,323 @@
 package client
 
 import (
+	"context"
 	"net"
 	"sync"
+	"time"
 
 	coreErrs "github.com/apernet/hysteria/core/v2/errors"
+	"github.com/apernet/hysteria/core/v2/internal/utils"
 )
 
+// ReconnectableClient is a Client that transparently reconnects
+// when the connection is closed.
+type ReconnectableClient interface {
+	Client
+	// Reconnect closes the current connection (if any) and connects again.
+	Reconnect() error
+	// HandshakeInfo returns the info of the current connection,
+	// or nil if the client is not connected.
+	HandshakeInfo() *HandshakeInfo
+	// SetConfigFunc replaces the function used to get the config for new connections.
+	// If connected, the client connects with the new config in the background,
+	// and closes the current connection once the new one is established.
+	SetConfigFunc(configFunc func() (*Config, error))
+	// SetServerGroup is like SetConfigFunc, but with a group of servers.
+	// The previous group is closed.
+	SetServerGroup(group *ServerGroup)
+	// SetMessageFunc sets the function called with the messages the server pushes
+	// on any connection, unless the config sets its own MessageFunc.
+	SetMessageFunc(f func(msg ControlMessage))
+	// SetBandwidth changes MaxTx and MaxRx of the bandwidth config, in bytes per second,
+	// on the current connection without reconnecting, and on the following ones until
+	// the config function or server group is replaced. The server is told about rx,
+	// which fails if it doesn't support control messages.
+	SetBandwidth(tx, rx uint64) error
+}
+
+// bandwidthSetter is implemented by clients whose bandwidth can be changed while connected.
+type bandwidthSetter interface {
+	setBandwidth(tx, rx uint64) error
+}
+
 // reconnectableClientImpl is a wrapper of Client, which can reconnect when the connection is closed,
 // except when the caller explicitly calls Close() to permanently close this client.
+// Reconnection happens in a background loop with a backoff between failed attempts,
+// during which dials fail right away instead of each waiting for a handshake.
 type reconnectableClientImpl struct {
-	configFunc    func() (*Config, error)           // called before connecting
-	connectedFunc func(Client, *HandshakeInfo, int) // called when successfully connected
-	client        Client
-	count         int
-	m             sync.Mutex
-	closed        bool // permanent close
+	connectedFunc    func(Client, *HandshakeInfo, int) // called when successfully connected
+	disconnectedFunc func(Client, error)               // called when the connection is lost or fails to establish
+	closeCh          chan struct{}                     // closed by Close
+	wakeCh           chan struct{}                     // cuts the backoff delay short
+	session          *sessionCache                     // shared by all connections
+	messageFunc      utils.Atomic[func(ControlMessage)]
+
+	m            sync.Mutex
+	group        *ServerGroup // servers to connect to
+	groupGen     int          // incremented when the group changes
+	client       Client
+	info         *HandshakeInfo
+	count        int
+	retiredTx    uint64 // traffic of previous connections
+	retiredRx    uint64
+	bandwidth    *[2]uint64 // tx and rx set by SetBandwidth, nil if not set
+	closed       bool       // permanent close
+	backoff      backoff
+	reconnecting bool         // reconnectLoop is running
+	lastErr      error        // why the client is disconnected, returned by dials until it reconnects
+	waiters      []chan error // get the result of the next connection attempt
 }
 
 // NewReconnectableClient creates a reconnectable client.
 // If lazy is true, the client will not connect until the first call to TCP() or UDP().
 // We use a function for config mainly to delay config evaluation
 // (which involves DNS resolution) until the actual connection attempt.
-func NewReconnectableClient(configFunc func() (*Config, error), connectedFunc func(Client, *HandshakeInfo, int), lazy bool) (Client, error) {
+// disconnectedFunc can be nil. It is called with the reason whenever the connection
+// is lost, or a connection attempt fails (e.g. with an AuthError).
+func NewReconnectableClient(configFunc func() (*Config, error), connectedFunc func(Client, *HandshakeInfo, int),
+	disconnectedFunc func(Client, error), lazy bool,
+) (ReconnectableClient, error) {
+	return NewReconnectableClientGroup(singleServerGroup(configFunc), ReconnectConfig{}, connectedFunc, disconnectedFunc, lazy)
+}
+
+// NewReconnectableClientGroup is like NewReconnectableClient, but connects to one of
+// the servers in group, and reconnects as set in reconnectConfig (zero fields take defaults).
+// When a server cannot be connected to or rejects the auth,
+// disconnectedFunc is called and the next one is tried right away.
+// The group is closed along with the client.
+func NewReconnectableClientGroup(group *ServerGroup, reconnectConfig ReconnectConfig,
+	connectedFunc func(Client, *HandshakeInfo, int), disconnectedFunc func(Client, error), lazy bool,
+) (ReconnectableClient, error) {
+	if err := reconnectConfig.verifyAndFill(); err != nil {
+		_ = group.Close()
+		return nil, err
+	}
 	rc := &reconnectableClientImpl{
-		configFunc:    configFunc,
-		connectedFunc: connectedFunc,
+		connectedFunc:    connectedFunc,
+		disconnectedFunc: disconnectedFunc,
+		closeCh:          make(chan struct{}),
+		wakeCh:           make(chan struct{}, 1),
+		group:            group,
+		session:          newSessionCache(),
+		backoff:          backoff{Config: reconnectConfig},
 	}
 	if !lazy {
-		if err := rc.reconnect(); err != nil {
+		if err := rc.connect(); err != nil {
+			_ = rc.Close()
 			return nil, err
 		}
 	}
 	return rc, nil
 }
 
-func (rc *reconnectableClientImpl) reconnect() error {
-	if rc.client != nil {
-		_ = rc.client.Close()
+func singleServerGroup(configFunc func() (*Config, error)) *ServerGroup {
+	return NewServerGroup([]func() (*Config, error){configFunc}, ServerPolicyFailover, 0)
+}
+
+// closeNotifier is implemented by clients that can tell when their connection is lost,
+// so that it can be replaced before a dial fails on it.
+type closeNotifier interface {
+	done() <-chan struct{}
+	closeErr() error
+}
+
+// retire adds the traffic of a client that is being replaced to the totals.
+// Must be called with rc.m held.
+func (rc *reconnectableClientImpl) retire(client Client) {
+	s := client.Stats()
+	rc.retiredTx += s.Tx
+	rc.retiredRx += s.Rx
+}
+
+// connect waits for the result of the next connection attempt.
+func (rc *reconnectableClientImpl) connect() error {
+	rc.m.Lock()
+	if rc.closed {
+		rc.m.Unlock()
+		return coreErrs.ClosedError{}
 	}
-	var info *HandshakeInfo
-	config, err := rc.configFunc()
-	if err != nil {
-		return err
+	ch := rc.waitLocked()
+	rc.m.Unlock()
+	return <-ch
+}
+
+// waitLocked returns a channel that receives the result of the next connection attempt.
+// Must be called with rc.m held.
+func (rc *reconnectableClientImpl) waitLocked() chan error {
+	ch := make(chan error, 1)
+	rc.waiters = append(rc.waiters, ch)
+	rc.startLocked()
+	return ch
+}
+
+// startLocked starts the reconnect loop, or makes it try again right away if it is already running.
+// Must be called with rc.m held.
+func (rc *reconnectableClientImpl) startLocked() {
+	if rc.reconnecting {
+		select {
+		case rc.wakeCh <- struct{}{}:
+		default:
+		}
+		return
 	}
-	rc.client, info, err = NewClient(config)
-	if err != nil {
-		return err
-	} else {
-		rc.count++
-		if rc.connectedFunc != nil {
-			rc.connectedFunc(rc, info, rc.count)
+	rc.reconnecting = true
+	go rc.reconnectLoop()
+}
+
+// reconnectLoop connects in the background until it succeeds or the client is closed.
+// The current client, if any, is kept until the new one is connected.
+func (rc *reconnectableClientImpl) reconnectLoop() {
+	for {
+		rc.m.Lock()
+		if rc.closed {
+			rc.m.Unlock()
+			return
+		}
+		group, gen := rc.group, rc.groupGen
+		rc.m.Unlock()
+
+		client, info, err := group.connect(rc.prepareConfig, func(err error) {
+			if rc.disconnectedFunc != nil {
+				rc.disconnectedFunc(rc, err)
+			}
+		})
+
+		rc.m.Lock()
+		if rc.closed {
+			rc.m.Unlock()
+			if client != nil {
+				_ = client.Close()
+			}
+			return
+		}
+		if gen != rc.groupGen {
+			// The servers changed during the attempt, connect to the new ones instead
+			rc.m.Unlock()
+			if client != nil {
+				_ = client.Close()
+			}
+			continue
+		}
+		waiters := rc.waiters
+		rc.waiters = nil
+		if err == nil {
+			if rc.client != nil {
+				rc.retire(rc.client)
+				_ = rc.client.Close()
+			}
+			rc.client, rc.info = client, info
+			rc.count++
+			count := rc.count
+			rc.reconnecting = false
+			rc.lastErr = nil
+			rc.backoff.Reset()
+			rc.m.Unlock()
+			for _, w := range waiters {
+				w <- nil
+			}
+			if rc.connectedFunc != nil {
+				rc.connectedFunc(rc, info, count)
+			}
+			go rc.watch(client)
+			return
+		}
+		rc.lastErr = err
+		delay := rc.backoff.Next()
+		rc.m.Unlock()
+		for _, w := range waiters {
+			w <- err
+		}
+		select {
+		case <-time.After(delay):
+		case <-rc.wakeCh:
+		case <-rc.closeCh:
+			return
 		}
-		return nil
+	}
+}
+
+// watch reconnects as soon as the connection of client is lost, if client can tell.
+func (rc *reconnectableClientImpl) watch(client Client) {
+	n, ok := client.(closeNotifier)
+	if !ok {
+		return
+	}
+	select {
+	case <-n.done():
+		rc.lost(client, n.closeErr())
+	case <-rc.closeCh:
+	}
+}
+
+// lost drops client if it is still the current one, and starts reconnecting in the background.
+func (rc *reconnectableClientImpl) lost(client Client, err error) {
+	rc.m.Lock()
+	if rc.closed || rc.client != client {
+		// This check is in case the client is already changed by another goroutine
+		rc.m.Unlock()
+		return
+	}
+	rc.retire(client)
+	rc.client, rc.info = nil, nil
+	rc.lastErr = err
+	rc.startLocked()
+	rc.m.Unlock()
+	_ = client.Close()
+	if rc.disconnectedFunc != nil {
+		rc.disconnectedFunc(rc, err)
 	}
 }
 
 // clientDo calls f with the current client.
-// If the client is nil, it will first reconnect.
+// If the client has not connected yet, it waits for the connection attempt, or until ctx is done.
+// If it has lost its connection, it fails right away with a ReconnectingError.
 // It will also detect if the client is closed, and if so,
-// set it to nil for reconnect next time.
-func (rc *reconnectableClientImpl) clientDo(f func(Client) (interface{}, error)) (interface{}, error) {
+// drop it and reconnect in the background.
+func (rc *reconnectableClientImpl) clientDo(ctx context.Context, f func(Client) (interface{}, error)) (interface{}, error) {
 	rc.m.Lock()
+	for rc.client == nil && rc.lastErr == nil && !rc.closed {
+		// Not connected yet (lazy mode, or after Reconnect), wait for it.
+		// This loops in case Reconnect is called again before we get the lock back.
+		ch := rc.waitLocked()
+		rc.m.Unlock()
+		select {
+		case err := <-ch:
+			if err != nil {
+				return nil, err
+			}
+		case <-ctx.Done():
+			// The attempt goes on in the background, ch is buffered
+			return nil, ctx.Err()
+		}
+		rc.m.Lock()
+	}
 	if rc.closed {
 		rc.m.Unlock()
 		return nil, coreErrs.ClosedError{}
 	}
 	if rc.client == nil {
-		// No active connection, connect first
-		if err := rc.reconnect(); err != nil {
-			rc.m.Unlock()
-			return nil, err
-		}
+		err := rc.lastErr
+		rc.m.Unlock()
+		return nil, coreErrs.ReconnectingError{Err: err}
 	}
 	client := rc.client
 	rc.m.Unlock()
 
 	ret, err := f(client)
 	if _, ok := err.(coreErrs.ClosedError); ok {
-		// Connection closed, set client to nil for reconnect next time
-		rc.m.Lock()
-		if rc.client == client {
-			// This check is in case the client is already changed by another goroutine
-			rc.client = nil
-		}
-		rc.m.Unlock()
+		rc.lost(client, err)
 	}
 	return ret, err
 }
 
 func (rc *reconnectableClientImpl) TCP(addr string) (net.Conn, error) {
-	if c, err := rc.clientDo(func(client Client) (interface{}, error) {
-		return client.TCP(addr)
+	return rc.TCPContext(context.Background(), addr)
+}
+
+func (rc *reconnectableClientImpl) TCPContext(ctx context.Context, addr string) (net.Conn, error) {
+	if c, err := rc.clientDo(ctx, func(client Client) (interface{}, error) {
+		return client.TCPContext(ctx, addr)
 	}); err != nil {
 		return nil, err
 	} else {
@@ -100,8 +326,12 @@ func (rc *reconnectableClientImpl) TCP(addr string) (net.Conn, error) {
 }
 
 func (rc *reconnectableClientImpl) UDP() (HyUDPConn, error) {
-	if c, err := rc.clientDo(func(client Client) (interface{}, error) {
-		return client.UDP()
+	return rc.UDPContext(context.Background())
+}
+
+func (rc *reconnectableClientImpl) UDPContext(ctx context.Context) (HyUDPConn, error) {
+	if c, err := rc.clientDo(ctx, func(client Client) (interface{}, error) {
+		return client.UDPContext(ctx)
 	}); err != nil {
 		return nil, err
 	} else {
@@ -109,10 +339,121 @@ func (rc *reconnectableClientImpl) UDP() (HyUDPConn, error) {
 	}
 }
 
+func (rc *reconnectableClientImpl) Stats() Stats {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	var s Stats
+	if rc.client != nil {
+		s = rc.client.Stats()
+	}
+	s.Tx += rc.retiredTx
+	s.Rx += rc.retiredRx
+	if rc.count > 1 {
+		s.ReconnectCount = rc.count - 1
+	}
+	return s
+}
+
+func (rc *reconnectableClientImpl) CloseConn(id uint64) bool {
+	rc.m.Lock()
+	client := rc.client
+	rc.m.Unlock()
+	if client == nil {
+		return false
+	}
+	return client.CloseConn(id)
+}
+
+func (rc *reconnectableClientImpl) Reconnect() error {
+	rc.m.Lock()
+	if rc.closed {
+		rc.m.Unlock()
+		return coreErrs.ClosedError{}
+	}
+	client := rc.client
+	if client != nil {
+		rc.retire(client)
+		rc.client, rc.info = nil, nil
+	}
+	rc.lastErr = nil
+	rc.backoff.Reset()
+	ch := rc.waitLocked()
+	rc.m.Unlock()
+	if client != nil {
+		_ = client.Close()
+	}
+	return <-ch
+}
+
+func (rc *reconnectableClientImpl) HandshakeInfo() *HandshakeInfo {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	return rc.info
+}
+
+func (rc *reconnectableClientImpl) SetMessageFunc(f func(msg ControlMessage)) {
+	rc.messageFunc.Store(f)
+}
+
+// prepareConfig sets what the connections of the client share on a config.
+func (rc *reconnectableClientImpl) prepareConfig(config *Config) {
+	config.session = rc.session
+	rc.m.Lock()
+	config.reconnect = rc.backoff.Config
+	if bw := rc.bandwidth; bw != nil {
+		config.BandwidthConfig.MaxTx, config.BandwidthConfig.MaxRx = bw[0], bw[1]
+	}
+	rc.m.Unlock()
+	if config.MessageFunc == nil {
+		config.MessageFunc = func(msg ControlMessage) {
+			if f := rc.messageFunc.Load(); f != nil {
+				f(msg)
+			}
+		}
+	}
+}
+
+func (rc *reconnectableClientImpl) SetBandwidth(tx, rx uint64) error {
+	rc.m.Lock()
+	rc.bandwidth = &[2]uint64{tx, rx}
+	client := rc.client
+	rc.m.Unlock()
+	if bs, ok := client.(bandwidthSetter); ok {
+		return bs.setBandwidth(tx, rx)
+	}
+	return nil
+}
+
+func (rc *reconnectableClientImpl) SetConfigFunc(configFunc func() (*Config, error)) {
+	rc.SetServerGroup(singleServerGroup(configFunc))
+}
+
+func (rc *reconnectableClientImpl) SetServerGroup(group *ServerGroup) {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	_ = rc.group.Close()
+	rc.group = group
+	rc.groupGen++
+	rc.bandwidth = nil
+	if rc.client != nil || rc.reconnecting {
+		rc.backoff.Reset()
+		rc.startLocked()
+	}
+}
+
 func (rc *reconnectableClientImpl) Close() error {
 	rc.m.Lock()
 	defer rc.m.Unlock()
+	if rc.closed {
+		return nil
+	}
 	rc.closed = true
+	close(rc.closeCh)
+	_ = rc.group.Close()
+	for _, w := range rc.waiters {
+		w <- coreErrs.ClosedError{}
+	}
+	rc.waiters = nil
 	if rc.client != nil {
 		return rc.client.Close()
 	}
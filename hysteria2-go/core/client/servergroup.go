@@ -0,0 +1,211 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServerPolicy decides the order in which a ServerGroup tries its servers.
+// Whatever the policy, servers that are known to be healthy are always tried
+// before those whose last connection attempt or health check failed.
+type ServerPolicy int
+
+const (
+	// ServerPolicyFailover tries the servers in the order they were given.
+	ServerPolicyFailover ServerPolicy = iota
+	// ServerPolicyRoundRobin starts each connection attempt with the server after the previous one.
+	ServerPolicyRoundRobin
+	// ServerPolicyLowestLatency tries the servers with the shortest handshake time first.
+	ServerPolicyLowestLatency
+)
+
+// ServerStatus is the health of a server in a ServerGroup,
+// as of its last connection attempt or health check.
+type ServerStatus struct {
+	Healthy bool
+	RTT     time.Duration // Handshake time, 0 if unknown
+	Err     error         // Set if unhealthy
+}
+
+// ServerGroup is a set of servers a reconnectable client can connect to.
+// When connecting, it tries them in the order given by the policy until one succeeds,
+// so a dead or rejecting server is skipped instead of being retried.
+type ServerGroup struct {
+	configFuncs []func() (*Config, error)
+	policy      ServerPolicy
+
+	mutex  sync.Mutex
+	status []ServerStatus
+	next   int // for round-robin
+	active int // the server connected to by the last connect, -1 if none
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServerGroup creates a server group. As with NewReconnectableClient, configs are
+// given as functions to delay their evaluation until the actual connection attempt.
+// If healthCheckInterval is positive, every server is checked with a handshake at that
+// interval in the background until Close is called.
+func NewServerGroup(configFuncs []func() (*Config, error), policy ServerPolicy, healthCheckInterval time.Duration) *ServerGroup {
+	g := &ServerGroup{
+		configFuncs: configFuncs,
+		policy:      policy,
+		status:      make([]ServerStatus, len(configFuncs)),
+		stopCh:      make(chan struct{}),
+		active:      -1,
+	}
+	for i := range g.status {
+		// Unknown servers are assumed to be healthy until proven otherwise
+		g.status[i].Healthy = true
+	}
+	if healthCheckInterval > 0 {
+		go g.healthCheckLoop(healthCheckInterval)
+	}
+	return g
+}
+
+// order returns the indices of the servers in the order they should be tried.
+func (g *ServerGroup) order() []int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	n := len(g.configFuncs)
+	idx := make([]int, n)
+	if n == 0 {
+		return idx
+	}
+	for i := range idx {
+		idx[i] = i
+	}
+	switch g.policy {
+	case ServerPolicyRoundRobin:
+		for i := range idx {
+			idx[i] = (g.next + i) % n
+		}
+		g.next = (g.next + 1) % n
+	case ServerPolicyLowestLatency:
+		sort.SliceStable(idx, func(a, b int) bool {
+			ra, rb := g.status[idx[a]].RTT, g.status[idx[b]].RTT
+			if ra == 0 || rb == 0 {
+				// Unknown latency goes last
+				return rb == 0 && ra != 0
+			}
+			return ra < rb
+		})
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return g.status[idx[a]].Healthy && !g.status[idx[b]].Healthy
+	})
+	return idx
+}
+
+func (g *ServerGroup) report(i int, rtt time.Duration, err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if err != nil {
+		g.status[i] = ServerStatus{Healthy: false, Err: err}
+	} else {
+		g.status[i] = ServerStatus{Healthy: true, RTT: rtt}
+	}
+}
+
+func (g *ServerGroup) setActive(i int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.active = i
+}
+
+// connect tries the servers in order until one succeeds.
+// prepareFunc, if not nil, is called on every config before connecting with it.
+// failedFunc, if not nil, is called for every server that could not be connected to,
+// including those whose config could not be made.
+// If all of them fail, the last error is returned.
+func (g *ServerGroup) connect(prepareFunc func(*Config), failedFunc func(err error)) (Client, *HandshakeInfo, error) {
+	g.setActive(-1)
+	var lastErr error
+	for _, i := range g.order() {
+		config, err := g.configFuncs[i]()
+		if err == nil {
+			if prepareFunc != nil {
+				prepareFunc(config)
+			}
+			start := time.Now()
+			var c Client
+			var info *HandshakeInfo
+			c, info, err = NewClient(config)
+			g.report(i, time.Since(start), err)
+			if err == nil {
+				g.setActive(i)
+				return c, info, nil
+			}
+		} else {
+			g.report(i, 0, err)
+		}
+		if failedFunc != nil {
+			failedFunc(err)
+		}
+		lastErr = err
+	}
+	return nil, nil, lastErr
+}
+
+// Check handshakes with every server concurrently and records their health and latency.
+// The probes stop at the QUIC handshake and never authenticate, so they don't count
+// towards the connection limits of the user on the server, and their RTT doesn't include
+// the auth request. The server the client is connected to is skipped as long as
+// it is healthy, its connection is proof enough.
+func (g *ServerGroup) Check() {
+	g.mutex.Lock()
+	active := g.active
+	if active >= 0 && !g.status[active].Healthy {
+		active = -1
+	}
+	g.mutex.Unlock()
+	var wg sync.WaitGroup
+	for i := range g.configFuncs {
+		if i == active {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			config, err := g.configFuncs[i]()
+			if err != nil {
+				g.report(i, 0, err)
+				return
+			}
+			rtt, err := probeServer(config)
+			g.report(i, rtt, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Status returns the health of each server, in the order they were given.
+func (g *ServerGroup) Status() []ServerStatus {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]ServerStatus(nil), g.status...)
+}
+
+func (g *ServerGroup) healthCheckLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.Check()
+		case <-g.stopCh:
+			return
+		}
+	}
+}
+
+// Close stops the background health check. It does not affect established connections.
+func (g *ServerGroup) Close() error {
+	g.closeOnce.Do(func() {
+		close(g.stopCh)
+	})
+	return nil
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerGroupOrder(t *testing.T) {
+	configFuncs := make([]func() (*Config, error), 3)
+	newGroup := func(policy ServerPolicy) *ServerGroup {
+		return NewServerGroup(configFuncs, policy, 0)
+	}
+
+	g := newGroup(ServerPolicyFailover)
+	assert.Equal(t, []int{0, 1, 2}, g.order())
+	g.report(0, 0, errors.New("down"))
+	assert.Equal(t, []int{1, 2, 0}, g.order())
+	g.report(0, 10*time.Millisecond, nil)
+	assert.Equal(t, []int{0, 1, 2}, g.order())
+
+	g = newGroup(ServerPolicyRoundRobin)
+	assert.Equal(t, []int{0, 1, 2}, g.order())
+	assert.Equal(t, []int{1, 2, 0}, g.order())
+	g.report(0, 0, errors.New("down"))
+	assert.Equal(t, []int{2, 1, 0}, g.order())
+
+	g = newGroup(ServerPolicyLowestLatency)
+	g.report(0, 30*time.Millisecond, nil)
+	g.report(2, 10*time.Millisecond, nil)
+	assert.Equal(t, []int{2, 0, 1}, g.order())
+	g.report(2, 0, errors.New("down"))
+	assert.Equal(t, []int{0, 1, 2}, g.order())
+}
+
+func TestServerGroupConnectFailover(t *testing.T) {
+	errDown := errors.New("down")
+	var tried []int
+	configFuncs := make([]func() (*Config, error), 3)
+	for i := range configFuncs {
+		configFuncs[i] = func() (*Config, error) {
+			tried = append(tried, i)
+			return nil, errDown
+		}
+	}
+	g := NewServerGroup(configFuncs, ServerPolicyFailover, 0)
+	var failed []error
+	_, _, err := g.connect(nil, func(err error) {
+		failed = append(failed, err)
+	})
+	assert.Equal(t, errDown, err)
+	assert.Equal(t, []int{0, 1, 2}, tried)
+	assert.Equal(t, []error{errDown, errDown, errDown}, failed)
+	for _, s := range g.Status() {
+		assert.False(t, s.Healthy)
+		assert.Equal(t, errDown, s.Err)
+	}
+}
+
+func TestServerGroupCheck(t *testing.T) {
+	errDown := errors.New("down")
+	var mutex sync.Mutex
+	var checked []int
+	configFuncs := make([]func() (*Config, error), 3)
+	for i := range configFuncs {
+		configFuncs[i] = func() (*Config, error) {
+			mutex.Lock()
+			checked = append(checked, i)
+			mutex.Unlock()
+			return nil, errDown
+		}
+	}
+	g := NewServerGroup(configFuncs, ServerPolicyFailover, 0)
+
+	// The healthy active server is skipped
+	g.setActive(1)
+	g.Check()
+	assert.ElementsMatch(t, []int{0, 2}, checked)
+	status := g.Status()
+	assert.False(t, status[0].Healthy)
+	assert.True(t, status[1].Healthy)
+	assert.False(t, status[2].Healthy)
+
+	// Unless it's no longer healthy
+	checked = nil
+	g.report(1, 0, errDown)
+	g.Check()
+	assert.ElementsMatch(t, []int{0, 1, 2}, checked)
+}
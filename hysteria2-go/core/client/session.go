@@ -0,0 +1,42 @@
+package client
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+const sessionCacheCapacity = 64
+
+// sessionCache keeps what a client needs to resume sessions with servers across
+// reconnects: the TLS session tickets, and which servers accept the auth request in 0-RTT.
+// It is shared by all the connections made by a ReconnectableClient.
+type sessionCache struct {
+	TLS tls.ClientSessionCache
+
+	mutex   sync.Mutex
+	zeroRTT map[string]bool // by server address
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{
+		TLS:     tls.NewLRUClientSessionCache(sessionCacheCapacity),
+		zeroRTT: make(map[string]bool),
+	}
+}
+
+// ZeroRTT returns whether the server at addr said it accepts the auth request in 0-RTT.
+func (c *sessionCache) ZeroRTT(addr string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.zeroRTT[addr]
+}
+
+func (c *sessionCache) SetZeroRTT(addr string, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if ok {
+		c.zeroRTT[addr] = true
+	} else {
+		delete(c.zeroRTT, addr)
+	}
+}
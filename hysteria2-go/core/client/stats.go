@@ -0,0 +1,229 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConnTypeTCP = "tcp"
+	ConnTypeUDP = "udp"
+)
+
+// Stats is a snapshot of the traffic counters of a client.
+type Stats struct {
+	// Tx and Rx are the cumulative payload bytes sent and received,
+	// including those of previous connections for a reconnectable client.
+	Tx uint64
+	Rx uint64
+	// ActiveStreams is the number of currently open TCP streams,
+	// ActiveUDPSessions the number of currently open UDP sessions.
+	ActiveStreams     int
+	ActiveUDPSessions int
+	// ReconnectCount is the number of times a reconnectable client
+	// has connected again after its first connection. Always 0 for a plain client.
+	ReconnectCount int
+	// RTT is the smoothed round-trip time of the connection to the server,
+	// and Loss the recent fraction of packets lost on it (0 if the health monitor is disabled).
+	// Both are averaged over the connections of a pool, and 0 while disconnected.
+	RTT  time.Duration
+	Loss float64
+	// Paths has the path stats of each QUIC connection to the server,
+	// one for a plain client or more for a pool. Empty while disconnected.
+	Paths []PathStats
+	// Conns lists the open TCP streams and UDP sessions, oldest first.
+	Conns []ConnStats
+}
+
+// PathStats is a snapshot of the path quality of a QUIC connection
+// and the state of its congestion controller.
+type PathStats struct {
+	SmoothedRTT      time.Duration
+	MinRTT           time.Duration
+	CongestionWindow uint64 // bytes, 0 for Reno
+	BytesInFlight    uint64 // 0 for Reno
+	PacketsSent      uint64
+	PacketsLost      uint64
+	BytesLost        uint64
+	MTU              uint64 // current max datagram size, 0 for Reno
+	Congestion       string // "brutal", "bbr" or "reno"
+	BrutalAckRate    float64
+	BrutalBandwidth  uint64 // bytes per second, the current target
+	BBRMode          string // "startup", "drain", "probeBW" or "probeRTT"
+	BBRBandwidth     uint64 // BBR bandwidth estimate in bytes per second
+}
+
+// ConnStats holds the counters of a single TCP stream or UDP session.
+type ConnStats struct {
+	ID    uint64
+	Type  string // ConnTypeTCP or ConnTypeUDP
+	Addr  string // Requested address, or the last one sent to for UDP sessions (empty until the first packet)
+	Tx    uint64
+	Rx    uint64
+	Since time.Time
+}
+
+// statsTracker keeps the counters of a single clientImpl.
+type statsTracker struct {
+	tx atomic.Uint64
+	rx atomic.Uint64
+
+	mutex sync.Mutex
+	conns map[uint64]*connCounter
+	ids   *atomic.Uint64 // last assigned ID, can be shared so that IDs are unique across trackers
+}
+
+func newStatsTracker() *statsTracker {
+	return newSharedStatsTracker(new(atomic.Uint64))
+}
+
+func newSharedStatsTracker(ids *atomic.Uint64) *statsTracker {
+	return &statsTracker{conns: make(map[uint64]*connCounter), ids: ids}
+}
+
+// Open starts tracking a new TCP stream or UDP session.
+// closeFunc is used by CloseConn to close it.
+func (t *statsTracker) Open(typ, addr string, closeFunc func() error) *connCounter {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	c := &connCounter{
+		tracker:   t,
+		id:        t.ids.Add(1),
+		typ:       typ,
+		since:     time.Now(),
+		closeFunc: closeFunc,
+	}
+	if addr != "" {
+		c.addr.Store(&addr)
+	}
+	t.conns[c.id] = c
+	return c
+}
+
+func (t *statsTracker) Stats() Stats {
+	s := Stats{
+		Tx: t.tx.Load(),
+		Rx: t.rx.Load(),
+	}
+	t.mutex.Lock()
+	s.Conns = make([]ConnStats, 0, len(t.conns))
+	for _, c := range t.conns {
+		s.Conns = append(s.Conns, c.Stats())
+		if c.typ == ConnTypeUDP {
+			s.ActiveUDPSessions++
+		} else {
+			s.ActiveStreams++
+		}
+	}
+	t.mutex.Unlock()
+	sort.Slice(s.Conns, func(i, j int) bool {
+		return s.Conns[i].ID < s.Conns[j].ID
+	})
+	return s
+}
+
+// Active returns the number of open TCP streams and UDP sessions.
+func (t *statsTracker) Active() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.conns)
+}
+
+// CloseConn closes the active TCP stream or UDP session with the given ID.
+// It returns false if there is no such connection.
+func (t *statsTracker) CloseConn(id uint64) bool {
+	t.mutex.Lock()
+	c, ok := t.conns[id]
+	t.mutex.Unlock()
+	if !ok {
+		return false
+	}
+	_ = c.closeFunc()
+	return true
+}
+
+// connCounter counts the bytes of one TCP stream or UDP session,
+// adding them to the tracker's totals as well.
+type connCounter struct {
+	tracker *statsTracker
+	id      uint64
+	typ     string
+	addr    atomic.Pointer[string] // nil if none yet
+	since   time.Time
+	tx      atomic.Uint64
+	rx      atomic.Uint64
+
+	closeFunc func() error
+}
+
+func (c *connCounter) AddTx(n int) {
+	if n > 0 {
+		c.tx.Add(uint64(n))
+		c.tracker.tx.Add(uint64(n))
+	}
+}
+
+func (c *connCounter) AddRx(n int) {
+	if n > 0 {
+		c.rx.Add(uint64(n))
+		c.tracker.rx.Add(uint64(n))
+	}
+}
+
+// SetAddr records addr as the latest destination of a UDP session.
+func (c *connCounter) SetAddr(addr string) {
+	if p := c.addr.Load(); p == nil || *p != addr {
+		c.addr.Store(&addr)
+	}
+}
+
+// Close stops listing the connection as active. Safe to call multiple times.
+func (c *connCounter) Close() {
+	c.tracker.mutex.Lock()
+	delete(c.tracker.conns, c.id)
+	c.tracker.mutex.Unlock()
+}
+
+func (c *connCounter) Stats() ConnStats {
+	s := ConnStats{
+		ID:    c.id,
+		Type:  c.typ,
+		Tx:    c.tx.Load(),
+		Rx:    c.rx.Load(),
+		Since: c.since,
+	}
+	if p := c.addr.Load(); p != nil {
+		s.Addr = *p
+	}
+	return s
+}
+
+// statsUDPConn counts the payload bytes of a UDP session.
+type statsUDPConn struct {
+	HyUDPConn
+	Counter *connCounter
+}
+
+func (u *statsUDPConn) Receive() ([]byte, string, error) {
+	data, addr, err := u.HyUDPConn.Receive()
+	if err == nil {
+		u.Counter.AddRx(len(data))
+	}
+	return data, addr, err
+}
+
+func (u *statsUDPConn) Send(data []byte, addr string) error {
+	err := u.HyUDPConn.Send(data, addr)
+	if err == nil {
+		u.Counter.AddTx(len(data))
+		u.Counter.SetAddr(addr)
+	}
+	return err
+}
+
+func (u *statsUDPConn) Close() error {
+	u.Counter.Close()
+	return u.HyUDPConn.Close()
+}
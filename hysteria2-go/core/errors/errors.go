@@ -64,6 +64,25 @@ func (c ClosedError) Unwrap() error {
 	return c.Err
 }
 
+// ReconnectingError is returned by a reconnectable client when it has lost its connection
+// and is trying to connect again in the background. Err is why the last attempt failed,
+// or why the connection was lost.
+type ReconnectingError struct {
+	Err error
+}
+
+func (c ReconnectingError) Error() string {
+	if c.Err == nil {
+		return "reconnecting"
+	} else {
+		return "reconnecting: " + c.Err.Error()
+	}
+}
+
+func (c ReconnectingError) Unwrap() error {
+	return c.Err
+}
+
 // ProtocolError is returned when the server/client runs into an unexpected
 // or malformed request/response/message.
 type ProtocolError struct {
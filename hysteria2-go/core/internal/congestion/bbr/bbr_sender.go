@@ -81,6 +81,21 @@ const (
 	bbrModeProbeRtt
 )
 
+func (m bbrMode) String() string {
+	switch m {
+	case bbrModeStartup:
+		return "startup"
+	case bbrModeDrain:
+		return "drain"
+	case bbrModeProbeBw:
+		return "probeBW"
+	case bbrModeProbeRtt:
+		return "probeRTT"
+	default:
+		return "unknown"
+	}
+}
+
 // Indicates how the congestion control limits the amount of bytes in flight.
 type bbrRecoveryState int
 
@@ -313,6 +328,9 @@ type bbrSender struct {
 	// Recorded on packet sent. equivalent |unacked_packets_->bytes_in_flight()|
 	bytesInFlight congestion.ByteCount
 
+	// Published for Stats, as the rest is only safe to read on the connection's goroutine.
+	stats common.SenderStatsTracker
+
 	debug bool
 }
 
@@ -375,6 +393,7 @@ func newBbrSender(
 	}
 
 	b.enterStartupMode(b.clock.Now())
+	b.updateStats()
 
 	return b
 }
@@ -452,6 +471,7 @@ func (b *bbrSender) OnPacketSent(
 
 	b.lastSentPacket = packetNumber
 	b.bytesInFlight = bytesInFlight
+	b.stats.SetBytesInFlight(bytesInFlight)
 
 	if bytesInFlight == 0 {
 		b.exitingQuiescence = true
@@ -506,6 +526,7 @@ func (b *bbrSender) SetMaxDatagramSize(s congestion.ByteCount) {
 	}
 	b.recoveryWindow = min(b.maxCongestionWindow, max(b.recoveryWindow, b.minCongestionWindow))
 	b.pacer.SetMaxDatagramSize(s)
+	b.updateStats()
 }
 
 // InSlowStart implements the SendAlgorithmWithDebugInfos interface.
@@ -635,6 +656,22 @@ func (b *bbrSender) OnCongestionEventEx(priorInFlight congestion.ByteCount, even
 		b.numLossEventsInRound = 0
 		b.bytesLostInRound = 0
 	}
+
+	b.updateStats()
+}
+
+// updateStats publishes the state of the sender for Stats.
+func (b *bbrSender) updateStats() {
+	b.stats.SetCongestionWindow(b.GetCongestionWindow())
+	b.stats.SetBytesInFlight(b.bytesInFlight)
+	b.stats.SetMaxDatagramSize(b.maxDatagramSize)
+	b.stats.SetMode(b.mode.String())
+	b.stats.SetBandwidthEstimate(uint64(b.bandwidthEstimate() / BytesPerSecond))
+}
+
+// Stats returns the current state of the sender. It is safe to call from any goroutine.
+func (b *bbrSender) Stats() common.SenderStats {
+	return b.stats.Stats()
 }
 
 func (b *bbrSender) PacingRate() Bandwidth {
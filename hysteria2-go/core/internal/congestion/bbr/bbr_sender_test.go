@@ -128,3 +128,16 @@ func TestParseProfile(t *testing.T) {
 	_, err = ParseProfile("turbo")
 	require.EqualError(t, err, `unsupported BBR profile "turbo"`)
 }
+
+func TestStats(t *testing.T) {
+	const maxDatagramSize = congestion.ByteCount(1000)
+
+	b := NewBbrSender(DefaultClock{}, maxDatagramSize, ProfileStandard)
+	s := b.Stats()
+	require.Equal(t, "startup", s.Mode)
+	require.Equal(t, initialCongestionWindowPackets*maxDatagramSize, s.CongestionWindow)
+	require.Equal(t, maxDatagramSize, s.MaxDatagramSize)
+
+	b.SetMaxDatagramSize(1400)
+	require.Equal(t, congestion.ByteCount(1400), b.Stats().MaxDatagramSize)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/apernet/hysteria/core/v2/internal/congestion/common"
@@ -26,7 +27,7 @@ var _ congestion.CongestionControl = &BrutalSender{}
 
 type BrutalSender struct {
 	rttStats        congestion.RTTStatsProvider
-	bps             congestion.ByteCount
+	bps             atomic.Uint64 // can be changed from other goroutines with SetBandwidth
 	maxDatagramSize congestion.ByteCount
 	pacer           *common.Pacer
 
@@ -35,6 +36,8 @@ type BrutalSender struct {
 
 	disableLossCompensation bool
 
+	stats common.SenderStatsTracker
+
 	debug                 bool
 	lastAckPrintTimestamp int64
 }
@@ -48,18 +51,28 @@ type pktInfo struct {
 func NewBrutalSender(bps uint64, disableLossCompensation bool) *BrutalSender {
 	debug, _ := strconv.ParseBool(os.Getenv(debugEnv))
 	bs := &BrutalSender{
-		bps:                     congestion.ByteCount(bps),
 		maxDatagramSize:         congestion.InitialPacketSize,
 		ackRate:                 1,
 		disableLossCompensation: disableLossCompensation,
 		debug:                   debug,
 	}
+	bs.bps.Store(bps)
 	bs.pacer = common.NewPacer(func() congestion.ByteCount {
-		return congestion.ByteCount(float64(bs.bps) / bs.ackRate)
+		return congestion.ByteCount(float64(bs.bps.Load()) / bs.ackRate)
 	})
+	bs.stats.SetMaxDatagramSize(bs.maxDatagramSize)
+	bs.stats.SetAckRate(bs.ackRate)
+	bs.stats.SetBandwidthTarget(bps)
 	return bs
 }
 
+// SetBandwidth changes the target send rate, in bytes per second, of a running sender.
+// It is safe to call from any goroutine.
+func (b *BrutalSender) SetBandwidth(bps uint64) {
+	b.bps.Store(bps)
+	b.stats.SetBandwidthTarget(bps)
+}
+
 func (b *BrutalSender) SetRTTStatsProvider(rttStats congestion.RTTStatsProvider) {
 	b.rttStats = rttStats
 }
@@ -81,7 +94,7 @@ func (b *BrutalSender) GetCongestionWindow() congestion.ByteCount {
 	if rtt <= 0 {
 		return 10240
 	}
-	cwnd := congestion.ByteCount(float64(b.bps) * rtt.Seconds() * congestionWindowMultiplier / b.ackRate)
+	cwnd := congestion.ByteCount(float64(b.bps.Load()) * rtt.Seconds() * congestionWindowMultiplier / b.ackRate)
 	if cwnd < b.maxDatagramSize {
 		cwnd = b.maxDatagramSize
 	}
@@ -92,6 +105,8 @@ func (b *BrutalSender) OnPacketSent(sentTime monotime.Time, bytesInFlight conges
 	packetNumber congestion.PacketNumber, bytes congestion.ByteCount, isRetransmittable bool,
 ) {
 	b.pacer.SentPacket(sentTime, bytes)
+	b.stats.SetBytesInFlight(bytesInFlight)
+	b.stats.SetCongestionWindow(b.GetCongestionWindow())
 }
 
 func (b *BrutalSender) OnPacketAcked(number congestion.PacketNumber, ackedBytes congestion.ByteCount,
@@ -119,11 +134,14 @@ func (b *BrutalSender) OnCongestionEventEx(priorInFlight congestion.ByteCount, e
 		b.pktInfoSlots[slot].LossCount = uint64(len(lostPackets))
 	}
 	b.updateAckRate(currentTimestamp)
+	b.stats.SetAckRate(b.ackRate)
+	b.stats.SetBytesInFlight(common.BytesInFlightAfter(priorInFlight, ackedPackets, lostPackets))
 }
 
 func (b *BrutalSender) SetMaxDatagramSize(size congestion.ByteCount) {
 	b.maxDatagramSize = size
 	b.pacer.SetMaxDatagramSize(size)
+	b.stats.SetMaxDatagramSize(size)
 	if b.debug {
 		b.debugPrint("SetMaxDatagramSize: %d", size)
 	}
@@ -170,6 +188,11 @@ func (b *BrutalSender) updateAckRate(currentTimestamp int64) {
 	}
 }
 
+// Stats returns the current state of the sender. It is safe to call from any goroutine.
+func (b *BrutalSender) Stats() common.SenderStats {
+	return b.stats.Stats()
+}
+
 func (b *BrutalSender) InSlowStart() bool {
 	return false
 }
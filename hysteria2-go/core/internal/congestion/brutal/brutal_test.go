@@ -43,3 +43,33 @@ func TestBrutalLossCompensation(t *testing.T) {
 		})
 	}
 }
+
+func TestBrutalStats(t *testing.T) {
+	b := NewBrutalSender(1000000, false)
+	if got := b.Stats().AckRate; got != 1.0 {
+		t.Errorf("initial AckRate = %v, want 1.0", got)
+	}
+	acked := make([]congestion.AckedPacketInfo, 80)
+	for i := range acked {
+		acked[i].BytesAcked = 1000
+	}
+	lost := make([]congestion.LostPacketInfo, 20)
+	for i := range lost {
+		lost[i].BytesLost = 1000
+	}
+	b.OnCongestionEventEx(200000, monotime.Time(5*time.Second), acked, lost)
+	s := b.Stats()
+	if s.AckRate != 0.8 {
+		t.Errorf("AckRate = %v, want 0.8", s.AckRate)
+	}
+	if s.BytesInFlight != 100000 {
+		t.Errorf("BytesInFlight = %v, want 100000", s.BytesInFlight)
+	}
+	if s.BandwidthTarget != 1000000 {
+		t.Errorf("BandwidthTarget = %v, want 1000000", s.BandwidthTarget)
+	}
+	b.SetBandwidth(500000)
+	if got := b.Stats().BandwidthTarget; got != 500000 {
+		t.Errorf("BandwidthTarget after SetBandwidth = %v, want 500000", got)
+	}
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/apernet/quic-go/congestion"
+)
+
+// SenderStats is a snapshot of the state of a congestion controller.
+type SenderStats struct {
+	CongestionWindow congestion.ByteCount
+	BytesInFlight    congestion.ByteCount
+	MaxDatagramSize  congestion.ByteCount
+
+	AckRate           float64 // Brutal only
+	BandwidthTarget   uint64  // Brutal only, in bytes per second
+	Mode              string  // BBR only
+	BandwidthEstimate uint64  // BBR only, in bytes per second
+}
+
+// SenderStatsTracker keeps the latest state of a congestion controller, which is only
+// updated on the connection's own goroutine, for other goroutines to read.
+type SenderStatsTracker struct {
+	congestionWindow  atomic.Int64
+	bytesInFlight     atomic.Int64
+	maxDatagramSize   atomic.Int64
+	ackRate           atomic.Uint64 // float64 bits
+	bandwidthTarget   atomic.Uint64
+	mode              atomic.Value // string
+	bandwidthEstimate atomic.Uint64
+}
+
+func (t *SenderStatsTracker) SetCongestionWindow(n congestion.ByteCount) {
+	t.congestionWindow.Store(int64(n))
+}
+
+func (t *SenderStatsTracker) SetBytesInFlight(n congestion.ByteCount) {
+	t.bytesInFlight.Store(int64(n))
+}
+
+func (t *SenderStatsTracker) SetMaxDatagramSize(n congestion.ByteCount) {
+	t.maxDatagramSize.Store(int64(n))
+}
+
+func (t *SenderStatsTracker) SetAckRate(rate float64) {
+	t.ackRate.Store(math.Float64bits(rate))
+}
+
+func (t *SenderStatsTracker) SetBandwidthTarget(bps uint64) {
+	t.bandwidthTarget.Store(bps)
+}
+
+func (t *SenderStatsTracker) SetMode(mode string) {
+	t.mode.Store(mode)
+}
+
+func (t *SenderStatsTracker) SetBandwidthEstimate(bps uint64) {
+	t.bandwidthEstimate.Store(bps)
+}
+
+func (t *SenderStatsTracker) Stats() SenderStats {
+	mode, _ := t.mode.Load().(string)
+	return SenderStats{
+		CongestionWindow:  congestion.ByteCount(t.congestionWindow.Load()),
+		BytesInFlight:     congestion.ByteCount(t.bytesInFlight.Load()),
+		MaxDatagramSize:   congestion.ByteCount(t.maxDatagramSize.Load()),
+		AckRate:           math.Float64frombits(t.ackRate.Load()),
+		BandwidthTarget:   t.bandwidthTarget.Load(),
+		Mode:              mode,
+		BandwidthEstimate: t.bandwidthEstimate.Load(),
+	}
+}
+
+// BytesInFlightAfter returns the bytes in flight after the given packets have been acked or lost.
+func BytesInFlightAfter(priorInFlight congestion.ByteCount, ackedPackets []congestion.AckedPacketInfo, lostPackets []congestion.LostPacketInfo) congestion.ByteCount {
+	n := priorInFlight
+	for _, p := range ackedPackets {
+		n -= p.BytesAcked
+	}
+	for _, p := range lostPackets {
+		n -= p.BytesLost
+	}
+	return max(n, 0)
+}
@@ -0,0 +1,66 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/internal/congestion/common"
+	"github.com/apernet/quic-go"
+)
+
+// Sender is a congestion controller of ours that reports its state.
+type Sender interface {
+	Stats() common.SenderStats
+}
+
+// Controller is the congestion controller in use on a connection.
+type Controller struct {
+	Type   string // TypeBrutal, TypeBBR or TypeReno
+	Sender Sender // nil for Reno, which is built into quic-go
+}
+
+// PathStats is a snapshot of the path quality of a connection and the state of its congestion controller.
+// The public client and server packages have identical types to convert it to.
+type PathStats struct {
+	SmoothedRTT      time.Duration
+	MinRTT           time.Duration
+	CongestionWindow uint64 // 0 for Reno
+	BytesInFlight    uint64 // 0 for Reno
+	PacketsSent      uint64
+	PacketsLost      uint64
+	BytesLost        uint64
+	MTU              uint64 // 0 for Reno
+	Congestion       string // TypeBrutal, TypeBBR or TypeReno
+	BrutalAckRate    float64
+	BrutalBandwidth  uint64 // bytes per second
+	BBRMode          string
+	BBRBandwidth     uint64 // bytes per second
+}
+
+// GetPathStats returns the current path stats of conn, which uses the controller c.
+// It is safe to call from any goroutine.
+func GetPathStats(conn *quic.Conn, c Controller) PathStats {
+	cs := conn.ConnectionStats()
+	s := PathStats{
+		SmoothedRTT: cs.SmoothedRTT,
+		MinRTT:      cs.MinRTT,
+		PacketsSent: cs.PacketsSent,
+		PacketsLost: cs.PacketsLost,
+		BytesLost:   cs.BytesLost,
+		Congestion:  c.Type,
+	}
+	if c.Sender != nil {
+		ss := c.Sender.Stats()
+		s.CongestionWindow = uint64(ss.CongestionWindow)
+		s.BytesInFlight = uint64(ss.BytesInFlight)
+		s.MTU = uint64(ss.MaxDatagramSize)
+		switch c.Type {
+		case TypeBrutal:
+			s.BrutalAckRate = ss.AckRate
+			s.BrutalBandwidth = ss.BandwidthTarget
+		case TypeBBR:
+			s.BBRMode = ss.Mode
+			s.BBRBandwidth = ss.BandwidthEstimate
+		}
+	}
+	return s
+}
@@ -11,8 +11,9 @@ import (
 )
 
 const (
-	TypeBBR  = "bbr"
-	TypeReno = "reno"
+	TypeBBR    = "bbr"
+	TypeReno   = "reno"
+	TypeBrutal = "brutal" // Only chosen by bandwidth negotiation, not a configurable type
 )
 
 func NormalizeType(congestionType string) (string, error) {
@@ -34,12 +35,14 @@ func NormalizeBBRProfile(profile string) (string, error) {
 	return string(normalized), nil
 }
 
-func UseBBR(conn *quic.Conn, profile bbr.Profile) {
-	conn.SetCongestionControl(bbr.NewBbrSender(
+func UseBBR(conn *quic.Conn, profile bbr.Profile) Controller {
+	sender := bbr.NewBbrSender(
 		bbr.DefaultClock{},
 		seedPacketSize(conn.InitialPacketSize(), bbr.GetInitialPacketSize(conn.RemoteAddr())),
 		profile,
-	))
+	)
+	conn.SetCongestionControl(sender)
+	return Controller{Type: TypeBBR, Sender: sender}
 }
 
 // seedPacketSize picks the datagram size to seed a replacement congestion
@@ -58,15 +61,28 @@ func seedPacketSize(quicSize, byAddr congestion.ByteCount) congestion.ByteCount
 	return min(quicSize, byAddr)
 }
 
-func UseBrutal(conn *quic.Conn, tx uint64, disableLossCompensation bool) {
-	conn.SetCongestionControl(brutal.NewBrutalSender(tx, disableLossCompensation))
+func UseBrutal(conn *quic.Conn, tx uint64, disableLossCompensation bool) Controller {
+	sender := brutal.NewBrutalSender(tx, disableLossCompensation)
+	conn.SetCongestionControl(sender)
+	return Controller{Type: TypeBrutal, Sender: sender}
 }
 
-func UseConfigured(conn *quic.Conn, congestionType, bbrProfile string) {
+// SetBrutalBandwidth changes the send rate of c to tx bytes per second if it is Brutal,
+// and returns whether it is.
+func (c Controller) SetBrutalBandwidth(tx uint64) bool {
+	if s, ok := c.Sender.(*brutal.BrutalSender); ok {
+		s.SetBandwidth(tx)
+		return true
+	}
+	return false
+}
+
+func UseConfigured(conn *quic.Conn, congestionType, bbrProfile string) Controller {
 	switch congestionType {
 	case TypeReno:
-		return
+		// quic-go's built-in controller
+		return Controller{Type: TypeReno}
 	default:
-		UseBBR(conn, bbr.Profile(bbrProfile))
+		return UseBBR(conn, bbr.Profile(bbrProfile))
 	}
 }
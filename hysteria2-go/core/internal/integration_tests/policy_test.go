@@ -0,0 +1,85 @@
+package integration_tests
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	coreErrs "github.com/apernet/hysteria/core/v2/errors"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// policyAuthenticator accepts every auth string that has a policy in Policies,
+// with the auth string as the ID.
+type policyAuthenticator struct {
+	Policies map[string]*server.Policy
+}
+
+func (a *policyAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ok, id, _ = a.AuthenticatePolicy(addr, auth, tx)
+	return
+}
+
+func (a *policyAuthenticator) AuthenticatePolicy(addr net.Addr, auth string, tx uint64) (ok bool, id string, policy *server.Policy) {
+	policy, ok = a.Policies[auth]
+	return ok, auth, policy
+}
+
+// TestServerPolicy tests that the policy returned by the authenticator
+// overrides the server config for that user only.
+func TestServerPolicy(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	s, err := server.NewServer(&server.Config{
+		TLSConfig: serverTLSConfig(),
+		Conn:      udpConn,
+		Authenticator: &policyAuthenticator{Policies: map[string]*server.Policy{
+			"default": nil,
+			"limited": {
+				MaxRx:      1000000,
+				DisableUDP: true,
+				MaxConns:   1,
+			},
+			"expired":  {ExpireAt: time.Now().Add(-time.Minute)},
+			"outbound": {Outbound: "nope"},
+		}},
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	newClient := func(auth string) (client.Client, *client.HandshakeInfo, error) {
+		return client.NewClient(&client.Config{
+			ServerAddr:      udpAddr,
+			Auth:            auth,
+			TLSConfig:       client.TLSConfig{InsecureSkipVerify: true},
+			BandwidthConfig: client.BandwidthConfig{MaxTx: 10000000},
+		})
+	}
+
+	// No policy, the server config applies
+	c, info, err := newClient("default")
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.True(t, info.UDPEnabled)
+	assert.Equal(t, uint64(10000000), info.Tx)
+
+	// Limited user
+	c, info, err = newClient("limited")
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.False(t, info.UDPEnabled)
+	assert.Equal(t, uint64(1000000), info.Tx)
+
+	// Rejected: too many connections, expired, unknown outbound
+	for _, auth := range []string{"limited", "expired", "outbound"} {
+		c, _, err = newClient(auth)
+		assert.Nil(t, c)
+		_, ok := err.(coreErrs.AuthError)
+		assert.True(t, ok, auth)
+	}
+}
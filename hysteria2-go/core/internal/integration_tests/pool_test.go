@@ -0,0 +1,222 @@
+package integration_tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/internal/integration_tests/mocks"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// TestClientPool tests that a pool client keeps several connections to the server,
+// and spreads concurrent TCP streams over them.
+func TestClientPool(t *testing.T) {
+	const poolSize = 3
+
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	eventLogger := mocks.NewMockEventLogger(t)
+	connectCh := make(chan struct{}, poolSize)
+	eventLogger.EXPECT().Connect(mock.Anything, "nobody", mock.Anything).Run(func(addr net.Addr, id string, tx uint64) {
+		connectCh <- struct{}{}
+	}).Times(poolSize)
+	eventLogger.EXPECT().Disconnect(mock.Anything, "nobody", mock.Anything).Maybe()
+	var addrsMutex sync.Mutex
+	addrs := make(map[string]struct{})
+	eventLogger.EXPECT().TCPRequest(mock.Anything, "nobody", mock.Anything).Run(func(addr net.Addr, id, reqAddr string) {
+		addrsMutex.Lock()
+		addrs[addr.String()] = struct{}{}
+		addrsMutex.Unlock()
+	})
+	eventLogger.EXPECT().TCPError(mock.Anything, "nobody", mock.Anything, mock.Anything).Maybe()
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+		EventLogger:   eventLogger,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	// Create TCP echo server
+	echoAddr := "127.0.0.1:22337"
+	echoListener, err := net.Listen("tcp", echoAddr)
+	assert.NoError(t, err)
+	echoServer := &tcpEchoServer{Listener: echoListener}
+	defer echoServer.Close()
+	go echoServer.Serve()
+
+	// Create client
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+		PoolSize:   poolSize,
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// Wait for the rest of the pool to connect in the background
+	for i := 0; i < poolSize; i++ {
+		select {
+		case <-connectCh:
+		case <-time.After(5 * time.Second):
+			t.Fatal("pool connections not established")
+		}
+	}
+
+	// Open one stream more than there are connections, all kept open
+	var conns []net.Conn
+	for i := 0; i < poolSize+1; i++ {
+		conn, err := c.TCP(echoAddr)
+		assert.NoError(t, err)
+		conns = append(conns, conn)
+		sData := []byte("hello world")
+		_, err = conn.Write(sData)
+		assert.NoError(t, err)
+		rData := make([]byte, len(sData))
+		_, err = io.ReadFull(conn, rData)
+		assert.NoError(t, err)
+		assert.Equal(t, sData, rData)
+	}
+	assert.Equal(t, poolSize+1, c.Stats().ActiveStreams)
+
+	// Every connection got at least one of them
+	addrsMutex.Lock()
+	assert.Len(t, addrs, poolSize)
+	addrsMutex.Unlock()
+
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+// TestClientPoolLost tests that a reconnectable pool client reconnects on its own
+// once all of the pooled connections are lost.
+func TestClientPoolLost(t *testing.T) {
+	newServer := func() server.Server {
+		udpConn, _, err := serverConn()
+		assert.NoError(t, err)
+		auth := mocks.NewMockAuthenticator(t)
+		auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+		s, err := server.NewServer(&server.Config{
+			TLSConfig:     serverTLSConfig(),
+			Conn:          udpConn,
+			Authenticator: auth,
+		})
+		assert.NoError(t, err)
+		go s.Serve()
+		return s
+	}
+	s := newServer()
+
+	countCh := make(chan int, 2)
+	c, err := client.NewReconnectableClient(func() (*client.Config, error) {
+		return &client.Config{
+			ServerAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 14514},
+			TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+			PoolSize:   2,
+		}, nil
+	}, func(_ client.Client, _ *client.HandshakeInfo, count int) {
+		countCh <- count
+	}, nil, false)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, 1, <-countCh)
+
+	// Shutting down the server closes all connections, no dial is needed to notice
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, s.Shutdown(context.Background()))
+	s = newServer()
+	defer s.Close()
+	select {
+	case count := <-countCh:
+		assert.Equal(t, 2, count)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for reconnect")
+	}
+}
+
+// TestClientPoolMemberLost tests that a reconnectable pool client keeps going
+// when one of its connections is lost, replacing it without reconnecting as a whole.
+func TestClientPoolMemberLost(t *testing.T) {
+	udpConn, _, err := serverConn()
+	assert.NoError(t, err)
+	authCh := make(chan struct{}, 3)
+	var authCount atomic.Int32
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(_ net.Addr, _ string, _ uint64) (bool, string) {
+		authCh <- struct{}{}
+		return true, "user" + strconv.Itoa(int(authCount.Add(1)))
+	})
+	// The first UDP packet gets the connection that sent it kicked
+	var kicked atomic.Bool
+	trafficLogger := mocks.NewMockTrafficLogger(t)
+	trafficLogger.EXPECT().LogOnlineState(mock.Anything, mock.Anything).Return().Maybe()
+	trafficLogger.EXPECT().LogTraffic(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(func(id string, tx, rx uint64) bool {
+		return kicked.Swap(true)
+	})
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+		TrafficLogger: trafficLogger,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	countCh := make(chan int, 2)
+	c, err := client.NewReconnectableClient(func() (*client.Config, error) {
+		return &client.Config{
+			ServerAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 14514},
+			TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+			PoolSize:   2,
+		}, nil
+	}, func(_ client.Client, _ *client.HandshakeInfo, count int) {
+		countCh <- count
+	}, nil, false)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, 1, <-countCh)
+	waitAuth := func() {
+		select {
+		case <-authCh:
+		case <-time.After(5 * time.Second):
+			t.Fatal("pool connection not established")
+		}
+	}
+	waitAuth()
+	waitAuth()
+
+	u, err := c.UDP()
+	assert.NoError(t, err)
+	assert.NoError(t, u.Send([]byte("hello"), "127.0.0.1:22338"))
+	_, _, err = u.Receive()
+	assert.Error(t, err)
+
+	// The lost connection is replaced, and the client never reconnected
+	waitAuth()
+	u, err = c.UDP()
+	assert.NoError(t, err)
+	assert.NoError(t, u.Send([]byte("hello"), "127.0.0.1:22338"))
+	_ = u.Close()
+	select {
+	case count := <-countCh:
+		t.Fatalf("client reconnected (%d)", count)
+	case <-time.After(time.Second):
+	}
+}
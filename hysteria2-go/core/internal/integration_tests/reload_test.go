@@ -0,0 +1,80 @@
+package integration_tests
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	coreErrs "github.com/apernet/hysteria/core/v2/errors"
+	"github.com/apernet/hysteria/core/v2/internal/integration_tests/mocks"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// TestServerReloadAuthenticator tests that a reloaded Authenticator applies to new
+// connections, while connections authenticated before the reload keep working.
+func TestServerReloadAuthenticator(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	oldAuth := mocks.NewMockAuthenticator(t)
+	oldAuth.EXPECT().Authenticate(mock.Anything, "oldpassword", uint64(0)).Return(true, "nobody").Once()
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: oldAuth,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	// Create TCP echo server
+	echoAddr := "127.0.0.1:22334"
+	echoListener, err := net.Listen("tcp", echoAddr)
+	assert.NoError(t, err)
+	echoServer := &tcpEchoServer{Listener: echoListener}
+	defer echoServer.Close()
+	go echoServer.Serve()
+
+	// Connect with the old password
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		Auth:       "oldpassword",
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// Rotate the password
+	newAuth := mocks.NewMockAuthenticator(t)
+	newAuth.EXPECT().Authenticate(mock.Anything, "oldpassword", uint64(0)).Return(false, "").Once()
+	assert.NoError(t, s.Reload(&server.ReloadConfig{Authenticator: newAuth}))
+
+	// The existing connection still works
+	conn, err := c.TCP(echoAddr)
+	assert.NoError(t, err)
+	sData := []byte("hello world")
+	_, err = conn.Write(sData)
+	assert.NoError(t, err)
+	rData := make([]byte, len(sData))
+	_, err = io.ReadFull(conn, rData)
+	assert.NoError(t, err)
+	assert.Equal(t, sData, rData)
+	_ = conn.Close()
+
+	// New connections with the old password are rejected
+	c2, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		Auth:       "oldpassword",
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.Nil(t, c2)
+	_, ok := err.(coreErrs.AuthError)
+	assert.True(t, ok)
+
+	// Invalid configs are rejected without changing anything
+	assert.Error(t, s.Reload(&server.ReloadConfig{}))
+}
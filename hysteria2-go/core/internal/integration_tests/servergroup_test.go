@@ -0,0 +1,55 @@
+package integration_tests
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/internal/integration_tests/mocks"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// TestServerGroupCheck tests that a server group health check reaches the server
+// without authenticating, and that a server nobody listens on is marked unhealthy.
+func TestServerGroupCheck(t *testing.T) {
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	// No expectations, an auth request would fail the test
+	auth := mocks.NewMockAuthenticator(t)
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	deadConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	deadAddr := deadConn.LocalAddr()
+	_ = deadConn.Close()
+
+	configFunc := func(addr net.Addr) func() (*client.Config, error) {
+		return func() (*client.Config, error) {
+			return &client.Config{
+				ServerAddr: addr,
+				TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+			}, nil
+		}
+	}
+	g := client.NewServerGroup([]func() (*client.Config, error){
+		configFunc(udpAddr),
+		configFunc(deadAddr),
+	}, client.ServerPolicyFailover, 0)
+	defer g.Close()
+	g.Check()
+	status := g.Status()
+	assert.True(t, status[0].Healthy)
+	assert.Greater(t, status[0].RTT, time.Duration(0))
+	assert.False(t, status[1].Healthy)
+	assert.Error(t, status[1].Err)
+}
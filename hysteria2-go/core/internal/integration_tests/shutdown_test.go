@@ -0,0 +1,153 @@
+package integration_tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/internal/integration_tests/mocks"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// TestServerShutdownDrain tests that Shutdown refuses new TCP streams,
+// lets the existing ones finish, and returns once they have.
+func TestServerShutdownDrain(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	// Create TCP echo server
+	echoAddr := "127.0.0.1:22335"
+	echoListener, err := net.Listen("tcp", echoAddr)
+	assert.NoError(t, err)
+	echoServer := &tcpEchoServer{Listener: echoListener}
+	defer echoServer.Close()
+	go echoServer.Serve()
+
+	// Create client
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	conn, err := c.TCP(echoAddr)
+	assert.NoError(t, err)
+
+	shutdownErrChan := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErrChan <- s.Shutdown(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	// New streams are refused
+	_, err = c.TCP(echoAddr)
+	assert.Error(t, err)
+
+	// The existing stream keeps working
+	sData := []byte("hello world")
+	_, err = conn.Write(sData)
+	assert.NoError(t, err)
+	rData := make([]byte, len(sData))
+	_, err = io.ReadFull(conn, rData)
+	assert.NoError(t, err)
+	assert.Equal(t, sData, rData)
+
+	select {
+	case <-shutdownErrChan:
+		t.Fatal("Shutdown returned with a stream in flight")
+	default:
+	}
+
+	// Shutdown returns once the stream is closed
+	_ = conn.Close()
+	select {
+	case err := <-shutdownErrChan:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Shutdown did not return after the stream was closed")
+	}
+}
+
+// TestServerShutdownDeadline tests that Shutdown gives up waiting
+// when the context is done, and closes the server anyway.
+func TestServerShutdownDeadline(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+	})
+	assert.NoError(t, err)
+	go s.Serve()
+
+	// Create TCP echo server
+	echoAddr := "127.0.0.1:22336"
+	echoListener, err := net.Listen("tcp", echoAddr)
+	assert.NoError(t, err)
+	echoServer := &tcpEchoServer{Listener: echoListener}
+	defer echoServer.Close()
+	go echoServer.Serve()
+
+	// Create client
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	conn, err := c.TCP(echoAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+
+	// The stream is closed along with the connection
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+// TestServerShutdownIdle tests that Shutdown of an idle server
+// doesn't report an error even if the context is already done.
+func TestServerShutdownIdle(t *testing.T) {
+	udpConn, _, err := serverConn()
+	assert.NoError(t, err)
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: mocks.NewMockAuthenticator(t),
+	})
+	assert.NoError(t, err)
+	go s.Serve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx))
+}
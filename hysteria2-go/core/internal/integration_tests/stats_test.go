@@ -0,0 +1,120 @@
+package integration_tests
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/internal/integration_tests/mocks"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// TestClientStatsTCP tests that the client counts the bytes of its TCP streams,
+// and that closed streams are no longer listed as active.
+func TestClientStatsTCP(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	// Create TCP echo server
+	echoAddr := "127.0.0.1:22333"
+	echoListener, err := net.Listen("tcp", echoAddr)
+	assert.NoError(t, err)
+	echoServer := &tcpEchoServer{Listener: echoListener}
+	defer echoServer.Close()
+	go echoServer.Serve()
+
+	// Create client
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	conn, err := c.TCP(echoAddr)
+	assert.NoError(t, err)
+
+	sData := []byte("hello world")
+	_, err = conn.Write(sData)
+	assert.NoError(t, err)
+	rData := make([]byte, len(sData))
+	_, err = io.ReadFull(conn, rData)
+	assert.NoError(t, err)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(len(sData)), stats.Tx)
+	assert.Equal(t, uint64(len(sData)), stats.Rx)
+	assert.Equal(t, 1, stats.ActiveStreams)
+	if assert.Len(t, stats.Conns, 1) {
+		assert.Equal(t, client.ConnTypeTCP, stats.Conns[0].Type)
+		assert.Equal(t, echoAddr, stats.Conns[0].Addr)
+		assert.Equal(t, uint64(len(sData)), stats.Conns[0].Tx)
+		assert.Equal(t, uint64(len(sData)), stats.Conns[0].Rx)
+	}
+
+	// Totals are kept after the stream is closed
+	_ = conn.Close()
+	stats = c.Stats()
+	assert.Equal(t, uint64(len(sData)), stats.Tx)
+	assert.Equal(t, uint64(len(sData)), stats.Rx)
+	assert.Zero(t, stats.ActiveStreams)
+	assert.Empty(t, stats.Conns)
+}
+
+// TestClientStatsUDP tests that a UDP session is listed with the last address it sent to.
+func TestClientStatsUDP(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	// Create client
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	conn, err := c.UDP()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	stats := c.Stats()
+	if assert.Len(t, stats.Conns, 1) {
+		assert.Equal(t, client.ConnTypeUDP, stats.Conns[0].Type)
+		assert.Empty(t, stats.Conns[0].Addr)
+	}
+
+	for _, addr := range []string{"127.0.0.1:22334", "127.0.0.1:22335"} {
+		assert.NoError(t, conn.Send([]byte("hello"), addr))
+		stats = c.Stats()
+		if assert.Len(t, stats.Conns, 1) {
+			assert.Equal(t, addr, stats.Conns[0].Addr)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package integration_tests
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/quic-go/http3"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/internal/protocol"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// countingAuthenticator accepts Password, and counts how many times it is called.
+type countingAuthenticator struct {
+	Password string
+	Count    atomic.Int32
+}
+
+func (a *countingAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	a.Count.Add(1)
+	return auth == a.Password, "user"
+}
+
+func newZeroRTTServer(t *testing.T, enable0RTT bool, auth server.Authenticator) server.Server {
+	udpConn, _, err := serverConn()
+	assert.NoError(t, err)
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		QUICConfig:    server.QUICConfig{Enable0RTT: enable0RTT},
+		Conn:          udpConn,
+		Authenticator: auth,
+	})
+	assert.NoError(t, err)
+	go s.Serve()
+	return s
+}
+
+// newZeroRTTClient returns a reconnectable client, and a channel
+// with the HandshakeInfo of each of its connections.
+func newZeroRTTClient(t *testing.T, auth string) (client.ReconnectableClient, <-chan *client.HandshakeInfo) {
+	infoCh := make(chan *client.HandshakeInfo, 10)
+	c, err := client.NewReconnectableClient(func() (*client.Config, error) {
+		return &client.Config{
+			ServerAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 14514},
+			Auth:       auth,
+			TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+		}, nil
+	}, func(_ client.Client, info *client.HandshakeInfo, _ int) {
+		infoCh <- info
+	}, nil, false)
+	assert.NoError(t, err)
+	return c, infoCh
+}
+
+// reconnect reconnects c, after giving the server time to send it a session ticket.
+func reconnect(t *testing.T, c client.ReconnectableClient, infoCh <-chan *client.HandshakeInfo) *client.HandshakeInfo {
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, c.Reconnect())
+	select {
+	case info := <-infoCh:
+		return info
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for reconnect")
+		return nil
+	}
+}
+
+// TestClientZeroRTT tests that a resumed session sends the auth request in 0-RTT,
+// and that it is only processed once.
+func TestClientZeroRTT(t *testing.T) {
+	auth := &countingAuthenticator{Password: "password"}
+	s := newZeroRTTServer(t, true, auth)
+	defer s.Close()
+
+	c, infoCh := newZeroRTTClient(t, "password")
+	defer c.Close()
+	assert.False(t, (<-infoCh).Used0RTT)
+
+	info := reconnect(t, c, infoCh)
+	assert.True(t, info.Used0RTT)
+	assert.Equal(t, int32(2), auth.Count.Load())
+}
+
+// TestClientZeroRTTDisabled tests that the client never uses 0-RTT
+// with a server that doesn't enable it.
+func TestClientZeroRTTDisabled(t *testing.T) {
+	auth := &countingAuthenticator{Password: "password"}
+	s := newZeroRTTServer(t, false, auth)
+	defer s.Close()
+
+	c, infoCh := newZeroRTTClient(t, "password")
+	defer c.Close()
+	assert.False(t, (<-infoCh).Used0RTT)
+
+	info := reconnect(t, c, infoCh)
+	assert.False(t, info.Used0RTT)
+	assert.Equal(t, int32(2), auth.Count.Load())
+}
+
+// TestClientZeroRTTRejected tests that the client connects again without 0-RTT right away
+// when the server rejects it, here because it restarted with new session ticket keys.
+func TestClientZeroRTTRejected(t *testing.T) {
+	auth := &countingAuthenticator{Password: "password"}
+	s := newZeroRTTServer(t, true, auth)
+
+	c, infoCh := newZeroRTTClient(t, "password")
+	defer c.Close()
+	assert.False(t, (<-infoCh).Used0RTT)
+
+	// Restart the server
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, s.Close())
+	newAuth := &countingAuthenticator{Password: "password"}
+	s = newZeroRTTServer(t, true, newAuth)
+	defer s.Close()
+
+	info := reconnect(t, c, infoCh)
+	assert.False(t, info.Used0RTT)
+	assert.Equal(t, int32(1), newAuth.Count.Load())
+
+	// The new server gave out its own ticket
+	info = reconnect(t, c, infoCh)
+	assert.True(t, info.Used0RTT)
+}
+
+// TestServerZeroRTTDisabled tests that a server without 0-RTT enabled
+// treats an auth request sent as GET as a normal web request.
+func TestServerZeroRTTDisabled(t *testing.T) {
+	auth := &countingAuthenticator{Password: "password"}
+	s := newZeroRTTServer(t, false, auth)
+	defer s.Close()
+
+	rt := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer rt.Close()
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL: &url.URL{
+			Scheme: "https",
+			Host:   "127.0.0.1:14514",
+			Path:   protocol.URLPath,
+		},
+		Host:   protocol.URLHost,
+		Header: make(http.Header),
+	}
+	protocol.AuthRequestToHeader(req.Header, protocol.AuthRequest{Auth: "password"})
+	resp, err := rt.RoundTrip(req)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.NotEqual(t, protocol.StatusAuthOK, resp.StatusCode)
+	assert.Equal(t, int32(0), auth.Count.Load())
+}
@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"io"
+
+	"github.com/apernet/hysteria/core/v2/errors"
+
+	"github.com/apernet/quic-go/quicvarint"
+)
+
+// Control message types
+const (
+	ControlTypeNotice       = 0 // Text only
+	ControlTypeQuotaWarning = 1 // Value = bytes left
+	ControlTypeBandwidth    = 2 // Value = sender's new max receive rate in bytes per second
+	ControlTypeMaintenance  = 3 // Value = seconds until the server goes down
+	ControlTypeKick         = 4 // Sender is about to close the connection, Text = reason
+)
+
+// ControlOpen format:
+// 0x402 (QUIC varint)
+// Padding length (QUIC varint)
+// Padding (bytes)
+
+// ReadControlOpen reads the rest of a ControlOpen after its frame type.
+func ReadControlOpen(r io.Reader) error {
+	return discardPadding(r, quicvarint.NewReader(r))
+}
+
+func WriteControlOpen(w io.Writer) error {
+	padding := controlOpenPadding.String()
+	paddingLen := len(padding)
+	sz := int(quicvarint.Len(FrameTypeControl)) +
+		int(quicvarint.Len(uint64(paddingLen))) + paddingLen
+	buf := make([]byte, sz)
+	i := varintPut(buf, FrameTypeControl)
+	i += varintPut(buf[i:], uint64(paddingLen))
+	copy(buf[i:], padding)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ControlMessage is sent by either side on the control stream after ControlOpen.
+// Receivers must ignore types they don't know.
+type ControlMessage struct {
+	Type  uint64
+	Value uint64
+	Text  string
+}
+
+// ControlMessage format:
+// Type (QUIC varint)
+// Value (QUIC varint)
+// Text length (QUIC varint)
+// Text (bytes)
+// Padding length (QUIC varint)
+// Padding (bytes)
+
+func ReadControlMessage(r io.Reader) (ControlMessage, error) {
+	bReader := quicvarint.NewReader(r)
+	msgType, err := quicvarint.Read(bReader)
+	if err != nil {
+		return ControlMessage{}, err
+	}
+	value, err := quicvarint.Read(bReader)
+	if err != nil {
+		return ControlMessage{}, err
+	}
+	textLen, err := quicvarint.Read(bReader)
+	if err != nil {
+		return ControlMessage{}, err
+	}
+	if textLen > MaxMessageLength {
+		return ControlMessage{}, errors.ProtocolError{Message: "invalid message length"}
+	}
+	var textBuf []byte
+	if textLen > 0 {
+		textBuf = make([]byte, textLen)
+		_, err = io.ReadFull(r, textBuf)
+		if err != nil {
+			return ControlMessage{}, err
+		}
+	}
+	if err := discardPadding(r, bReader); err != nil {
+		return ControlMessage{}, err
+	}
+	return ControlMessage{Type: msgType, Value: value, Text: string(textBuf)}, nil
+}
+
+func WriteControlMessage(w io.Writer, msg ControlMessage) error {
+	if len(msg.Text) > MaxMessageLength {
+		return errors.ProtocolError{Message: "message too long"}
+	}
+	padding := controlMsgPadding.String()
+	paddingLen := len(padding)
+	textLen := len(msg.Text)
+	sz := int(quicvarint.Len(msg.Type)) + int(quicvarint.Len(msg.Value)) +
+		int(quicvarint.Len(uint64(textLen))) + textLen +
+		int(quicvarint.Len(uint64(paddingLen))) + paddingLen
+	buf := make([]byte, sz)
+	i := varintPut(buf, msg.Type)
+	i += varintPut(buf[i:], msg.Value)
+	i += varintPut(buf[i:], uint64(textLen))
+	i += copy(buf[i:], msg.Text)
+	i += varintPut(buf[i:], uint64(paddingLen))
+	copy(buf[i:], padding)
+	_, err := w.Write(buf)
+	return err
+}
+
+func discardPadding(r io.Reader, bReader quicvarint.Reader) error {
+	paddingLen, err := quicvarint.Read(bReader)
+	if err != nil {
+		return err
+	}
+	if paddingLen > MaxPaddingLength {
+		return errors.ProtocolError{Message: "invalid padding length"}
+	}
+	if paddingLen > 0 {
+		_, err = io.CopyN(io.Discard, r, int64(paddingLen))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadControlMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    ControlMessage
+		wantErr bool
+	}{
+		{
+			name: "normal no padding",
+			data: []byte("\x03\x0a\x05hello\x00"),
+			want: ControlMessage{Type: ControlTypeMaintenance, Value: 10, Text: "hello"},
+		},
+		{
+			name: "normal with padding",
+			data: []byte("\x01\x44\x00\x00\x02gg"),
+			want: ControlMessage{Type: ControlTypeQuotaWarning, Value: 1024},
+		},
+		{
+			name: "unknown type",
+			data: []byte("\x3f\x00\x00\x00"),
+			want: ControlMessage{Type: 63},
+		},
+		{
+			name:    "incomplete 1",
+			data:    []byte("\x04\x00\x0bhoho"),
+			wantErr: true,
+		},
+		{
+			name:    "incomplete 2",
+			data:    []byte("\x04\x00\x02gg\x05x"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.data)
+			got, err := ReadControlMessage(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadControlMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadControlMessage() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteControlMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     ControlMessage
+		wantW   string // Just a prefix, we don't care about the padding
+		wantErr bool
+	}{
+		{
+			name:  "kick",
+			msg:   ControlMessage{Type: ControlTypeKick, Text: "bye"},
+			wantW: "\x04\x00\x03bye",
+		},
+		{
+			name:  "bandwidth",
+			msg:   ControlMessage{Type: ControlTypeBandwidth, Value: 12500000},
+			wantW: "\x02\x80\xbe\xbc\x20\x00",
+		},
+		{
+			name:    "text too long",
+			msg:     ControlMessage{Type: ControlTypeNotice, Text: strings.Repeat("x", MaxMessageLength+1)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := WriteControlMessage(w, tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteControlMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			gotW := w.String()
+			if !(strings.HasPrefix(gotW, tt.wantW) && len(gotW) > len(tt.wantW)) {
+				t.Errorf("WriteControlMessage() gotW = %v, want %v", gotW, tt.wantW)
+			}
+			// Read it back
+			got, err := ReadControlMessage(strings.NewReader(gotW))
+			if err != nil || !reflect.DeepEqual(got, tt.msg) {
+				t.Errorf("ReadControlMessage() got = %v, %v, want %v", got, err, tt.msg)
+			}
+		})
+	}
+}
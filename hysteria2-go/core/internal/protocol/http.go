@@ -11,6 +11,8 @@ const (
 
 	RequestHeaderAuth        = "Hysteria-Auth"
 	ResponseHeaderUDPEnabled = "Hysteria-UDP"
+	ResponseHeader0RTT       = "Hysteria-0RTT"
+	ResponseHeaderControl    = "Hysteria-Control"
 	CommonHeaderCCRX         = "Hysteria-CC-RX"
 	CommonHeaderPadding      = "Hysteria-Padding"
 
@@ -28,6 +30,8 @@ type AuthResponse struct {
 	UDPEnabled bool
 	Rx         uint64 // 0 = unlimited
 	RxAuto     bool   // true = server asks client to use bandwidth detection
+	ZeroRTT    bool   // true = server accepts the auth request in 0-RTT on resumption
+	Control    bool   // true = server supports the control stream
 }
 
 func AuthRequestFromHeader(h http.Header) AuthRequest {
@@ -47,6 +51,8 @@ func AuthRequestToHeader(h http.Header, req AuthRequest) {
 func AuthResponseFromHeader(h http.Header) AuthResponse {
 	resp := AuthResponse{}
 	resp.UDPEnabled, _ = strconv.ParseBool(h.Get(ResponseHeaderUDPEnabled))
+	resp.ZeroRTT, _ = strconv.ParseBool(h.Get(ResponseHeader0RTT))
+	resp.Control, _ = strconv.ParseBool(h.Get(ResponseHeaderControl))
 	rxStr := h.Get(CommonHeaderCCRX)
 	if rxStr == "auto" {
 		// Special case for server requesting client to use bandwidth detection
@@ -64,5 +70,11 @@ func AuthResponseToHeader(h http.Header, resp AuthResponse) {
 	} else {
 		h.Set(CommonHeaderCCRX, strconv.FormatUint(resp.Rx, 10))
 	}
+	if resp.ZeroRTT {
+		h.Set(ResponseHeader0RTT, "true")
+	}
+	if resp.Control {
+		h.Set(ResponseHeaderControl, "true")
+	}
 	h.Set(CommonHeaderPadding, authResponsePadding.String())
 }
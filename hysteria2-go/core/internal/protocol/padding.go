@@ -28,4 +28,6 @@ var (
 	authResponsePadding = padding{Min: 256, Max: 2048}
 	tcpRequestPadding   = padding{Min: 64, Max: 512}
 	tcpResponsePadding  = padding{Min: 128, Max: 1024}
+	controlOpenPadding  = padding{Min: 64, Max: 512}
+	controlMsgPadding   = padding{Min: 16, Max: 256}
 )
@@ -13,6 +13,7 @@ import (
 
 const (
 	FrameTypeTCPRequest = 0x401
+	FrameTypeControl    = 0x402
 
 	// Max length values are for preventing DoS attacks
 
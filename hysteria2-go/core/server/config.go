@@ -32,8 +32,11 @@ type Config struct {
 	Cleanup               io.Closer
 	RequestHook           RequestHook
 	Outbound              Outbound
+	Outbounds             map[string]Outbound // named outbounds that a Policy can pick
 	CongestionConfig      CongestionConfig
 	BandwidthConfig       BandwidthConfig
+	RateLimitConfig       RateLimitConfig
+	ConnLimitConfig       ConnLimitConfig
 	IgnoreClientBandwidth bool
 	DisableUDP            bool
 	UDPIdleTimeout        time.Duration
@@ -94,26 +97,63 @@ func (c *Config) fill() error {
 	if c.Conn == nil {
 		return errors.ConfigError{Field: "Conn", Reason: "must be set"}
 	}
+	if c.UDPIdleTimeout == 0 {
+		c.UDPIdleTimeout = defaultUDPIdleTimeout
+	} else if c.UDPIdleTimeout < 2*time.Second || c.UDPIdleTimeout > 600*time.Second {
+		return errors.ConfigError{Field: "UDPIdleTimeout", Reason: "must be between 2s and 600s"}
+	}
+	if c.ConnLimitConfig.MaxPerUser < 0 || c.ConnLimitConfig.MaxPerIP < 0 {
+		return errors.ConfigError{Field: "ConnLimitConfig", Reason: "limits must not be negative"}
+	}
+	if c.ConnLimitConfig.IPv4Prefix == 0 {
+		c.ConnLimitConfig.IPv4Prefix = defaultConnLimitIPv4Prefix
+	} else if c.ConnLimitConfig.IPv4Prefix < 0 || c.ConnLimitConfig.IPv4Prefix > 32 {
+		return errors.ConfigError{Field: "ConnLimitConfig.IPv4Prefix", Reason: "must be between 0 and 32"}
+	}
+	if c.ConnLimitConfig.IPv6Prefix == 0 {
+		c.ConnLimitConfig.IPv6Prefix = defaultConnLimitIPv6Prefix
+	} else if c.ConnLimitConfig.IPv6Prefix < 0 || c.ConnLimitConfig.IPv6Prefix > 128 {
+		return errors.ConfigError{Field: "ConnLimitConfig.IPv6Prefix", Reason: "must be between 0 and 128"}
+	}
+	return c.fillReloadable()
+}
+
+// fillReloadable is the part of fill that covers the fields in ReloadConfig.
+func (c *Config) fillReloadable() error {
 	if c.Outbound == nil {
 		c.Outbound = &defaultOutbound{}
 	}
+	for name, ob := range c.Outbounds {
+		if ob == nil {
+			return errors.ConfigError{Field: "Outbounds", Reason: "outbound " + name + " is nil"}
+		}
+	}
 	if c.BandwidthConfig.MaxTx != 0 && c.BandwidthConfig.MaxTx < 65536 {
 		return errors.ConfigError{Field: "BandwidthConfig.MaxTx", Reason: "must be at least 65536"}
 	}
 	if c.BandwidthConfig.MaxRx != 0 && c.BandwidthConfig.MaxRx < 65536 {
 		return errors.ConfigError{Field: "BandwidthConfig.MaxRx", Reason: "must be at least 65536"}
 	}
-	if c.UDPIdleTimeout == 0 {
-		c.UDPIdleTimeout = defaultUDPIdleTimeout
-	} else if c.UDPIdleTimeout < 2*time.Second || c.UDPIdleTimeout > 600*time.Second {
-		return errors.ConfigError{Field: "UDPIdleTimeout", Reason: "must be between 2s and 600s"}
-	}
 	if c.Authenticator == nil {
 		return errors.ConfigError{Field: "Authenticator", Reason: "must be set"}
 	}
 	return nil
 }
 
+// ReloadConfig contains the fields of Config that can be replaced on a running server
+// with Server.Reload. Established connections keep running: the Outbound applies
+// to new TCP requests and UDP sessions, the MasqHandler to new HTTP requests, and
+// the Authenticator and bandwidth settings to new authentications.
+// Outbounds also applies to established connections whose Policy names one.
+type ReloadConfig struct {
+	Authenticator         Authenticator
+	Outbound              Outbound
+	Outbounds             map[string]Outbound
+	MasqHandler           http.Handler
+	BandwidthConfig       BandwidthConfig
+	IgnoreClientBandwidth bool
+}
+
 // TLSConfig contains the TLS configuration fields that we want to expose to the user.
 type TLSConfig struct {
 	Certificates   []tls.Certificate
@@ -133,6 +173,13 @@ type QUICConfig struct {
 	MaxIncomingStreams             int64
 	DisablePathMTUDiscovery        bool // The server may still override this to true on unsupported platforms.
 	DisableGSO                     bool
+	// Enable0RTT lets clients send the auth request in 0-RTT when resuming a session.
+	// Nothing else is accepted in 0-RTT, and the auth request itself is only processed
+	// once the handshake completes, as 0-RTT data can be replayed.
+	Enable0RTT bool
+	// EnableMigration lets clients move their connections to a new address,
+	// e.g. when they switch networks, keeping their streams and UDP sessions.
+	EnableMigration bool
 }
 
 type CongestionConfig struct {
@@ -221,11 +268,69 @@ type BandwidthConfig struct {
 	DisableLossCompensation bool
 }
 
+// RateLimitConfig limits the throughput of each user (auth ID) across all of their
+// connections, in bytes per second. 0 means unlimited. Unlike BandwidthConfig,
+// it is enforced on the data itself, whatever congestion control the client uses.
+type RateLimitConfig struct {
+	MaxTx uint64 // from the server to the client
+	MaxRx uint64 // from the client to the server
+}
+
+// ConnLimitConfig limits the concurrent authenticated connections of each user (auth ID)
+// and of each client IP, or each prefix of that length. 0 means unlimited.
+// A new connection over a limit fails to authenticate, or with EvictOldest,
+// the oldest connections over the limit are closed instead.
+type ConnLimitConfig struct {
+	MaxPerUser  int
+	MaxPerIP    int
+	IPv4Prefix  int // 32 if 0
+	IPv6Prefix  int // 64 if 0
+	EvictOldest bool
+}
+
+// Limits of ConnLimitConfig, as reported to ConnLimitLogger.
+const (
+	ConnLimitUser = "user"
+	ConnLimitIP   = "ip"
+)
+
+// ConnLimitLogger can optionally be implemented by an EventLogger
+// to be told about the decisions made for ConnLimitConfig.
+type ConnLimitLogger interface {
+	// ConnRejected is called when a new connection from addr is refused because of limit.
+	ConnRejected(addr net.Addr, id string, limit string)
+	// ConnEvicted is called when the connection from addr is closed because of limit,
+	// to make room for a new one from newAddr.
+	ConnEvicted(addr net.Addr, id string, limit string, newAddr net.Addr)
+}
+
 // Authenticator is an interface that provides authentication logic.
 type Authenticator interface {
 	Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string)
 }
 
+// PolicyAuthenticator can optionally be implemented by an Authenticator
+// to also return a Policy for the user. The server then calls AuthenticatePolicy
+// instead of Authenticate. A nil policy means the server config applies as is.
+type PolicyAuthenticator interface {
+	Authenticator
+	AuthenticatePolicy(addr net.Addr, auth string, tx uint64) (ok bool, id string, policy *Policy)
+}
+
+// Policy overrides parts of the server config for a single user.
+// The zero value of each field keeps the server config. Limits can only be
+// made stricter than the server config, not looser.
+type Policy struct {
+	MaxTx      uint64          // bytes per second, the most the server sends to the user
+	MaxRx      uint64          // bytes per second, the most the user is asked to send
+	RateLimit  RateLimitConfig // enforced limits, see Config.RateLimitConfig
+	DisableUDP bool            // disallow UDP relay for the user
+	MaxConns   int             // concurrent connections of the user, see ConnLimitConfig.MaxPerUser
+	MaxStreams int             // concurrent TCP streams on each connection of the user
+	Outbound   string          // name in Config.Outbounds to use instead of Config.Outbound
+	ExpireAt   time.Time       // the connection is closed at this time
+}
+
 // EventLogger is an interface that provides logging logic.
 type EventLogger interface {
 	Connect(addr net.Addr, id string, tx uint64)
@@ -261,6 +366,13 @@ type TrafficLogger interface {
 	UntraceStream(stream HyStream)
 }
 
+// UserChecker can optionally be implemented by a TrafficLogger to refuse users
+// who passed the Authenticator, for example because they are over their quota.
+// Such users are treated as if the authentication failed.
+type UserChecker interface {
+	CheckUser(id string) (ok bool)
+}
+
 type StreamState int
 
 const (
@@ -315,6 +427,63 @@ type StreamStats struct {
 	LastActiveTime utils.Atomic[time.Time]
 }
 
+// ConnTracer can optionally be implemented by a TrafficLogger
+// to also keep track of the connections of authenticated clients.
+type ConnTracer interface {
+	TraceConn(stats *ConnStats)
+	UntraceConn(stats *ConnStats)
+}
+
+type ConnStats struct {
+	AuthID      string
+	ConnID      uint32
+	RemoteAddr  net.Addr
+	InitialTime time.Time
+
+	pathStats    func() PathStats
+	sendMessage  func(msg ControlMessage) error
+	setBandwidth func(tx, rx uint64) error
+}
+
+// PathStats returns the current path stats of the connection.
+func (s *ConnStats) PathStats() PathStats {
+	return s.pathStats()
+}
+
+// SendMessage pushes msg to the client over its control stream. It fails if the client
+// doesn't support control messages. After ControlKick, the connection is closed.
+func (s *ConnStats) SendMessage(msg ControlMessage) error {
+	return s.sendMessage(msg)
+}
+
+// SetBandwidth changes the bandwidth of the connection while it is open, in bytes per second.
+// tx is the most the server sends to the client, on top of the config's limit and what
+// the client can receive. rx is the most the client is asked to send, which it applies
+// through a ControlBandwidth message. 0 sets either back to the config. Changing rx fails
+// if the client doesn't support control messages, in which case tx is still applied.
+// Like the negotiated bandwidth, both only apply to Brutal, they are not enforced.
+func (s *ConnStats) SetBandwidth(tx, rx uint64) error {
+	return s.setBandwidth(tx, rx)
+}
+
+// PathStats is a snapshot of the path quality of a QUIC connection
+// and the state of its congestion controller.
+type PathStats struct {
+	SmoothedRTT      time.Duration
+	MinRTT           time.Duration
+	CongestionWindow uint64 // bytes, 0 for Reno
+	BytesInFlight    uint64 // 0 for Reno
+	PacketsSent      uint64
+	PacketsLost      uint64
+	BytesLost        uint64
+	MTU              uint64 // current max datagram size, 0 for Reno
+	Congestion       string // "brutal", "bbr" or "reno"
+	BrutalAckRate    float64
+	BrutalBandwidth  uint64 // bytes per second, the current target
+	BBRMode          string // "startup", "drain", "probeBW" or "probeRTT"
+	BBRBandwidth     uint64 // BBR bandwidth estimate in bytes per second
+}
+
 func (s *StreamStats) setHookedReqAddr(addr string) {
 	if addr != s.ReqAddr.Load() {
 		s.HookedReqAddr.Store(addr)
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+const (
+	defaultConnLimitIPv4Prefix = 32
+	defaultConnLimitIPv6Prefix = 64
+
+	connEvictedText = "replaced by a newer connection"
+)
+
+// limitedConn is a connection counted by connLimiter.
+type limitedConn struct {
+	h      *h3sHandler
+	id     string
+	prefix netip.Prefix // invalid if the address is not an IP
+}
+
+// connEviction is a connection removed by connLimiter to make room for a new one,
+// and the limit it was removed for.
+type connEviction struct {
+	conn  *limitedConn
+	limit string
+}
+
+// connLimiter keeps the authenticated connections of each user and client IP prefix,
+// in the order they were authenticated.
+type connLimiter struct {
+	mutex sync.Mutex
+	users map[string][]*limitedConn
+	ips   map[netip.Prefix][]*limitedConn
+}
+
+// Acquire adds a new connection if the limits (0 = unlimited) allow it. If not, it returns
+// the limit that refused it, or with evict, removes the oldest connections over the
+// limits instead and returns them for the caller to close.
+func (l *connLimiter) Acquire(c *limitedConn, maxUser, maxIP int, evict bool) (evicted []connEviction, limit string, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.users == nil {
+		l.users = make(map[string][]*limitedConn)
+		l.ips = make(map[netip.Prefix][]*limitedConn)
+	}
+	if !evict {
+		if maxUser > 0 && len(l.users[c.id]) >= maxUser {
+			return nil, ConnLimitUser, false
+		}
+		if maxIP > 0 && c.prefix.IsValid() && len(l.ips[c.prefix]) >= maxIP {
+			return nil, ConnLimitIP, false
+		}
+	} else {
+		for maxUser > 0 && len(l.users[c.id]) >= maxUser {
+			old := l.users[c.id][0]
+			l.removeLocked(old)
+			evicted = append(evicted, connEviction{old, ConnLimitUser})
+		}
+		for maxIP > 0 && c.prefix.IsValid() && len(l.ips[c.prefix]) >= maxIP {
+			old := l.ips[c.prefix][0]
+			l.removeLocked(old)
+			evicted = append(evicted, connEviction{old, ConnLimitIP})
+		}
+	}
+	l.users[c.id] = append(l.users[c.id], c)
+	if c.prefix.IsValid() {
+		l.ips[c.prefix] = append(l.ips[c.prefix], c)
+	}
+	return evicted, "", true
+}
+
+// Release removes a connection, if it has not been evicted already.
+func (l *connLimiter) Release(c *limitedConn) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.removeLocked(c)
+}
+
+func (l *connLimiter) removeLocked(c *limitedConn) {
+	isC := func(x *limitedConn) bool { return x == c }
+	if conns := slices.DeleteFunc(l.users[c.id], isC); len(conns) > 0 {
+		l.users[c.id] = conns
+	} else {
+		delete(l.users, c.id)
+	}
+	if c.prefix.IsValid() {
+		if conns := slices.DeleteFunc(l.ips[c.prefix], isC); len(conns) > 0 {
+			l.ips[c.prefix] = conns
+		} else {
+			delete(l.ips, c.prefix)
+		}
+	}
+}
+
+// connPrefix returns the prefix of addr that connections are limited by,
+// or an invalid prefix if addr is not an IP address.
+func connPrefix(addr net.Addr, config ConnLimitConfig) netip.Prefix {
+	var ip netip.Addr
+	if uAddr, ok := addr.(*net.UDPAddr); ok {
+		ip, _ = netip.AddrFromSlice(uAddr.IP)
+	} else if ap, err := netip.ParseAddrPort(addr.String()); err == nil {
+		ip = ap.Addr()
+	}
+	if !ip.IsValid() {
+		return netip.Prefix{}
+	}
+	ip = ip.Unmap()
+	bits := config.IPv6Prefix
+	if ip.Is4() {
+		bits = config.IPv4Prefix
+	}
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}
+	}
+	return prefix
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnLimiterReject(t *testing.T) {
+	var l connLimiter
+	prefix := netip.MustParsePrefix("1.2.3.4/32")
+	c1 := &limitedConn{id: "alice", prefix: prefix}
+	c2 := &limitedConn{id: "alice", prefix: prefix}
+	c3 := &limitedConn{id: "bob", prefix: prefix}
+
+	_, _, ok := l.Acquire(c1, 1, 2, false)
+	assert.True(t, ok)
+	_, limit, ok := l.Acquire(c2, 1, 2, false)
+	assert.False(t, ok)
+	assert.Equal(t, ConnLimitUser, limit)
+	_, _, ok = l.Acquire(c3, 1, 2, false)
+	assert.True(t, ok)
+
+	// Same IP, another user
+	_, limit, ok = l.Acquire(&limitedConn{id: "carol", prefix: prefix}, 1, 2, false)
+	assert.False(t, ok)
+	assert.Equal(t, ConnLimitIP, limit)
+
+	// Room again after a release
+	l.Release(c1)
+	_, _, ok = l.Acquire(c2, 1, 2, false)
+	assert.True(t, ok)
+	l.Release(c2)
+	l.Release(c3)
+	assert.Empty(t, l.users)
+	assert.Empty(t, l.ips)
+}
+
+func TestConnLimiterEvict(t *testing.T) {
+	var l connLimiter
+	prefix := netip.MustParsePrefix("1.2.3.4/32")
+	c1 := &limitedConn{id: "alice", prefix: prefix}
+	c2 := &limitedConn{id: "bob", prefix: prefix}
+	c3 := &limitedConn{id: "alice", prefix: prefix}
+
+	evicted, _, ok := l.Acquire(c1, 1, 2, true)
+	assert.True(t, ok)
+	assert.Empty(t, evicted)
+	evicted, _, ok = l.Acquire(c2, 1, 2, true)
+	assert.True(t, ok)
+	assert.Empty(t, evicted)
+
+	// The oldest connection of the user goes first, and then the IP is not over the limit anymore
+	evicted, _, ok = l.Acquire(c3, 1, 2, true)
+	assert.True(t, ok)
+	assert.Equal(t, []connEviction{{c1, ConnLimitUser}}, evicted)
+
+	// The oldest connection of the IP
+	c4 := &limitedConn{id: "carol", prefix: prefix}
+	evicted, _, ok = l.Acquire(c4, 1, 2, true)
+	assert.True(t, ok)
+	assert.Equal(t, []connEviction{{c2, ConnLimitIP}}, evicted)
+
+	// Releasing evicted connections does nothing
+	l.Release(c1)
+	l.Release(c2)
+	assert.Equal(t, []*limitedConn{c3, c4}, l.ips[prefix])
+}
+
+func TestConnPrefix(t *testing.T) {
+	config := ConnLimitConfig{IPv4Prefix: 24, IPv6Prefix: 64}
+	assert.Equal(t, netip.MustParsePrefix("1.2.3.0/24"),
+		connPrefix(&net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1234}, config))
+	assert.Equal(t, netip.MustParsePrefix("2001:db8:1:2::/64"),
+		connPrefix(&net.UDPAddr{IP: net.ParseIP("2001:db8:1:2:3:4:5:6"), Port: 1234}, config))
+	assert.Equal(t, netip.MustParsePrefix("1.2.3.0/24"),
+		connPrefix(&net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 1234}, config))
+	assert.False(t, connPrefix(&net.UnixAddr{Name: "/tmp/x", Net: "unixgram"}, config).IsValid())
+}
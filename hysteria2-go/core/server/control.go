@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/internal/protocol"
+	"github.com/apernet/hysteria/core/v2/internal/utils"
+)
+
+// kickDelay is how long the server waits after sending ControlKick before it closes
+// the connection, so that the message has a chance to reach the client.
+const kickDelay = time.Second
+
+var errControlUnsupported = errors.New("client does not support control messages")
+
+// ControlType is the kind of a ControlMessage.
+type ControlType uint64
+
+const (
+	ControlNotice       ControlType = protocol.ControlTypeNotice       // Text only
+	ControlQuotaWarning ControlType = protocol.ControlTypeQuotaWarning // Value is the number of bytes left
+	ControlBandwidth    ControlType = protocol.ControlTypeBandwidth    // Value is the new max receive rate in bytes per second
+	ControlMaintenance  ControlType = protocol.ControlTypeMaintenance  // Value is the number of seconds until the server goes down
+	ControlKick         ControlType = protocol.ControlTypeKick         // The connection is closed right after, Text is the reason
+)
+
+func (t ControlType) String() string {
+	switch t {
+	case ControlNotice:
+		return "notice"
+	case ControlQuotaWarning:
+		return "quotaWarning"
+	case ControlBandwidth:
+		return "bandwidth"
+	case ControlMaintenance:
+		return "maintenance"
+	case ControlKick:
+		return "kick"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseControlType is the reverse of ControlType.String.
+func ParseControlType(s string) (ControlType, bool) {
+	for t := ControlNotice; t <= ControlKick; t++ {
+		if t.String() == s {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// ControlMessage is a message pushed to a client over its control stream.
+type ControlMessage struct {
+	Type  ControlType
+	Value uint64
+	Text  string
+}
+
+// controlStream is the stream a client opens after authentication
+// for the server to push messages to it.
+type controlStream struct {
+	mutex  sync.Mutex // serializes the messages
+	stream *utils.QStream
+}
+
+func (c *controlStream) Send(msg ControlMessage) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return protocol.WriteControlMessage(c.stream, protocol.ControlMessage{
+		Type:  uint64(msg.Type),
+		Value: msg.Value,
+		Text:  msg.Text,
+	})
+}
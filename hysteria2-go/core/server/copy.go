@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"io"
 	"sync"
@@ -43,21 +44,39 @@ func copyBufferLog(dst io.Writer, src io.Reader, log func(n uint64) bool) error
 	}
 }
 
-func copyTwoWayEx(id string, serverRw, remoteRw io.ReadWriter, l TrafficLogger, stats *StreamStats) error {
+// copyTwoWayEx copies like copyTwoWay, while updating stats, logging the traffic to l
+// and waiting for the rate limiter of the user until ctx is done. l may be nil.
+func copyTwoWayEx(ctx context.Context, id string, serverRw, remoteRw io.ReadWriter, l TrafficLogger, limiter *userRateLimiter, stats *StreamStats) error {
 	errChan := make(chan error, 2)
 	go func() {
-		errChan <- copyBufferLog(serverRw, remoteRw, func(n uint64) bool {
+		var waitErr error
+		err := copyBufferLog(serverRw, remoteRw, func(n uint64) bool {
+			if waitErr = limiter.WaitTx(ctx, n); waitErr != nil {
+				return false
+			}
 			stats.LastActiveTime.Store(time.Now())
 			stats.Rx.Add(n)
-			return l.LogTraffic(id, 0, n)
+			return l == nil || l.LogTraffic(id, 0, n)
 		})
+		if waitErr != nil {
+			err = waitErr
+		}
+		errChan <- err
 	}()
 	go func() {
-		errChan <- copyBufferLog(remoteRw, serverRw, func(n uint64) bool {
+		var waitErr error
+		err := copyBufferLog(remoteRw, serverRw, func(n uint64) bool {
+			if waitErr = limiter.WaitRx(ctx, n); waitErr != nil {
+				return false
+			}
 			stats.LastActiveTime.Store(time.Now())
 			stats.Tx.Add(n)
-			return l.LogTraffic(id, n, 0)
+			return l == nil || l.LogTraffic(id, n, 0)
 		})
+		if waitErr != nil {
+			err = waitErr
+		}
+		errChan <- err
 	}()
 	// Block until one of the two goroutines returns
 	return <-errChan
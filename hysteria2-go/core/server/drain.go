@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"sync"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// drainTracker counts the TCP streams and UDP sessions in flight,
+// so that Shutdown can wait for them to finish.
+type drainTracker struct {
+	mutex    sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{} // closed once draining and no longer active
+}
+
+// Acquire registers a new stream or session. It returns false
+// if the server is draining, in which case it must be refused.
+func (t *drainTracker) Acquire() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.draining {
+		return false
+	}
+	t.active++
+	return true
+}
+
+// Release must be called exactly once for every successful Acquire.
+func (t *drainTracker) Release() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.active--
+	if t.draining && t.active == 0 {
+		close(t.idle)
+	}
+}
+
+// Drain refuses all further Acquire calls and returns a channel
+// that is closed once everything acquired so far has been released.
+func (t *drainTracker) Drain() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.draining {
+		t.draining = true
+		t.idle = make(chan struct{})
+		if t.active == 0 {
+			close(t.idle)
+		}
+	}
+	return t.idle
+}
+
+// drainUDPConn releases its slot in the tracker when closed.
+type drainUDPConn struct {
+	UDPConn
+	once    sync.Once
+	tracker *drainTracker
+}
+
+func (c *drainUDPConn) Close() error {
+	c.once.Do(c.tracker.Release)
+	return c.UDPConn.Close()
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	errTooManyStreams  = errors.New("too many streams")
+	errUnknownOutbound = errors.New("unknown outbound")
+)
+
+const policyExpiredText = "session expired"
+
+// authenticate calls AuthenticatePolicy if the authenticator supports it,
+// and Authenticate otherwise.
+func authenticate(a Authenticator, addr net.Addr, auth string, tx uint64) (ok bool, id string, policy *Policy) {
+	if pa, ok := a.(PolicyAuthenticator); ok {
+		return pa.AuthenticatePolicy(addr, auth, tx)
+	}
+	ok, id = a.Authenticate(addr, auth, tx)
+	return ok, id, nil
+}
+
+// policyOutbound returns the outbound named by a Policy, or the default one if name is empty.
+// A name that is not (or no longer, after a reload) in the config is an error
+// rather than a fallback, so that a restricted user never gets the default outbound.
+func policyOutbound(config *Config, name string) (Outbound, error) {
+	if name == "" {
+		return config.Outbound, nil
+	}
+	ob, ok := config.Outbounds[name]
+	if !ok {
+		return nil, errUnknownOutbound
+	}
+	return ob, nil
+}
+
+// minLimit returns the stricter of two limits where 0 means unlimited.
+func minLimit(a, b uint64) uint64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
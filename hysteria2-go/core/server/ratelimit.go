@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const minRateLimitBurst = 65536
+
+// userRateLimiter is the token bucket of a user, shared by all their connections.
+// tx is for the data the server sends to the client, rx for what it receives.
+type userRateLimiter struct {
+	refs int
+	tx   *rate.Limiter
+	rx   *rate.Limiter
+}
+
+// Limited returns whether any of the limits is set,
+// so that the fast paths that don't count bytes can be used otherwise.
+func (l *userRateLimiter) Limited() bool {
+	return l.tx.Limit() != rate.Inf || l.rx.Limit() != rate.Inf
+}
+
+// WaitTx and WaitRx wait until n bytes can pass, or until ctx is done.
+// They are for streams, whose data can't be dropped.
+func (l *userRateLimiter) WaitTx(ctx context.Context, n uint64) error {
+	return waitRateLimiter(ctx, l.tx, n)
+}
+
+func (l *userRateLimiter) WaitRx(ctx context.Context, n uint64) error {
+	return waitRateLimiter(ctx, l.rx, n)
+}
+
+// AllowTx and AllowRx report whether n bytes can pass right now, taking them if so.
+// They are for datagrams, which are dropped over the limit instead of holding up
+// the single loop that receives (or the session that sends) them.
+func (l *userRateLimiter) AllowTx(n uint64) bool {
+	return allowRateLimiter(l.tx, n)
+}
+
+func (l *userRateLimiter) AllowRx(n uint64) bool {
+	return allowRateLimiter(l.rx, n)
+}
+
+// waitRateLimiter waits until n bytes can pass, in pieces no larger than the burst.
+// It only fails if ctx is done first.
+func waitRateLimiter(ctx context.Context, l *rate.Limiter, n uint64) error {
+	if l.Limit() == rate.Inf {
+		return nil
+	}
+	for n > 0 {
+		chunk := n
+		if burst := uint64(l.Burst()); chunk > burst {
+			chunk = burst
+		}
+		if err := l.WaitN(ctx, int(chunk)); err != nil {
+			if ctx.Err() != nil || chunk <= uint64(l.Burst()) {
+				// Done, or the wait would outlast its deadline
+				return err
+			}
+			// The burst shrank in between, go on with the new one
+			continue
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+func allowRateLimiter(l *rate.Limiter, n uint64) bool {
+	if l.Limit() == rate.Inf {
+		return true
+	}
+	return n <= uint64(l.Burst()) && l.AllowN(time.Now(), int(n))
+}
+
+// setRateLimit sets the limit to bps bytes per second, 0 for unlimited.
+// The burst is one second worth of traffic.
+func setRateLimit(l *rate.Limiter, bps uint64) {
+	if bps == 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	burst := bps
+	if burst < minRateLimitBurst {
+		burst = minRateLimitBurst
+	}
+	l.SetBurst(int(burst))
+	l.SetLimit(rate.Limit(bps))
+}
+
+// rateLimiterMap keeps the userRateLimiter of each user with open connections.
+type rateLimiterMap struct {
+	mutex sync.Mutex
+	users map[string]*userRateLimiter
+}
+
+// Acquire returns the limiter of id for a new connection. The limits of the latest
+// connection apply to all connections of the user.
+func (m *rateLimiterMap) Acquire(id string, config RateLimitConfig) *userRateLimiter {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	l := m.users[id]
+	if l == nil {
+		l = &userRateLimiter{
+			tx: rate.NewLimiter(rate.Inf, 0),
+			rx: rate.NewLimiter(rate.Inf, 0),
+		}
+		if m.users == nil {
+			m.users = make(map[string]*userRateLimiter)
+		}
+		m.users[id] = l
+	}
+	l.refs++
+	setRateLimit(l.tx, config.MaxTx)
+	setRateLimit(l.rx, config.MaxRx)
+	return l
+}
+
+func (m *rateLimiterMap) Release(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	l := m.users[id]
+	if l == nil {
+		return
+	}
+	l.refs--
+	if l.refs <= 0 {
+		delete(m.users, id)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterMap(t *testing.T) {
+	var m rateLimiterMap
+
+	// Unlimited
+	l := m.Acquire("alice", RateLimitConfig{})
+	assert.False(t, l.Limited())
+
+	// Shared by the connections of a user, with the latest limits
+	l2 := m.Acquire("alice", RateLimitConfig{MaxTx: 1000000})
+	assert.Same(t, l, l2)
+	assert.True(t, l.Limited())
+	assert.Equal(t, rate.Limit(1000000), l.tx.Limit())
+	assert.Equal(t, 1000000, l.tx.Burst())
+	assert.Equal(t, rate.Inf, l.rx.Limit())
+
+	// Other users have their own
+	l3 := m.Acquire("bob", RateLimitConfig{MaxRx: 1000})
+	assert.NotSame(t, l, l3)
+	assert.Equal(t, minRateLimitBurst, l3.rx.Burst())
+
+	// Removed with the last connection
+	m.Release("alice")
+	assert.Len(t, m.users, 2)
+	m.Release("alice")
+	assert.Len(t, m.users, 1)
+	m.Release("bob")
+	assert.Len(t, m.users, 0)
+}
+
+func TestUserRateLimiterWait(t *testing.T) {
+	var m rateLimiterMap
+	l := m.Acquire("alice", RateLimitConfig{MaxRx: 200000})
+	defer m.Release("alice")
+
+	// The first second is the burst, the rest has to wait
+	start := time.Now()
+	assert.NoError(t, l.WaitRx(context.Background(), 300000))
+	elapsed := time.Since(start)
+	assert.Greater(t, elapsed, 400*time.Millisecond)
+	assert.Less(t, elapsed, 1*time.Second)
+
+	// Tx is not limited
+	start = time.Now()
+	assert.NoError(t, l.WaitTx(context.Background(), 1<<30))
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestUserRateLimiterWaitCancel(t *testing.T) {
+	var m rateLimiterMap
+	l := m.Acquire("alice", RateLimitConfig{MaxRx: 100000})
+	defer m.Release("alice")
+
+	// Gives up once ctx is done instead of waiting for the tokens
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	assert.ErrorIs(t, l.WaitRx(ctx, 1000000), context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestUserRateLimiterAllow(t *testing.T) {
+	var m rateLimiterMap
+	l := m.Acquire("alice", RateLimitConfig{MaxRx: 100000})
+	defer m.Release("alice")
+
+	// The burst passes, then datagrams are dropped instead of waiting
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.AllowRx(1000))
+	}
+	assert.False(t, l.AllowRx(10000))
+
+	// Tx is not limited
+	assert.True(t, l.AllowTx(1<<30))
+}
@@ -6,8 +6,10 @@ import (
 	"crypto/tls"
 	"errors"
 	"math/rand"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apernet/quic-go"
@@ -26,6 +28,13 @@ const (
 
 type Server interface {
 	Serve() error
+	// Reload replaces the reloadable parts of the config on the running server.
+	// Established connections are kept; see ReloadConfig for when each part takes effect.
+	Reload(config *ReloadConfig) error
+	// Shutdown stops accepting new connections and new TCP streams and UDP sessions,
+	// waits for the existing ones to finish until ctx is done, and then closes the server.
+	// It returns the error of ctx if it was done while streams or sessions were still in flight.
+	Shutdown(ctx context.Context) error
 	Close() error
 }
 
@@ -62,7 +71,8 @@ func NewServer(config *Config) (Server, error) {
 		EnableDatagrams:                true,
 		MaxDatagramFrameSize:           protocol.MaxDatagramFrameSize,
 		AssumePeerMaxDatagramFrameSize: protocol.MaxDatagramFrameSize,
-		DisablePathManager:             true,
+		DisablePathManager:             !config.QUICConfig.EnableMigration,
+		Allow0RTT:                      config.QUICConfig.Enable0RTT,
 	}
 	srk := config.StatelessResetKey
 	if srk == nil {
@@ -77,7 +87,7 @@ func NewServer(config *Config) (Server, error) {
 		DisableGSO:        config.QUICConfig.DisableGSO,
 		StatelessResetKey: srk,
 	}
-	listener, err := tr.Listen(tlsConfig, quicConfig)
+	listener, err := tr.ListenEarly(tlsConfig, quicConfig)
 	if err != nil {
 		err = errors.Join(err, tr.Close(), config.Conn.Close())
 		if config.Cleanup != nil {
@@ -85,17 +95,26 @@ func NewServer(config *Config) (Server, error) {
 		}
 		return nil, err
 	}
-	return &serverImpl{
-		config:   config,
+	s := &serverImpl{
 		tr:       tr,
 		listener: listener,
-	}, nil
+		conns:    make(map[*quic.Conn]struct{}),
+	}
+	s.config.Store(config)
+	return s, nil
 }
 
 type serverImpl struct {
-	config   *Config
-	tr       *quic.Transport
-	listener *quic.Listener
+	config      atomic.Pointer[Config] // replaced as a whole on reload
+	reloadMutex sync.Mutex
+	tr          *quic.Transport
+	listener    *quic.EarlyListener
+
+	drain        drainTracker
+	connLimiter  connLimiter
+	rateLimiters rateLimiterMap
+	connsMutex   sync.Mutex
+	conns        map[*quic.Conn]struct{}
 }
 
 func (s *serverImpl) Serve() error {
@@ -108,16 +127,65 @@ func (s *serverImpl) Serve() error {
 	}
 }
 
+func (s *serverImpl) Reload(rc *ReloadConfig) error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+	config := *s.config.Load()
+	config.Authenticator = rc.Authenticator
+	config.Outbound = rc.Outbound
+	config.Outbounds = rc.Outbounds
+	config.MasqHandler = rc.MasqHandler
+	config.BandwidthConfig = rc.BandwidthConfig
+	config.IgnoreClientBandwidth = rc.IgnoreClientBandwidth
+	if err := config.fillReloadable(); err != nil {
+		return err
+	}
+	s.config.Store(&config)
+	return nil
+}
+
+func (s *serverImpl) Shutdown(ctx context.Context) error {
+	_ = s.listener.Close()
+	idle := s.drain.Drain()
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		// Only an error if there is still something in flight,
+		// which isn't the case for an idle server with an expired ctx
+		select {
+		case <-idle:
+		default:
+			err = ctx.Err()
+		}
+	}
+	s.connsMutex.Lock()
+	for conn := range s.conns {
+		_ = conn.CloseWithError(closeErrCodeOK, "")
+	}
+	s.connsMutex.Unlock()
+	return errors.Join(err, s.Close())
+}
+
 func (s *serverImpl) Close() error {
-	err := errors.Join(s.listener.Close(), s.tr.Close(), s.config.Conn.Close())
-	if s.config.Cleanup != nil {
-		err = errors.Join(err, s.config.Cleanup.Close())
+	config := s.config.Load()
+	err := errors.Join(s.listener.Close(), s.tr.Close(), config.Conn.Close())
+	if config.Cleanup != nil {
+		err = errors.Join(err, config.Cleanup.Close())
 	}
 	return err
 }
 
 func (s *serverImpl) handleClient(conn *quic.Conn) {
-	handler := newH3sHandler(s.config, conn)
+	s.connsMutex.Lock()
+	s.conns[conn] = struct{}{}
+	s.connsMutex.Unlock()
+	defer func() {
+		s.connsMutex.Lock()
+		delete(s.conns, conn)
+		s.connsMutex.Unlock()
+	}()
+	handler := newH3sHandler(&s.config, &s.drain, &s.connLimiter, &s.rateLimiters, conn)
 	h3s := http3.Server{
 		Handler:          handler,
 		StreamDispatcher: handler.ProxyStreamHijacker,
@@ -125,10 +193,15 @@ func (s *serverImpl) handleClient(conn *quic.Conn) {
 	err := h3s.ServeQUICConn(conn)
 	// If the client is authenticated, we need to log the disconnect event
 	if handler.authenticated {
-		if tl := s.config.TrafficLogger; tl != nil {
+		handler.releasePolicy()
+		config := s.config.Load()
+		if tl := config.TrafficLogger; tl != nil {
 			tl.LogOnlineState(handler.authID, false)
 		}
-		if el := s.config.EventLogger; el != nil {
+		if handler.connTracer != nil {
+			handler.connTracer.UntraceConn(handler.connStats)
+		}
+		if el := config.EventLogger; el != nil {
 			el.Disconnect(conn.RemoteAddr(), handler.authID, err)
 		}
 	}
@@ -136,87 +209,126 @@ func (s *serverImpl) handleClient(conn *quic.Conn) {
 }
 
 type h3sHandler struct {
-	config *Config
-	conn   *quic.Conn
+	configs      *atomic.Pointer[Config]
+	drain        *drainTracker
+	connLimiter  *connLimiter
+	rateLimiters *rateLimiterMap
+	conn         *quic.Conn
 
 	authenticated bool
 	authMutex     sync.Mutex
 	authID        string
 	connID        uint32 // a random id for dump streams
 
+	policy      *Policy // of the user, never nil after authentication
+	limitedConn *limitedConn
+	limiter     *userRateLimiter
+	streams     atomic.Int32
+	expireTimer *time.Timer
+
+	bwMutex  sync.Mutex
+	cc       congestion.Controller
+	clientRx uint64 // as last advertised by the client
+	txLimit  uint64 // set with ConnStats.SetBandwidth, 0 = none
+
+	connTracer ConnTracer // the one that traced connStats, in case the config is reloaded
+	connStats  *ConnStats
+
+	controlMutex sync.Mutex
+	control      *controlStream // nil until the client opens it
+
 	udpSM *udpSessionManager // Only set after authentication
 }
 
-func newH3sHandler(config *Config, conn *quic.Conn) *h3sHandler {
+func newH3sHandler(configs *atomic.Pointer[Config], drain *drainTracker, connLimiter *connLimiter, rateLimiters *rateLimiterMap, conn *quic.Conn) *h3sHandler {
 	return &h3sHandler{
-		config: config,
-		conn:   conn,
-		connID: rand.Uint32(),
+		configs:      configs,
+		drain:        drain,
+		connLimiter:  connLimiter,
+		rateLimiters: rateLimiters,
+		conn:         conn,
+		connID:       rand.Uint32(),
 	}
 }
 
+// config returns the current server config, which changes on reload.
+func (h *h3sHandler) config() *Config {
+	return h.configs.Load()
+}
+
 func (h *h3sHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost && r.Host == protocol.URLHost && r.URL.Path == protocol.URLPath {
+	// A request sent in 0-RTT could be a replay, so nothing is done with it until the
+	// handshake completes: authenticating alone can count towards a ban, and the
+	// masquerade may proxy the request elsewhere.
+	if !h.waitHandshake() {
+		return
+	}
+	config := h.config()
+	if h.isAuthMethod(config, r.Method) && r.Host == protocol.URLHost && r.URL.Path == protocol.URLPath {
 		h.authMutex.Lock()
 		defer h.authMutex.Unlock()
 		if h.authenticated {
 			// Already authenticated
-			protocol.AuthResponseToHeader(w.Header(), protocol.AuthResponse{
-				UDPEnabled: !h.config.DisableUDP,
-				Rx:         h.config.BandwidthConfig.MaxRx,
-				RxAuto:     h.config.IgnoreClientBandwidth,
-			})
+			protocol.AuthResponseToHeader(w.Header(), h.authResponse(config))
 			w.WriteHeader(protocol.StatusAuthOK)
 			return
 		}
 		authReq := protocol.AuthRequestFromHeader(r.Header)
-		actualTx := authReq.Rx
-		ok, id := h.config.Authenticator.Authenticate(h.conn.RemoteAddr(), authReq.Auth, actualTx)
+		ok, id, policy := authenticate(config.Authenticator, h.conn.RemoteAddr(), authReq.Auth, authReq.Rx)
+		if uc, isUC := config.TrafficLogger.(UserChecker); ok && isUC {
+			ok = uc.CheckUser(id)
+		}
+		if ok {
+			ok = h.acquirePolicy(config, id, policy)
+		}
 		if ok {
 			// Set authenticated flag
 			h.authenticated = true
 			h.authID = id
-			if h.config.IgnoreClientBandwidth {
-				// Ignore client bandwidth and use the configured congestion controller.
-				congestion.UseConfigured(h.conn, h.config.CongestionConfig.Type, h.config.CongestionConfig.BBRProfile)
-				actualTx = 0
-			} else {
-				// actualTx = min(serverTx, clientRx)
-				if h.config.BandwidthConfig.MaxTx > 0 && actualTx > h.config.BandwidthConfig.MaxTx {
-					// We have a maxTx limit and the client is asking for more than that,
-					// return and use the limit instead
-					actualTx = h.config.BandwidthConfig.MaxTx
-				}
-				if actualTx > 0 {
-					congestion.UseBrutal(h.conn, actualTx, h.config.BandwidthConfig.DisableLossCompensation)
-				} else {
-					// Client doesn't know its own bandwidth, use the configured congestion controller.
-					congestion.UseConfigured(h.conn, h.config.CongestionConfig.Type, h.config.CongestionConfig.BBRProfile)
-				}
-			}
+			h.bwMutex.Lock()
+			h.clientRx = authReq.Rx
+			actualTx := h.applyBandwidthLocked(config)
+			h.bwMutex.Unlock()
 			// Auth OK, send response
-			protocol.AuthResponseToHeader(w.Header(), protocol.AuthResponse{
-				UDPEnabled: !h.config.DisableUDP,
-				Rx:         h.config.BandwidthConfig.MaxRx,
-				RxAuto:     h.config.IgnoreClientBandwidth,
-			})
+			protocol.AuthResponseToHeader(w.Header(), h.authResponse(config))
 			w.WriteHeader(protocol.StatusAuthOK)
 			// Call event logger
-			if tl := h.config.TrafficLogger; tl != nil {
+			if tl := config.TrafficLogger; tl != nil {
 				tl.LogOnlineState(id, true)
+				if ct, ok := tl.(ConnTracer); ok {
+					h.connTracer = ct
+					h.connStats = &ConnStats{
+						AuthID:      id,
+						ConnID:      h.connID,
+						RemoteAddr:  h.conn.RemoteAddr(),
+						InitialTime: time.Now(),
+						pathStats: func() PathStats {
+							h.bwMutex.Lock()
+							cc := h.cc
+							h.bwMutex.Unlock()
+							return PathStats(congestion.GetPathStats(h.conn, cc))
+						},
+						sendMessage:  h.sendControl,
+						setBandwidth: h.setBandwidth,
+					}
+					ct.TraceConn(h.connStats)
+				}
 			}
-			if el := h.config.EventLogger; el != nil {
+			if el := config.EventLogger; el != nil {
 				el.Connect(h.conn.RemoteAddr(), id, actualTx)
 			}
 			// Initialize UDP session manager (if UDP is enabled)
 			// We use sync.Once to make sure that only one goroutine is started,
 			// as ServeHTTP may be called by multiple goroutines simultaneously
-			if !h.config.DisableUDP {
+			if h.udpEnabled(config) {
 				go func() {
+					if !h.waitHandshake() {
+						return
+					}
 					sm := newUDPSessionManager(
-						&udpIOImpl{h.conn, id, h.config.TrafficLogger, h.config.RequestHook, h.config.Outbound},
-						&udpEventLoggerImpl{h.conn, id, h.config.EventLogger},
-						h.config.UDPIdleTimeout,
+						&udpIOImpl{h.conn, id, config.TrafficLogger, config.RequestHook, h.configs, h.drain, h.policy.Outbound, h.limiter},
+						&udpEventLoggerImpl{h.conn, id, config.EventLogger},
+						config.UDPIdleTimeout,
 					)
 					h.udpSM = sm
 					go sm.Run()
@@ -232,6 +344,115 @@ func (h *h3sHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// acquirePolicy checks the policy of a user who just passed the authenticator,
+// and if it allows this connection, counts it and keeps the policy for the connection.
+func (h *h3sHandler) acquirePolicy(config *Config, id string, policy *Policy) bool {
+	if policy == nil {
+		policy = &Policy{}
+	}
+	if !policy.ExpireAt.IsZero() && !time.Now().Before(policy.ExpireAt) {
+		return false
+	}
+	if _, err := policyOutbound(config, policy.Outbound); err != nil {
+		return false
+	}
+	if !h.acquireConnLimit(config, id, policy) {
+		return false
+	}
+	h.policy = policy
+	h.limiter = h.rateLimiters.Acquire(id, RateLimitConfig{
+		MaxTx: minLimit(config.RateLimitConfig.MaxTx, policy.RateLimit.MaxTx),
+		MaxRx: minLimit(config.RateLimitConfig.MaxRx, policy.RateLimit.MaxRx),
+	})
+	if !policy.ExpireAt.IsZero() {
+		h.expireTimer = time.AfterFunc(time.Until(policy.ExpireAt), func() {
+			h.kick(policyExpiredText)
+		})
+	}
+	return true
+}
+
+// acquireConnLimit counts the connection for ConnLimitConfig and the MaxConns of the policy,
+// and closes the connections it replaces, if any.
+func (h *h3sHandler) acquireConnLimit(config *Config, id string, policy *Policy) bool {
+	maxUser := config.ConnLimitConfig.MaxPerUser
+	if policy.MaxConns > 0 && (maxUser == 0 || policy.MaxConns < maxUser) {
+		maxUser = policy.MaxConns
+	}
+	lc := &limitedConn{h: h, id: id, prefix: connPrefix(h.conn.RemoteAddr(), config.ConnLimitConfig)}
+	evicted, limit, ok := h.connLimiter.Acquire(lc, maxUser, config.ConnLimitConfig.MaxPerIP, config.ConnLimitConfig.EvictOldest)
+	cl, _ := config.EventLogger.(ConnLimitLogger)
+	if !ok {
+		if cl != nil {
+			cl.ConnRejected(h.conn.RemoteAddr(), id, limit)
+		}
+		return false
+	}
+	for _, e := range evicted {
+		if cl != nil {
+			cl.ConnEvicted(e.conn.h.conn.RemoteAddr(), e.conn.id, e.limit, h.conn.RemoteAddr())
+		}
+		// Not while holding our authMutex, sending the kick can block
+		go e.conn.h.kick(connEvictedText)
+	}
+	h.limitedConn = lc
+	return true
+}
+
+// kick closes the connection, after telling the client why if it can be told.
+func (h *h3sHandler) kick(text string) {
+	if h.sendControl(ControlMessage{Type: ControlKick, Text: text}) != nil {
+		_ = h.conn.CloseWithError(closeErrCodeOK, text)
+	}
+}
+
+// releasePolicy undoes acquirePolicy when the connection is closed.
+func (h *h3sHandler) releasePolicy() {
+	if h.expireTimer != nil {
+		h.expireTimer.Stop()
+	}
+	h.connLimiter.Release(h.limitedConn)
+	h.rateLimiters.Release(h.authID)
+}
+
+func (h *h3sHandler) udpEnabled(config *Config) bool {
+	return !config.DisableUDP && !h.policy.DisableUDP
+}
+
+// maxRx returns the most we ask the client to send, from the config and the policy of the user.
+func (h *h3sHandler) maxRx(config *Config) uint64 {
+	return minLimit(config.BandwidthConfig.MaxRx, h.policy.MaxRx)
+}
+
+func (h *h3sHandler) authResponse(config *Config) protocol.AuthResponse {
+	return protocol.AuthResponse{
+		UDPEnabled: h.udpEnabled(config),
+		Rx:         h.maxRx(config),
+		RxAuto:     config.IgnoreClientBandwidth,
+		ZeroRTT:    config.QUICConfig.Enable0RTT,
+		Control:    true,
+	}
+}
+
+// isAuthMethod returns whether method can be used for the auth request.
+// Clients send it as a GET when they send it in 0-RTT, which we only accept when enabled.
+func (h *h3sHandler) isAuthMethod(config *Config, method string) bool {
+	return method == http.MethodPost || (method == http.MethodGet && config.QUICConfig.Enable0RTT)
+}
+
+// waitHandshake waits for the handshake to complete, and returns false if the connection
+// is closed before that. The auth request is the only thing we accept in 0-RTT,
+// and even that is only processed after the handshake, as everything sent in 0-RTT
+// could be replayed by an attacker.
+func (h *h3sHandler) waitHandshake() bool {
+	select {
+	case <-h.conn.HandshakeComplete():
+		return true
+	case <-h.conn.Context().Done():
+		return false
+	}
+}
+
 func (h *h3sHandler) ProxyStreamHijacker(ft http3.FrameType, stream *quic.Stream, err error) (bool, error) {
 	if err != nil || !h.authenticated {
 		return false, nil
@@ -248,13 +469,126 @@ func (h *h3sHandler) ProxyStreamHijacker(ft http3.FrameType, stream *quic.Stream
 		qStream := &utils.QStream{Stream: stream}
 		go h.handleTCPRequest(qStream)
 		return true, nil
+	case protocol.FrameTypeControl:
+		if _, err := quicvarint.Read(quicvarint.NewReader(stream)); err != nil {
+			return false, err
+		}
+		go h.handleControlStream(&utils.QStream{Stream: stream})
+		return true, nil
 	default:
 		return false, nil
 	}
 }
 
+// handleControlStream keeps the control stream of the client for sendControl,
+// and reads what the client sends on it until it is closed.
+func (h *h3sHandler) handleControlStream(stream *utils.QStream) {
+	defer stream.Close()
+	if !h.waitHandshake() {
+		return
+	}
+	if err := protocol.ReadControlOpen(stream); err != nil {
+		return
+	}
+	control := &controlStream{stream: stream}
+	h.controlMutex.Lock()
+	if h.control != nil {
+		// Only one per connection
+		h.controlMutex.Unlock()
+		return
+	}
+	h.control = control
+	h.controlMutex.Unlock()
+	defer func() {
+		h.controlMutex.Lock()
+		h.control = nil
+		h.controlMutex.Unlock()
+	}()
+	for {
+		msg, err := protocol.ReadControlMessage(stream)
+		if err != nil {
+			return
+		}
+		if msg.Type == protocol.ControlTypeBandwidth {
+			// The client changed its max receive rate
+			h.bwMutex.Lock()
+			h.clientRx = msg.Value
+			h.applyBandwidthLocked(h.config())
+			h.bwMutex.Unlock()
+		}
+	}
+}
+
+// applyBandwidthLocked picks the congestion controller from the rate the client can receive
+// and our limits, the same way as at authentication, and returns the resulting send rate
+// (0 if not Brutal). A Brutal controller is retargeted rather than replaced.
+// Must be called with bwMutex held.
+func (h *h3sHandler) applyBandwidthLocked(config *Config) uint64 {
+	var tx uint64
+	if !config.IgnoreClientBandwidth {
+		// tx = min(serverTx, clientRx)
+		tx = h.clientRx
+		if config.BandwidthConfig.MaxTx > 0 && tx > config.BandwidthConfig.MaxTx {
+			tx = config.BandwidthConfig.MaxTx
+		}
+		if h.policy.MaxTx > 0 && tx > h.policy.MaxTx {
+			tx = h.policy.MaxTx
+		}
+	}
+	if h.txLimit > 0 && (tx == 0 || tx > h.txLimit) {
+		tx = h.txLimit
+	}
+	if tx > 0 {
+		if !h.cc.SetBrutalBandwidth(tx) {
+			h.cc = congestion.UseBrutal(h.conn, tx, config.BandwidthConfig.DisableLossCompensation)
+		}
+	} else if h.cc.Type == "" || h.cc.Type == congestion.TypeBrutal {
+		// Client doesn't know its own bandwidth (or we ignore it),
+		// use the configured congestion controller.
+		h.cc = congestion.UseConfigured(h.conn, config.CongestionConfig.Type, config.CongestionConfig.BBRProfile)
+	}
+	return tx
+}
+
+// setBandwidth implements ConnStats.SetBandwidth.
+func (h *h3sHandler) setBandwidth(tx, rx uint64) error {
+	config := h.config()
+	h.bwMutex.Lock()
+	h.txLimit = tx
+	h.applyBandwidthLocked(config)
+	h.bwMutex.Unlock()
+	if rx == 0 {
+		rx = h.maxRx(config)
+	}
+	return h.sendControl(ControlMessage{Type: ControlBandwidth, Value: rx})
+}
+
+// sendControl pushes msg to the client, and closes the connection shortly after ControlKick.
+func (h *h3sHandler) sendControl(msg ControlMessage) error {
+	h.controlMutex.Lock()
+	control := h.control
+	h.controlMutex.Unlock()
+	if control == nil {
+		return errControlUnsupported
+	}
+	if err := control.Send(msg); err != nil {
+		return err
+	}
+	if msg.Type == ControlKick {
+		time.AfterFunc(kickDelay, func() {
+			_ = h.conn.CloseWithError(closeErrCodeOK, msg.Text)
+		})
+	}
+	return nil
+}
+
 func (h *h3sHandler) handleTCPRequest(stream *utils.QStream) {
-	trafficLogger := h.config.TrafficLogger
+	if !h.waitHandshake() {
+		_ = stream.Close()
+		return
+	}
+	config := h.config()
+	trafficLogger := config.TrafficLogger
 	streamStats := &StreamStats{
 		AuthID:      h.authID,
 		ConnID:      h.connID,
@@ -277,18 +611,33 @@ func (h *h3sHandler) handleTCPRequest(stream *utils.QStream) {
 		return
 	}
 	streamStats.ReqAddr.Store(reqAddr)
+	if !h.drain.Acquire() {
+		_ = protocol.WriteTCPResponse(stream, false, errShuttingDown.Error())
+		_ = stream.Close()
+		return
+	}
+	defer h.drain.Release()
+	if max := h.policy.MaxStreams; max > 0 {
+		if h.streams.Add(1) > int32(max) {
+			h.streams.Add(-1)
+			_ = protocol.WriteTCPResponse(stream, false, errTooManyStreams.Error())
+			_ = stream.Close()
+			return
+		}
+		defer h.streams.Add(-1)
+	}
 	// Call the hook if set
 	var putback []byte
 	var hooked bool
-	if h.config.RequestHook != nil {
-		hooked = h.config.RequestHook.Check(false, reqAddr)
+	if config.RequestHook != nil {
+		hooked = config.RequestHook.Check(false, reqAddr)
 		// When the hook is enabled, the server should always accept a connection
 		// so that the client will send whatever request the hook wants to see.
 		// This is essentially a server-side fast-open.
 		if hooked {
 			streamStats.State.Store(StreamStateHooking)
 			_ = protocol.WriteTCPResponse(stream, true, "RequestHook enabled")
-			putback, err = h.config.RequestHook.TCP(stream, &reqAddr)
+			putback, err = config.RequestHook.TCP(stream, &reqAddr)
 			if err != nil {
 				_ = stream.Close()
 				return
@@ -297,20 +646,24 @@ func (h *h3sHandler) handleTCPRequest(stream *utils.QStream) {
 		}
 	}
 	// Log the event
-	if h.config.EventLogger != nil {
-		h.config.EventLogger.TCPRequest(h.conn.RemoteAddr(), h.authID, reqAddr)
+	if config.EventLogger != nil {
+		config.EventLogger.TCPRequest(h.conn.RemoteAddr(), h.authID, reqAddr)
 	}
 	// Dial target
 	streamStats.State.Store(StreamStateConnecting)
-	tConn, err := h.config.Outbound.TCP(reqAddr)
+	var tConn net.Conn
+	ob, err := policyOutbound(config, h.policy.Outbound)
+	if err == nil {
+		tConn, err = ob.TCP(reqAddr)
+	}
 	if err != nil {
 		if !hooked {
 			_ = protocol.WriteTCPResponse(stream, false, err.Error())
 		}
 		_ = stream.Close()
 		// Log the error
-		if h.config.EventLogger != nil {
-			h.config.EventLogger.TCPError(h.conn.RemoteAddr(), h.authID, reqAddr, err)
+		if config.EventLogger != nil {
+			config.EventLogger.TCPError(h.conn.RemoteAddr(), h.authID, reqAddr, err)
 		}
 		return
 	}
@@ -324,14 +677,14 @@ func (h *h3sHandler) handleTCPRequest(stream *utils.QStream) {
 		streamStats.Tx.Add(uint64(n))
 	}
 	// Start proxying
-	if trafficLogger != nil {
-		err = copyTwoWayEx(h.authID, stream, tConn, trafficLogger, streamStats)
+	if trafficLogger != nil || h.limiter.Limited() {
+		err = copyTwoWayEx(h.conn.Context(), h.authID, stream, tConn, trafficLogger, h.limiter, streamStats)
 	} else {
-		// Use the fast path if no traffic logger is set
+		// Use the fast path if no traffic logger or rate limit is set
 		err = copyTwoWay(stream, tConn)
 	}
-	if h.config.EventLogger != nil {
-		h.config.EventLogger.TCPError(h.conn.RemoteAddr(), h.authID, reqAddr, err)
+	if config.EventLogger != nil {
+		config.EventLogger.TCPError(h.conn.RemoteAddr(), h.authID, reqAddr, err)
 	}
 	// Cleanup
 	_ = tConn.Close()
@@ -343,8 +696,8 @@ func (h *h3sHandler) handleTCPRequest(stream *utils.QStream) {
 }
 
 func (h *h3sHandler) masqHandler(w http.ResponseWriter, r *http.Request) {
-	if h.config.MasqHandler != nil {
-		h.config.MasqHandler.ServeHTTP(w, r)
+	if masq := h.config().MasqHandler; masq != nil {
+		masq.ServeHTTP(w, r)
 	} else {
 		// Return 404 for everything
 		http.NotFound(w, r)
@@ -357,7 +710,10 @@ type udpIOImpl struct {
 	AuthID        string
 	TrafficLogger TrafficLogger
 	RequestHook   RequestHook
-	Outbound      Outbound
+	Configs       *atomic.Pointer[Config] // for the current Outbound
+	Drain         *drainTracker
+	OutboundName  string // from the policy of the user
+	Limiter       *userRateLimiter
 }
 
 func (io *udpIOImpl) ReceiveMessage() (*protocol.UDPMessage, error) {
@@ -372,6 +728,10 @@ func (io *udpIOImpl) ReceiveMessage() (*protocol.UDPMessage, error) {
 			// Invalid message, this is fine - just wait for the next
 			continue
 		}
+		if !io.Limiter.AllowRx(uint64(len(udpMsg.Data))) {
+			// Over the rate limit, drop it like the network would
+			continue
+		}
 		if io.TrafficLogger != nil {
 			ok := io.TrafficLogger.LogTraffic(io.AuthID, uint64(len(udpMsg.Data)), 0)
 			if !ok {
@@ -385,6 +745,10 @@ func (io *udpIOImpl) ReceiveMessage() (*protocol.UDPMessage, error) {
 }
 
 func (io *udpIOImpl) SendMessage(buf []byte, msg *protocol.UDPMessage) error {
+	if !io.Limiter.AllowTx(uint64(len(msg.Data))) {
+		// Over the rate limit, silent drop
+		return nil
+	}
 	if io.TrafficLogger != nil {
 		ok := io.TrafficLogger.LogTraffic(io.AuthID, 0, uint64(len(msg.Data)))
 		if !ok {
@@ -410,11 +774,28 @@ func (io *udpIOImpl) Hook(data []byte, reqAddr *string) error {
 }
 
 func (io *udpIOImpl) UDP(reqAddr string) (UDPConn, error) {
-	return io.Outbound.UDP(reqAddr)
+	if !io.Drain.Acquire() {
+		return nil, errShuttingDown
+	}
+	ob, err := policyOutbound(io.Configs.Load(), io.OutboundName)
+	if err != nil {
+		io.Drain.Release()
+		return nil, err
+	}
+	conn, err := ob.UDP(reqAddr)
+	if err != nil {
+		io.Drain.Release()
+		return nil, err
+	}
+	return &drainUDPConn{UDPConn: conn, tracker: io.Drain}, nil
 }
 
 func (io *udpIOImpl) CheckUDP(reqAddr string) error {
-	return io.Outbound.CheckUDP(reqAddr)
+	ob, err := policyOutbound(io.Configs.Load(), io.OutboundName)
+	if err != nil {
+		return err
+	}
+	return ob.CheckUDP(reqAddr)
 }
 
 type udpEventLoggerImpl struct {
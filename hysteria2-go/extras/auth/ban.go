@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	defaultBanWindow   = 10 * time.Minute
+	defaultBanDuration = 1 * time.Hour
+)
+
+// BanConfig configures a BanList. MaxFailures 0 disables banning.
+type BanConfig struct {
+	MaxFailures int           // failed authentications within Window that get an IP banned
+	Window      time.Duration // 10 minutes if 0
+	BanDuration time.Duration // 1 hour if 0
+	File        string        // if set, a line is appended to it on every ban and unban
+}
+
+// Ban is an IP that is currently banned.
+type Ban struct {
+	IP    string    `json:"ip"`
+	Until time.Time `json:"until"`
+}
+
+type banFailures struct {
+	count int
+	first time.Time
+}
+
+// BanList counts failed authentications per source IP, and bans IPs that fail
+// too often. It is kept across config reloads, and its config can be changed with SetConfig.
+//
+// Lines written to the ban file look like
+//
+//	2006-01-02T15:04:05Z ban 192.0.2.1 until 2006-01-02T16:04:05Z
+//	2006-01-02T15:10:00Z unban 192.0.2.1
+//
+// so that tools like fail2ban can block the IPs at the firewall too.
+type BanList struct {
+	nowFunc func() time.Time
+
+	mutex     sync.Mutex
+	config    BanConfig
+	failures  map[netip.Addr]*banFailures
+	bans      map[netip.Addr]time.Time
+	lastSweep time.Time
+	banCount  atomic.Int32 // len(bans), for the packet filter to skip the lock when nobody is banned
+}
+
+func NewBanList(config BanConfig) *BanList {
+	l := &BanList{
+		nowFunc:  time.Now,
+		failures: make(map[netip.Addr]*banFailures),
+		bans:     make(map[netip.Addr]time.Time),
+	}
+	l.SetConfig(config)
+	return l
+}
+
+// SetConfig replaces the config. Existing bans stay until they expire.
+func (l *BanList) SetConfig(config BanConfig) {
+	if config.Window == 0 {
+		config.Window = defaultBanWindow
+	}
+	if config.BanDuration == 0 {
+		config.BanDuration = defaultBanDuration
+	}
+	l.mutex.Lock()
+	l.config = config
+	l.mutex.Unlock()
+}
+
+// Banned returns whether the IP of addr is currently banned.
+func (l *BanList) Banned(addr net.Addr) bool {
+	if l.banCount.Load() == 0 {
+		return false
+	}
+	ip, ok := addrIP(addr)
+	if !ok {
+		return false
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.bannedLocked(ip, l.nowFunc())
+}
+
+func (l *BanList) bannedLocked(ip netip.Addr, now time.Time) bool {
+	until, ok := l.bans[ip]
+	if !ok {
+		return false
+	}
+	if now.Before(until) {
+		return true
+	}
+	delete(l.bans, ip)
+	l.banCount.Store(int32(len(l.bans)))
+	return false
+}
+
+// Fail records a failed authentication from addr, and bans its IP if it has failed
+// MaxFailures times within Window.
+func (l *BanList) Fail(addr net.Addr) {
+	ip, ok := addrIP(addr)
+	if !ok {
+		return
+	}
+	l.mutex.Lock()
+	if l.config.MaxFailures <= 0 {
+		l.mutex.Unlock()
+		return
+	}
+	now := l.nowFunc()
+	l.sweepLocked(now)
+	f := l.failures[ip]
+	if f == nil || now.Sub(f.first) > l.config.Window {
+		f = &banFailures{first: now}
+		l.failures[ip] = f
+	}
+	f.count++
+	if f.count < l.config.MaxFailures {
+		l.mutex.Unlock()
+		return
+	}
+	delete(l.failures, ip)
+	until := now.Add(l.config.BanDuration)
+	l.bans[ip] = until
+	l.banCount.Store(int32(len(l.bans)))
+	file := l.config.File
+	l.mutex.Unlock()
+	l.writeFile(file, fmt.Sprintf("%s ban %s until %s", now.UTC().Format(time.RFC3339), ip, until.UTC().Format(time.RFC3339)))
+}
+
+// Succeed clears the failures of addr after a successful authentication.
+func (l *BanList) Succeed(addr net.Addr) {
+	ip, ok := addrIP(addr)
+	if !ok {
+		return
+	}
+	l.mutex.Lock()
+	delete(l.failures, ip)
+	l.mutex.Unlock()
+}
+
+// sweepLocked drops expired failures and bans, at most once per Window,
+// so that scans from many IPs don't grow the maps forever.
+func (l *BanList) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < l.config.Window {
+		return
+	}
+	l.lastSweep = now
+	for ip, f := range l.failures {
+		if now.Sub(f.first) > l.config.Window {
+			delete(l.failures, ip)
+		}
+	}
+	for ip, until := range l.bans {
+		if !now.Before(until) {
+			delete(l.bans, ip)
+		}
+	}
+	l.banCount.Store(int32(len(l.bans)))
+}
+
+// Bans returns the IPs that are currently banned, sorted by IP.
+func (l *BanList) Bans() []Ban {
+	l.mutex.Lock()
+	now := l.nowFunc()
+	ips := make([]netip.Addr, 0, len(l.bans))
+	for ip := range l.bans {
+		if l.bannedLocked(ip, now) {
+			ips = append(ips, ip)
+		}
+	}
+	slices.SortFunc(ips, netip.Addr.Compare)
+	bans := make([]Ban, len(ips))
+	for i, ip := range ips {
+		bans[i] = Ban{IP: ip.String(), Until: l.bans[ip]}
+	}
+	l.mutex.Unlock()
+	return bans
+}
+
+// Unban lifts the ban of an IP and clears its failures.
+// It returns false if the IP is invalid or was not banned.
+func (l *BanList) Unban(s string) bool {
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	l.mutex.Lock()
+	now := l.nowFunc()
+	delete(l.failures, ip)
+	banned := l.bannedLocked(ip, now)
+	delete(l.bans, ip)
+	l.banCount.Store(int32(len(l.bans)))
+	file := l.config.File
+	l.mutex.Unlock()
+	if banned {
+		l.writeFile(file, fmt.Sprintf("%s unban %s", now.UTC().Format(time.RFC3339), ip))
+	}
+	return banned
+}
+
+func (l *BanList) writeFile(file, line string) {
+	if file == "" {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return
+	}
+	_, _ = f.WriteString(line + "\n")
+	_ = f.Close()
+}
+
+// Wrap returns an Authenticator that uses a and records its results in the list.
+func (l *BanList) Wrap(a server.Authenticator) *BanAuthenticator {
+	return &BanAuthenticator{Authenticator: a, List: l}
+}
+
+// addrIP returns the IP of addr, with IPv4-mapped IPv6 addresses unmapped.
+func addrIP(addr net.Addr) (netip.Addr, bool) {
+	if addr == nil {
+		return netip.Addr{}, false
+	}
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		ip, ok := netip.AddrFromSlice(udpAddr.IP)
+		return ip.Unmap(), ok
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ap.Addr().Unmap(), true
+}
+
+var _ server.PolicyAuthenticator = &BanAuthenticator{}
+
+// BanAuthenticator rejects clients from IPs banned by List, even with correct credentials,
+// so that they only ever see the masquerade. Other clients are passed to Authenticator,
+// and its failures counted. The policy of Authenticator is passed through if it returns one.
+type BanAuthenticator struct {
+	Authenticator server.Authenticator
+	List          *BanList
+}
+
+func (a *BanAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ok, id, _ = a.AuthenticatePolicy(addr, auth, tx)
+	return ok, id
+}
+
+func (a *BanAuthenticator) AuthenticatePolicy(addr net.Addr, auth string, tx uint64) (ok bool, id string, policy *server.Policy) {
+	if a.List.Banned(addr) {
+		return false, "", nil
+	}
+	if pa, isPA := a.Authenticator.(server.PolicyAuthenticator); isPA {
+		ok, id, policy = pa.AuthenticatePolicy(addr, auth, tx)
+	} else {
+		ok, id = a.Authenticator.Authenticate(addr, auth, tx)
+	}
+	if ok {
+		a.List.Succeed(addr)
+	} else {
+		a.List.Fail(addr)
+	}
+	return ok, id, policy
+}
+
+// udpLikePacketConn is the subset of *net.UDPConn methods that quic-go uses
+// for UDP-specific optimizations, see the same interface in extras/obfs.
+type udpLikePacketConn interface {
+	net.PacketConn
+	SyscallConn() (syscall.RawConn, error)
+	SetReadBuffer(int) error
+	SetWriteBuffer(int) error
+}
+
+// banPacketConn drops all packets from banned IPs, so that they can't even
+// complete a handshake, let alone reach the masquerade.
+type banPacketConn struct {
+	net.PacketConn
+	List *BanList
+}
+
+type banPacketConnUDP struct {
+	*banPacketConn
+	UDPConn udpLikePacketConn
+}
+
+// WrapPacketConn returns a PacketConn that silently drops the packets of banned IPs.
+func (l *BanList) WrapPacketConn(conn net.PacketConn) net.PacketConn {
+	bc := &banPacketConn{PacketConn: conn, List: l}
+	if udpConn, ok := conn.(udpLikePacketConn); ok {
+		return &banPacketConnUDP{banPacketConn: bc, UDPConn: udpConn}
+	}
+	return bc
+}
+
+func (c *banPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(p)
+		if err != nil || !c.List.Banned(addr) {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *banPacketConnUDP) SyscallConn() (syscall.RawConn, error) {
+	return c.UDPConn.SyscallConn()
+}
+
+func (c *banPacketConnUDP) SetReadBuffer(bytes int) error {
+	return c.UDPConn.SetReadBuffer(bytes)
+}
+
+func (c *banPacketConnUDP) SetWriteBuffer(bytes int) error {
+	return c.UDPConn.SetWriteBuffer(bytes)
+}
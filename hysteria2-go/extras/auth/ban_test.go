@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBanAuthenticator(t *testing.T) {
+	banFile := filepath.Join(t.TempDir(), "bans.log")
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	list := NewBanList(BanConfig{
+		MaxFailures: 3,
+		Window:      time.Minute,
+		BanDuration: time.Hour,
+		File:        banFile,
+	})
+	list.nowFunc = func() time.Time { return now }
+	a := list.Wrap(&PasswordAuthenticator{Password: "correct"})
+
+	attacker := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}
+	attackerMapped := &net.UDPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 5678}
+	other := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1234}
+
+	// Failures outside the window don't add up
+	ok, _ := a.Authenticate(attacker, "wrong", 0)
+	assert.False(t, ok)
+	ok, _ = a.Authenticate(attacker, "wrong", 0)
+	assert.False(t, ok)
+	now = now.Add(2 * time.Minute)
+	ok, _ = a.Authenticate(attacker, "wrong", 0)
+	assert.False(t, ok)
+	assert.Empty(t, list.Bans())
+
+	// A success clears the failures
+	ok, _ = a.Authenticate(attacker, "correct", 0)
+	assert.True(t, ok)
+	for i := 0; i < 2; i++ {
+		ok, _ = a.Authenticate(attacker, "wrong", 0)
+		assert.False(t, ok)
+	}
+	assert.Empty(t, list.Bans())
+
+	// Banned, from any port and even with the correct password
+	ok, _ = a.Authenticate(attackerMapped, "wrong", 0)
+	assert.False(t, ok)
+	assert.Equal(t, []Ban{{IP: "192.0.2.1", Until: now.Add(time.Hour)}}, list.Bans())
+	ok, _ = a.Authenticate(attacker, "correct", 0)
+	assert.False(t, ok)
+	assert.True(t, list.Banned(attackerMapped))
+
+	// Other IPs are not affected
+	ok, _ = a.Authenticate(other, "correct", 0)
+	assert.True(t, ok)
+	assert.False(t, list.Banned(other))
+
+	// Manual unban
+	assert.False(t, list.Unban("invalid"))
+	assert.False(t, list.Unban("2001:db8::1"))
+	assert.True(t, list.Unban("192.0.2.1"))
+	ok, _ = a.Authenticate(attacker, "correct", 0)
+	assert.True(t, ok)
+
+	// Bans expire
+	for i := 0; i < 3; i++ {
+		_, _ = a.Authenticate(other, "wrong", 0)
+	}
+	assert.True(t, list.Banned(other))
+	now = now.Add(time.Hour)
+	assert.False(t, list.Banned(other))
+	assert.Empty(t, list.Bans())
+
+	bs, err := os.ReadFile(banFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"2026-01-15T00:02:00Z ban 192.0.2.1 until 2026-01-15T01:02:00Z",
+		"2026-01-15T00:02:00Z unban 192.0.2.1",
+		"2026-01-15T00:02:00Z ban 2001:db8::1 until 2026-01-15T01:02:00Z",
+	}, strings.Split(strings.TrimSpace(string(bs)), "\n"))
+}
+
+func TestBanAuthenticatorDisabled(t *testing.T) {
+	list := NewBanList(BanConfig{})
+	a := list.Wrap(&PasswordAuthenticator{Password: "correct"})
+	addr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}
+	for i := 0; i < 100; i++ {
+		_, _ = a.Authenticate(addr, "wrong", 0)
+	}
+	ok, _ := a.Authenticate(addr, "correct", 0)
+	assert.True(t, ok)
+	assert.Empty(t, list.Bans())
+}
+
+// queuePacketConn returns the packets of its queue, one per ReadFrom.
+type queuePacketConn struct {
+	net.PacketConn
+	queue []queuedPacket
+}
+
+type queuedPacket struct {
+	addr net.Addr
+	data string
+}
+
+func (c *queuePacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if len(c.queue) == 0 {
+		return 0, nil, io.EOF
+	}
+	pkt := c.queue[0]
+	c.queue = c.queue[1:]
+	return copy(p, pkt.data), pkt.addr, nil
+}
+
+func TestBanPacketConn(t *testing.T) {
+	list := NewBanList(BanConfig{MaxFailures: 1})
+	banned := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234}
+	allowed := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1234}
+	list.Fail(banned)
+
+	conn := list.WrapPacketConn(&queuePacketConn{queue: []queuedPacket{
+		{banned, "banned 1"},
+		{allowed, "allowed"},
+		{banned, "banned 2"},
+	}})
+	buf := make([]byte, 16)
+	n, addr, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, allowed, addr)
+	assert.Equal(t, "allowed", string(buf[:n]))
+	_, _, err = conn.ReadFrom(buf)
+	assert.Equal(t, io.EOF, err)
+
+	// UDP optimizations are kept
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	defer udpConn.Close()
+	_, ok := list.WrapPacketConn(udpConn).(udpLikePacketConn)
+	assert.True(t, ok)
+}
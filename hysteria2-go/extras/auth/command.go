@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"net"
 	"os/exec"
 	"strconv"
@@ -9,20 +10,46 @@ import (
 	"github.com/apernet/hysteria/core/v2/server"
 )
 
-var _ server.Authenticator = &CommandAuthenticator{}
+var _ server.PolicyAuthenticator = &CommandAuthenticator{}
 
+// CommandAuthenticator runs Cmd with the client address, the auth string and tx as arguments.
+// The client is accepted if the command exits with 0. The first line of its output is the ID,
+// and the rest, if any, is the policy of the user in the same JSON format as HTTPAuthenticator.
 type CommandAuthenticator struct {
 	Cmd string
 }
 
 func (a *CommandAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ok, id, _ = a.AuthenticatePolicy(addr, auth, tx)
+	return ok, id
+}
+
+func (a *CommandAuthenticator) AuthenticatePolicy(addr net.Addr, auth string, tx uint64) (ok bool, id string, policy *server.Policy) {
 	cmd := exec.Command(a.Cmd, addr.String(), auth, strconv.Itoa(int(tx)))
 	out, err := cmd.Output()
 	if err != nil {
 		// This includes failing to execute the command,
 		// or the command exiting with a non-zero exit code.
-		return false, ""
-	} else {
-		return true, strings.TrimSpace(string(out))
+		return false, "", nil
+	}
+	id, policy, err = parseCommandOutput(string(out))
+	if err != nil {
+		// Don't let the user in without the policy meant for them
+		return false, "", nil
+	}
+	return true, id, policy
+}
+
+func parseCommandOutput(out string) (id string, policy *server.Policy, err error) {
+	id, rest, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	id = strings.TrimSpace(id)
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return id, nil, nil
+	}
+	var p policyJSON
+	if err := json.Unmarshal([]byte(rest), &p); err != nil {
+		return "", nil, err
 	}
+	return id, p.Policy(), nil
 }
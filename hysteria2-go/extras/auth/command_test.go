@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+func TestParseCommandOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		out        string
+		wantID     string
+		wantPolicy *server.Policy
+		wantErr    bool
+	}{
+		{
+			name:   "id only",
+			out:    "alice\n",
+			wantID: "alice",
+		},
+		{
+			name:   "empty",
+			out:    "",
+			wantID: "",
+		},
+		{
+			name:   "id and policy",
+			out:    "bob\n{\"max_tx\": 1250000, \"udp\": false, \"max_conns\": 2, \"outbound\": \"slow\", \"expire_at\": 1700000000}\n",
+			wantID: "bob",
+			wantPolicy: &server.Policy{
+				MaxTx:      1250000,
+				DisableUDP: true,
+				MaxConns:   2,
+				Outbound:   "slow",
+				ExpireAt:   time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name:    "invalid policy",
+			out:     "carol\nnot json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, policy, err := parseCommandOutput(tt.out)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
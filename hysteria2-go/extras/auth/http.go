@@ -17,7 +17,7 @@ const (
 	httpAuthTimeout = 10 * time.Second
 )
 
-var _ server.Authenticator = &HTTPAuthenticator{}
+var _ server.PolicyAuthenticator = &HTTPAuthenticator{}
 
 var errInvalidStatusCode = errors.New("invalid status code")
 
@@ -47,8 +47,9 @@ type httpAuthRequest struct {
 }
 
 type httpAuthResponse struct {
-	OK bool   `json:"ok"`
-	ID string `json:"id"`
+	OK     bool        `json:"ok"`
+	ID     string      `json:"id"`
+	Policy *policyJSON `json:"policy"`
 }
 
 func (a *HTTPAuthenticator) post(req *httpAuthRequest) (*httpAuthResponse, error) {
@@ -77,14 +78,19 @@ func (a *HTTPAuthenticator) post(req *httpAuthRequest) (*httpAuthResponse, error
 }
 
 func (a *HTTPAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ok, id, _ = a.AuthenticatePolicy(addr, auth, tx)
+	return ok, id
+}
+
+func (a *HTTPAuthenticator) AuthenticatePolicy(addr net.Addr, auth string, tx uint64) (ok bool, id string, policy *server.Policy) {
 	req := &httpAuthRequest{
 		Addr: addr.String(),
 		Auth: auth,
 		Tx:   tx,
 	}
 	resp, err := a.post(req)
-	if err != nil {
-		return false, ""
+	if err != nil || !resp.OK {
+		return false, "", nil
 	}
-	return resp.OK, resp.ID
+	return true, resp.ID, resp.Policy.Policy()
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/core/v2/server"
 )
 
 func TestHTTPAuthenticator(t *testing.T) {
@@ -33,4 +35,16 @@ func TestHTTPAuthenticator(t *testing.T) {
 	}, "wahaha", 12345)
 	assert.True(t, ok)
 	assert.Equal(t, "some_unique_id", id)
+
+	ok, id, policy := auth.AuthenticatePolicy(&net.UDPAddr{
+		IP:   net.ParseIP("123.123.123.123"),
+		Port: 5566,
+	}, "limited", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "limited_id", id)
+	assert.Equal(t, &server.Policy{
+		MaxRx:      1250000,
+		DisableUDP: true,
+		MaxStreams: 16,
+	}, policy)
 }
@@ -16,6 +16,14 @@ def auth():
 
     if addr == "123.123.123.123:5566" and auth == "wahaha" and tx == 12345:
         return jsonify({"ok": True, "id": "some_unique_id"})
+    elif auth == "limited":
+        return jsonify(
+            {
+                "ok": True,
+                "id": "limited_id",
+                "policy": {"max_rx": 1250000, "udp": False, "max_streams": 16},
+            }
+        )
     else:
         return jsonify({"ok": False, "id": ""})
 
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// policyJSON is the per-user policy returned by the HTTP and command authenticators.
+// Bandwidth is in bytes per second, expire_at is a Unix timestamp in seconds.
+// Fields left out keep the server config.
+type policyJSON struct {
+	MaxTx      uint64 `json:"max_tx"`
+	MaxRx      uint64 `json:"max_rx"`
+	RateTx     uint64 `json:"rate_limit_tx"`
+	RateRx     uint64 `json:"rate_limit_rx"`
+	UDP        *bool  `json:"udp"`
+	MaxConns   int    `json:"max_conns"`
+	MaxStreams int    `json:"max_streams"`
+	Outbound   string `json:"outbound"`
+	ExpireAt   int64  `json:"expire_at"`
+}
+
+func (p *policyJSON) Policy() *server.Policy {
+	if p == nil {
+		return nil
+	}
+	policy := &server.Policy{
+		MaxTx: p.MaxTx,
+		MaxRx: p.MaxRx,
+		RateLimit: server.RateLimitConfig{
+			MaxTx: p.RateTx,
+			MaxRx: p.RateRx,
+		},
+		DisableUDP: p.UDP != nil && !*p.UDP,
+		MaxConns:   p.MaxConns,
+		MaxStreams: p.MaxStreams,
+		Outbound:   p.Outbound,
+	}
+	if p.ExpireAt > 0 {
+		policy.ExpireAt = time.Unix(p.ExpireAt, 0)
+	}
+	return policy
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/apernet/hysteria/core/v2/server"
+	"github.com/apernet/hysteria/extras/v2/auth"
 )
 
 const (
@@ -20,9 +21,19 @@ const (
 
 // TrafficStatsServer implements both server.TrafficLogger and http.Handler
 // to provide a simple HTTP API to get the traffic stats per user.
+// It also implements server.ConnTracer to report the path stats of each connection.
 type TrafficStatsServer interface {
 	server.TrafficLogger
+	server.ConnTracer
 	http.Handler
+	// SetBanList sets the ban list served by GET /bans and POST /unban.
+	SetBanList(l BanList)
+}
+
+// BanList is the list of client IPs banned for failing authentication, see auth.BanList.
+type BanList interface {
+	Bans() []auth.Ban
+	Unban(ip string) bool
 }
 
 func NewTrafficStatsServer(secret string) TrafficStatsServer {
@@ -31,6 +42,7 @@ func NewTrafficStatsServer(secret string) TrafficStatsServer {
 		KickMap:   make(map[string]struct{}),
 		OnlineMap: make(map[string]int),
 		StreamMap: make(map[server.HyStream]*server.StreamStats),
+		ConnMap:   make(map[*server.ConnStats]struct{}),
 		Secret:    secret,
 	}
 }
@@ -40,7 +52,9 @@ type trafficStatsServerImpl struct {
 	StatsMap  map[string]*trafficStatsEntry
 	OnlineMap map[string]int
 	StreamMap map[server.HyStream]*server.StreamStats
+	ConnMap   map[*server.ConnStats]struct{}
 	KickMap   map[string]struct{}
+	BanList   BanList
 	Secret    string
 }
 
@@ -99,6 +113,27 @@ func (s *trafficStatsServerImpl) UntraceStream(stream server.HyStream) {
 	delete(s.StreamMap, stream)
 }
 
+func (s *trafficStatsServerImpl) TraceConn(stats *server.ConnStats) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	s.ConnMap[stats] = struct{}{}
+}
+
+func (s *trafficStatsServerImpl) UntraceConn(stats *server.ConnStats) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	delete(s.ConnMap, stats)
+}
+
+func (s *trafficStatsServerImpl) SetBanList(l BanList) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	s.BanList = l
+}
+
 func (s *trafficStatsServerImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if s.Secret != "" && r.Header.Get("Authorization") != s.Secret {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -116,6 +151,14 @@ func (s *trafficStatsServerImpl) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		s.kick(w, r)
 		return
 	}
+	if r.Method == http.MethodPost && r.URL.Path == "/message" {
+		s.message(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/bandwidth" {
+		s.bandwidth(w, r)
+		return
+	}
 	if r.Method == http.MethodGet && r.URL.Path == "/online" {
 		s.getOnline(w, r)
 		return
@@ -124,6 +167,18 @@ func (s *trafficStatsServerImpl) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		s.getDumpStreams(w, r)
 		return
 	}
+	if r.Method == http.MethodGet && r.URL.Path == "/dump/conns" {
+		s.getDumpConns(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/bans" {
+		s.getBans(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/unban" {
+		s.unban(w, r)
+		return
+	}
 	http.NotFound(w, r)
 }
 
@@ -282,6 +337,155 @@ func (s *trafficStatsServerImpl) getDumpStreams(w http.ResponseWriter, r *http.R
 	}
 }
 
+type dumpConnEntry struct {
+	Auth       string `json:"auth"`
+	Connection uint32 `json:"connection"`
+	Addr       string `json:"addr"`
+	InitialAt  string `json:"initial_at"`
+
+	SmoothedRTT      float64 `json:"smoothed_rtt"` // milliseconds
+	MinRTT           float64 `json:"min_rtt"`      // milliseconds
+	CongestionWindow uint64  `json:"cwnd"`
+	BytesInFlight    uint64  `json:"bytes_in_flight"`
+	PacketsSent      uint64  `json:"packets_sent"`
+	PacketsLost      uint64  `json:"packets_lost"`
+	BytesLost        uint64  `json:"bytes_lost"`
+	MTU              uint64  `json:"mtu"`
+	Congestion       string  `json:"congestion"`
+	BrutalAckRate    float64 `json:"brutal_ack_rate,omitempty"`
+	BrutalBandwidth  uint64  `json:"brutal_bandwidth,omitempty"`
+	BBRMode          string  `json:"bbr_mode,omitempty"`
+	BBRBandwidth     uint64  `json:"bbr_bandwidth,omitempty"` // bytes per second
+}
+
+func (e *dumpConnEntry) fromConnStats(s *server.ConnStats) {
+	p := s.PathStats()
+	e.Auth = s.AuthID
+	e.Connection = s.ConnID
+	e.Addr = s.RemoteAddr.String()
+	e.InitialAt = s.InitialTime.Format(time.RFC3339Nano)
+	e.SmoothedRTT = float64(p.SmoothedRTT) / float64(time.Millisecond)
+	e.MinRTT = float64(p.MinRTT) / float64(time.Millisecond)
+	e.CongestionWindow = p.CongestionWindow
+	e.BytesInFlight = p.BytesInFlight
+	e.PacketsSent = p.PacketsSent
+	e.PacketsLost = p.PacketsLost
+	e.BytesLost = p.BytesLost
+	e.MTU = p.MTU
+	e.Congestion = p.Congestion
+	e.BrutalAckRate = p.BrutalAckRate
+	e.BrutalBandwidth = p.BrutalBandwidth
+	e.BBRMode = p.BBRMode
+	e.BBRBandwidth = p.BBRBandwidth
+}
+
+// getDumpConns responds with the path stats of every connection, sorted like the streams.
+func (s *trafficStatsServerImpl) getDumpConns(w http.ResponseWriter, r *http.Request) {
+	s.Mutex.RLock()
+	entries := make([]dumpConnEntry, 0, len(s.ConnMap))
+	for stats := range s.ConnMap {
+		var entry dumpConnEntry
+		entry.fromConnStats(stats)
+		entries = append(entries, entry)
+	}
+	s.Mutex.RUnlock()
+
+	slices.SortFunc(entries, func(lhs, rhs dumpConnEntry) int {
+		if ret := cmp.Compare(lhs.Auth, rhs.Auth); ret != 0 {
+			return ret
+		}
+		return cmp.Compare(lhs.Connection, rhs.Connection)
+	})
+
+	wrapper := struct {
+		Conns []dumpConnEntry `json:"conns"`
+	}{entries}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err := json.NewEncoder(w).Encode(&wrapper)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type messageRequest struct {
+	User  string `json:"user"`
+	Type  string `json:"type"`
+	Value uint64 `json:"value"`
+	Text  string `json:"text"`
+}
+
+// message pushes a control message to every connection of a user,
+// and responds with the number of connections it was sent to.
+func (s *trafficStatsServerImpl) message(w http.ResponseWriter, r *http.Request) {
+	var req messageRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msgType, ok := server.ParseControlType(req.Type)
+	if !ok {
+		http.Error(w, "invalid message type", http.StatusBadRequest)
+		return
+	}
+	msg := server.ControlMessage{Type: msgType, Value: req.Value, Text: req.Text}
+
+	s.Mutex.RLock()
+	var conns []*server.ConnStats
+	for stats := range s.ConnMap {
+		if stats.AuthID == req.User {
+			conns = append(conns, stats)
+		}
+	}
+	s.Mutex.RUnlock()
+
+	sent := 0
+	for _, stats := range conns {
+		// Clients that don't support control messages are skipped
+		if stats.SendMessage(msg) == nil {
+			sent++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]int{"sent": sent})
+}
+
+type bandwidthRequest struct {
+	User string `json:"user"`
+	Tx   uint64 `json:"tx"`
+	Rx   uint64 `json:"rx"`
+}
+
+// bandwidth changes the bandwidth of every connection of a user while they stay open,
+// and responds with the number of connections whose client was told about it.
+func (s *trafficStatsServerImpl) bandwidth(w http.ResponseWriter, r *http.Request) {
+	var req bandwidthRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Mutex.RLock()
+	var conns []*server.ConnStats
+	for stats := range s.ConnMap {
+		if stats.AuthID == req.User {
+			conns = append(conns, stats)
+		}
+	}
+	s.Mutex.RUnlock()
+
+	sent := 0
+	for _, stats := range conns {
+		// tx is applied even when the client doesn't support control messages
+		if stats.SetBandwidth(req.Tx, req.Rx) == nil {
+			sent++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]int{"sent": sent})
+}
+
 func (s *trafficStatsServerImpl) kick(w http.ResponseWriter, r *http.Request) {
 	var ids []string
 	err := json.NewDecoder(r.Body).Decode(&ids)
@@ -297,3 +501,46 @@ func (s *trafficStatsServerImpl) kick(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// getBans responds with the banned IPs and when their bans end.
+func (s *trafficStatsServerImpl) getBans(w http.ResponseWriter, r *http.Request) {
+	s.Mutex.RLock()
+	banList := s.BanList
+	s.Mutex.RUnlock()
+
+	wrapper := struct {
+		Bans []auth.Ban `json:"bans"`
+	}{[]auth.Ban{}}
+	if banList != nil {
+		wrapper.Bans = banList.Bans()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err := json.NewEncoder(w).Encode(&wrapper)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// unban lifts the bans of a list of IPs, and responds with the number of them that were banned.
+func (s *trafficStatsServerImpl) unban(w http.ResponseWriter, r *http.Request) {
+	var ips []string
+	err := json.NewDecoder(r.Body).Decode(&ips)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Mutex.RLock()
+	banList := s.BanList
+	s.Mutex.RUnlock()
+
+	unbanned := 0
+	if banList != nil {
+		for _, ip := range ips {
+			if banList.Unban(ip) {
+				unbanned++
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]int{"unbanned": unbanned})
+}
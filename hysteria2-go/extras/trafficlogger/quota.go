@@ -0,0 +1,285 @@
+package trafficlogger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	defaultQuotaSaveInterval = 1 * time.Minute
+	quotaMonthFormat         = "2006-01"
+)
+
+// QuotaLimit is the amount of traffic (tx + rx) a user is allowed, in bytes.
+// 0 means unlimited.
+type QuotaLimit struct {
+	Monthly uint64 `json:"monthly"` // per calendar month in UTC
+	Total   uint64 `json:"total"`
+}
+
+// QuotaUsage is the amount of traffic a user has used, in bytes.
+type QuotaUsage struct {
+	Monthly uint64 `json:"monthly"`
+	Total   uint64 `json:"total"`
+}
+
+type quotaState struct {
+	Month string                 `json:"month"`
+	Users map[string]*QuotaUsage `json:"users"`
+}
+
+// QuotaLogger is a server.TrafficLogger that enforces per-user traffic quotas.
+// The limits are read from a JSON file that maps user IDs to QuotaLimit, users
+// not in it are unlimited. The file is read again whenever it changes.
+// The usage is saved to a state file periodically and on Close, so that it survives restarts.
+// Users over their quota are refused at authentication, and disconnected
+// as soon as they go over it.
+// Everything is also passed to Next if set, including ConnTracer and UserChecker calls
+// if Next implements them, so that it can be combined with TrafficStatsServer.
+type QuotaLogger struct {
+	Next server.TrafficLogger
+
+	limitsFile   string
+	limitsMod    time.Time
+	stateFile    string
+	saveInterval time.Duration
+	nowFunc      func() time.Time
+
+	mutex  sync.Mutex
+	limits map[string]QuotaLimit
+	state  quotaState
+	dirty  bool
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+	doneChan  chan struct{}
+}
+
+// NewQuotaLogger loads the limits and the saved usage, and starts saving the usage
+// every saveInterval (1 minute if 0). A missing state file starts from zero.
+func NewQuotaLogger(limitsFile, stateFile string, saveInterval time.Duration, next server.TrafficLogger) (*QuotaLogger, error) {
+	if saveInterval == 0 {
+		saveInterval = defaultQuotaSaveInterval
+	}
+	l := &QuotaLogger{
+		Next:         next,
+		limitsFile:   limitsFile,
+		stateFile:    stateFile,
+		saveInterval: saveInterval,
+		nowFunc:      time.Now,
+		closeChan:    make(chan struct{}),
+		doneChan:     make(chan struct{}),
+	}
+	if err := l.loadLimits(); err != nil {
+		return nil, err
+	}
+	if err := l.loadState(); err != nil {
+		return nil, err
+	}
+	go l.run()
+	return l, nil
+}
+
+func (l *QuotaLogger) loadLimits() error {
+	info, err := os.Stat(l.limitsFile)
+	if err != nil {
+		return err
+	}
+	bs, err := os.ReadFile(l.limitsFile)
+	if err != nil {
+		return err
+	}
+	var limits map[string]QuotaLimit
+	if err := json.Unmarshal(bs, &limits); err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	l.limits = limits
+	l.mutex.Unlock()
+	l.limitsMod = info.ModTime()
+	return nil
+}
+
+func (l *QuotaLogger) loadState() error {
+	state := quotaState{Users: make(map[string]*QuotaUsage)}
+	bs, err := os.ReadFile(l.stateFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(bs, &state); err != nil {
+			return err
+		}
+		if state.Users == nil {
+			state.Users = make(map[string]*QuotaUsage)
+		}
+	}
+	l.mutex.Lock()
+	l.state = state
+	l.mutex.Unlock()
+	return nil
+}
+
+func (l *QuotaLogger) run() {
+	defer close(l.doneChan)
+	ticker := time.NewTicker(l.saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if info, err := os.Stat(l.limitsFile); err == nil && !info.ModTime().Equal(l.limitsMod) {
+				// Keep the old limits if the new file is invalid
+				_ = l.loadLimits()
+			}
+			_ = l.Save()
+		case <-l.closeChan:
+			return
+		}
+	}
+}
+
+// Save writes the usage to the state file, if it has changed since the last save.
+func (l *QuotaLogger) Save() error {
+	l.mutex.Lock()
+	if !l.dirty {
+		l.mutex.Unlock()
+		return nil
+	}
+	bs, err := json.Marshal(&l.state)
+	l.dirty = false
+	l.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that a crash never leaves a truncated state
+	tmpFile := l.stateFile + ".tmp"
+	if err := os.WriteFile(tmpFile, bs, 0o644); err != nil {
+		l.setDirty()
+		return err
+	}
+	if err := os.Rename(tmpFile, l.stateFile); err != nil {
+		l.setDirty()
+		return err
+	}
+	return nil
+}
+
+func (l *QuotaLogger) setDirty() {
+	l.mutex.Lock()
+	l.dirty = true
+	l.mutex.Unlock()
+}
+
+// Close stops the periodic saving and saves the usage one last time.
+func (l *QuotaLogger) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closeChan)
+	})
+	<-l.doneChan
+	return l.Save()
+}
+
+// Usage returns the current usage of a user.
+func (l *QuotaLogger) Usage(id string) QuotaUsage {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rollMonthLocked()
+	if u := l.state.Users[id]; u != nil {
+		return *u
+	}
+	return QuotaUsage{}
+}
+
+// rollMonthLocked resets the monthly usage when a new month begins.
+func (l *QuotaLogger) rollMonthLocked() {
+	month := l.nowFunc().UTC().Format(quotaMonthFormat)
+	if l.state.Month == month {
+		return
+	}
+	for _, u := range l.state.Users {
+		u.Monthly = 0
+	}
+	l.state.Month = month
+	l.dirty = true
+}
+
+func (l *QuotaLogger) exceededLocked(id string) bool {
+	limit, ok := l.limits[id]
+	if !ok {
+		return false
+	}
+	u := l.state.Users[id]
+	if u == nil {
+		return false
+	}
+	return (limit.Monthly > 0 && u.Monthly >= limit.Monthly) ||
+		(limit.Total > 0 && u.Total >= limit.Total)
+}
+
+func (l *QuotaLogger) CheckUser(id string) (ok bool) {
+	l.mutex.Lock()
+	l.rollMonthLocked()
+	ok = !l.exceededLocked(id)
+	l.mutex.Unlock()
+	if uc, isUC := l.Next.(server.UserChecker); ok && isUC {
+		ok = uc.CheckUser(id)
+	}
+	return ok
+}
+
+func (l *QuotaLogger) LogTraffic(id string, tx, rx uint64) (ok bool) {
+	ok = true
+	if l.Next != nil {
+		ok = l.Next.LogTraffic(id, tx, rx)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rollMonthLocked()
+	if tx+rx > 0 {
+		u := l.state.Users[id]
+		if u == nil {
+			u = &QuotaUsage{}
+			l.state.Users[id] = u
+		}
+		u.Monthly += tx + rx
+		u.Total += tx + rx
+		l.dirty = true
+	}
+	return ok && !l.exceededLocked(id)
+}
+
+func (l *QuotaLogger) LogOnlineState(id string, online bool) {
+	if l.Next != nil {
+		l.Next.LogOnlineState(id, online)
+	}
+}
+
+func (l *QuotaLogger) TraceStream(stream server.HyStream, stats *server.StreamStats) {
+	if l.Next != nil {
+		l.Next.TraceStream(stream, stats)
+	}
+}
+
+func (l *QuotaLogger) UntraceStream(stream server.HyStream) {
+	if l.Next != nil {
+		l.Next.UntraceStream(stream)
+	}
+}
+
+func (l *QuotaLogger) TraceConn(stats *server.ConnStats) {
+	if ct, ok := l.Next.(server.ConnTracer); ok {
+		ct.TraceConn(stats)
+	}
+}
+
+func (l *QuotaLogger) UntraceConn(stats *server.ConnStats) {
+	if ct, ok := l.Next.(server.ConnTracer); ok {
+		ct.UntraceConn(stats)
+	}
+}
@@ -0,0 +1,76 @@
+package trafficlogger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaLogger(t *testing.T) {
+	dir := t.TempDir()
+	limitsFile := filepath.Join(dir, "limits.json")
+	stateFile := filepath.Join(dir, "state.json")
+	assert.NoError(t, os.WriteFile(limitsFile, []byte(`{
+		"alice": {"monthly": 1000},
+		"bob": {"total": 1500}
+	}`), 0o644))
+
+	now := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	newLogger := func() *QuotaLogger {
+		l, err := NewQuotaLogger(limitsFile, stateFile, time.Hour, nil)
+		assert.NoError(t, err)
+		l.nowFunc = func() time.Time { return now }
+		return l
+	}
+	l := newLogger()
+
+	// Under quota
+	assert.True(t, l.LogTraffic("alice", 300, 300))
+	assert.True(t, l.LogTraffic("bob", 500, 500))
+	assert.True(t, l.CheckUser("alice"))
+
+	// Over quota: disconnected, and refused from now on
+	assert.False(t, l.LogTraffic("alice", 400, 0))
+	assert.False(t, l.CheckUser("alice"))
+	assert.False(t, l.LogTraffic("bob", 0, 600))
+	assert.False(t, l.CheckUser("bob"))
+
+	// Users without a limit
+	assert.True(t, l.LogTraffic("carol", 1<<40, 0))
+	assert.True(t, l.CheckUser("carol"))
+
+	// The usage survives a restart
+	assert.NoError(t, l.Close())
+	l = newLogger()
+	assert.Equal(t, QuotaUsage{Monthly: 1000, Total: 1000}, l.Usage("alice"))
+	assert.False(t, l.CheckUser("alice"))
+
+	// The monthly quota resets, the total one doesn't
+	now = now.AddDate(0, 1, 0)
+	assert.True(t, l.CheckUser("alice"))
+	assert.Equal(t, QuotaUsage{Monthly: 0, Total: 1000}, l.Usage("alice"))
+	assert.False(t, l.CheckUser("bob"))
+	assert.NoError(t, l.Close())
+}
+
+func TestQuotaLoggerNext(t *testing.T) {
+	dir := t.TempDir()
+	limitsFile := filepath.Join(dir, "limits.json")
+	assert.NoError(t, os.WriteFile(limitsFile, []byte(`{}`), 0o644))
+
+	tss := NewTrafficStatsServer("")
+	l, err := NewQuotaLogger(limitsFile, filepath.Join(dir, "state.json"), time.Hour, tss)
+	assert.NoError(t, err)
+	defer l.Close()
+
+	assert.True(t, l.LogTraffic("alice", 100, 200))
+	entry := tss.(*trafficStatsServerImpl).StatsMap["alice"]
+	assert.Equal(t, &trafficStatsEntry{Tx: 100, Rx: 200}, entry)
+
+	// Kicks by the stats server still apply
+	tss.(*trafficStatsServerImpl).KickMap["alice"] = struct{}{}
+	assert.False(t, l.LogTraffic("alice", 1, 1))
+}
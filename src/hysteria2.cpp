@@ -26,26 +26,317 @@
 namespace py = pybind11;
 
 namespace {
+    PyObject* clientErrorType = nullptr;
+
+    // Only accessed with the GIL held.
+    py::object* eventHandler = nullptr;
+    py::object* logHandler = nullptr;
+
+    // Raises hysteria2.ClientError from the JSON error returned by the Go side,
+    // which is freed here. Does nothing if error is NULL. Must hold the GIL.
+    void checkError(char* error)
+    {
+        if (error == nullptr) {
+            return;
+        }
+
+        std::string encoded(error);
+        freeString(error);
+
+        py::dict info = py::module_::import("json").attr("loads")(encoded);
+        py::object exception = py::reinterpret_borrow<py::object>(clientErrorType)(info["message"]);
+        exception.attr("category") = info["category"];
+        exception.attr("field") = info.contains("field") ? py::object(info["field"]) : py::object(py::str(""));
+
+        PyErr_SetObject(clientErrorType, exception.ptr());
+        throw py::error_already_set();
+    }
+
+    void validateJSON(const std::string& json)
+    {
+        GoString jsonString{json.data(), static_cast<ptrdiff_t>(json.size())};
+        char* error;
+
+        {
+            py::gil_scoped_release release;
+
+            error = validateClientJSON(jsonString);
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        checkError(error);
+    }
+
     void startFromJSON(const std::string& json)
     {
         GoString jsonString{json.data(), static_cast<ptrdiff_t>(json.size())};
+        char* error;
+
+        {
+            py::gil_scoped_release release;
+
+            error = startClientFromJSON(jsonString);
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        checkError(error);
+    }
+
+    long long startClientInstance(const std::string& json)
+    {
+        GoString jsonString{json.data(), static_cast<ptrdiff_t>(json.size())};
+        GoInt64 handle;
+        char* error;
 
         {
             py::gil_scoped_release release;
 
-            startClientFromJSON(jsonString);
+            handle = startClient(jsonString, &error);
 
             py::gil_scoped_acquire acquire;
         }
+
+        checkError(error);
+
+        return handle;
+    }
+
+    void waitClientInstance(long long handle)
+    {
+        char* error;
+
+        {
+            py::gil_scoped_release release;
+
+            error = waitClient(static_cast<GoInt64>(handle));
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        checkError(error);
+    }
+
+    py::object clientInstanceStats(long long handle)
+    {
+        char* stats;
+
+        {
+            py::gil_scoped_release release;
+
+            stats = clientStats(static_cast<GoInt64>(handle));
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        if (stats == nullptr) {
+            throw py::value_error("unknown Hysteria2 client handle");
+        }
+
+        std::string encoded(stats);
+        freeString(stats);
+
+        return py::module_::import("json").attr("loads")(encoded);
+    }
+
+    void reloadClientInstance(long long handle, const std::string& json)
+    {
+        GoString jsonString{json.data(), static_cast<ptrdiff_t>(json.size())};
+        char* error;
+
+        {
+            py::gil_scoped_release release;
+
+            error = reloadClient(static_cast<GoInt64>(handle), jsonString);
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        checkError(error);
+    }
+
+    void setClientInstanceBandwidth(long long handle, const std::string& up, const std::string& down)
+    {
+        GoString upString{up.data(), static_cast<ptrdiff_t>(up.size())};
+        GoString downString{down.data(), static_cast<ptrdiff_t>(down.size())};
+        char* error;
+
+        {
+            py::gil_scoped_release release;
+
+            error = setClientBandwidth(static_cast<GoInt64>(handle), upString, downString);
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        checkError(error);
+    }
+
+    void stopClientInstance(long long handle)
+    {
+        py::gil_scoped_release release;
+
+        stopClient(static_cast<GoInt64>(handle));
+    }
+
+    void dispatchEvent(long long handle, const char* event, void* /* userData */)
+    {
+        py::gil_scoped_acquire acquire;
+
+        if (eventHandler == nullptr) {
+            return;
+        }
+
+        try {
+            py::object info = py::module_::import("json").attr("loads")(py::str(event));
+
+            (*eventHandler)(handle, info);
+        } catch (py::error_already_set& e) {
+            e.discard_as_unraisable("hysteria2 event callback");
+        }
+    }
+
+    void setEventHandler(const py::object& handler)
+    {
+        if (handler.is_none()) {
+            {
+                py::gil_scoped_release release;
+
+                // Waits for running callbacks, which need the GIL to return
+                setEventCallback(nullptr, nullptr);
+            }
+
+            delete eventHandler;
+            eventHandler = nullptr;
+
+            return;
+        }
+
+        if (eventHandler == nullptr) {
+            eventHandler = new py::object(handler);
+        } else {
+            *eventHandler = handler;
+        }
+
+        py::gil_scoped_release release;
+
+        setEventCallback(&dispatchEvent, nullptr);
+    }
+
+    void dispatchLog(const char* line, void* /* userData */)
+    {
+        py::gil_scoped_acquire acquire;
+
+        if (logHandler == nullptr) {
+            return;
+        }
+
+        try {
+            (*logHandler)(py::str(line));
+        } catch (py::error_already_set& e) {
+            e.discard_as_unraisable("hysteria2 log callback");
+        }
+    }
+
+    void setLogHandler(const py::object& handler)
+    {
+        if (handler.is_none()) {
+            {
+                py::gil_scoped_release release;
+
+                setLogCallback(nullptr, nullptr);
+            }
+
+            // In-flight calls check for nullptr with the GIL held
+            delete logHandler;
+            logHandler = nullptr;
+
+            return;
+        }
+
+        if (logHandler == nullptr) {
+            logHandler = new py::object(handler);
+        } else {
+            *logHandler = handler;
+        }
+
+        py::gil_scoped_release release;
+
+        setLogCallback(&dispatchLog, nullptr);
+    }
+
+    void setLevel(const std::string& level)
+    {
+        GoString levelString{level.data(), static_cast<ptrdiff_t>(level.size())};
+        char* error;
+
+        {
+            py::gil_scoped_release release;
+
+            error = setLogLevel(levelString);
+
+            py::gil_scoped_acquire acquire;
+        }
+
+        checkError(error);
     }
 
     // TODO: Audit the C++ and Go code for free-threading safety before using
     // py::mod_gil_not_used() here.
     PYBIND11_MODULE(hysteria2, m) {
+        clientErrorType = PyErr_NewException("hysteria2.ClientError", PyExc_RuntimeError, nullptr);
+        m.attr("ClientError") = py::handle(clientErrorType);
+
+        m.def("validateJSON",
+            &validateJSON,
+            "Validate Hysteria2 client JSON without connecting, raise ClientError if invalid",
+            py::arg("json"));
         m.def("startFromJSON",
             &startFromJSON,
-            "Start Hysteria2 client with JSON",
+            "Start Hysteria2 client with JSON, raise ClientError if it fails",
             py::arg("json"));
+        m.def("startClient",
+            &startClientInstance,
+            "Start Hysteria2 client with JSON in the background and return its handle",
+            py::arg("json"));
+        m.def("waitClient",
+            &waitClientInstance,
+            "Wait for Hysteria2 client started by startClient to stop, raise ClientError if it failed",
+            py::arg("handle"));
+        m.def("clientStats",
+            &clientInstanceStats,
+            "Get traffic counters of Hysteria2 client started by startClient as a dict",
+            py::arg("handle"));
+        m.def("reloadClient",
+            &reloadClientInstance,
+            "Apply new JSON to Hysteria2 client started by startClient, raise ClientError if invalid",
+            py::arg("handle"),
+            py::arg("json"));
+        m.def("setBandwidth",
+            &setClientInstanceBandwidth,
+            "Change bandwidth of Hysteria2 client started by startClient without reconnecting, raise ClientError if it fails",
+            py::arg("handle"),
+            py::arg("up"),
+            py::arg("down"));
+        m.def("stopClient",
+            &stopClientInstance,
+            "Stop Hysteria2 client started by startClient",
+            py::arg("handle"));
+
+        m.def("setEventCallback",
+            &setEventHandler,
+            "Set callback(handle, event) receiving events of all clients as dicts, None to unset",
+            py::arg("callback"));
+
+        m.def("setLogCallback",
+            &setLogHandler,
+            "Set callback(line) receiving log entries instead of stderr, None to unset",
+            py::arg("callback"));
+        m.def("setLogLevel",
+            &setLevel,
+            "Change log level (debug, info, warn or error) at runtime",
+            py::arg("level"));
 
         m.attr("__version__") = "2.12.1.1";
     }